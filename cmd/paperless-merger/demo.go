@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/meska/paperless-merger/internal/config"
+	"github.com/meska/paperless-merger/internal/paperless/fake"
+)
+
+// demoLatency rallenta le risposte del server finto quanto basta per vedere
+// avanzare le operazioni
+const demoLatency = 20 * time.Millisecond
+
+// startDemo avvia il server finto su una porta locale con la fixture indicata
+// (o una generata dal seme) e restituisce la configurazione che lo usa, insieme alla
+// funzione che ferma il server ed elimina i journal della demo.
+// Della configurazione reale vengono tenute solo le preferenze, non l'istanza.
+func startDemo(cfg *config.Config, fixturePath string, seed int64) (*config.Config, func(), error) {
+	fixture := fake.Generate(seed)
+	if fixturePath != "" {
+		var err error
+		if fixture, err = fake.LoadFixture(fixturePath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	server, err := fake.NewServer(fixture)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fixture non valida: %w", err)
+	}
+	server.Latency = demoLatency
+
+	dir, err := os.MkdirTemp("", "paperless-merger-demo-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	httpServer := &http.Server{Handler: server}
+	go httpServer.Serve(listener)
+
+	token := fixture.Token
+	if token == "" {
+		token = fake.DefaultToken
+	}
+
+	demoCfg := &config.Config{
+		BaseURL:  "http://" + listener.Addr().String(),
+		APIKey:   token,
+		Language: cfg.Language,
+		DryRun:   cfg.DryRun,
+		Demo:     true,
+		DemoDir:  dir,
+	}
+	stop := func() {
+		httpServer.Close()
+		os.RemoveAll(dir)
+	}
+	return demoCfg, stop, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -12,12 +13,30 @@ import (
 var version = "dev"
 
 func main() {
+	dryRun := flag.Bool("dry-run", false, "simula i merge senza inviare modifiche a Paperless-ngx")
+	demo := flag.Bool("demo", false, "usa un server Paperless-ngx finto in memoria con dati di prova")
+	demoFixture := flag.String("demo-fixture", "", "file JSON con i dati del server finto (al posto di quelli generati)")
+	demoSeed := flag.Int64("demo-seed", 1, "seme dei dati generati per la demo")
+	flag.Parse()
+
 	// Carica o crea la configurazione
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Printf("Errore nel caricamento della configurazione: %v\n", err)
 		os.Exit(1)
 	}
+	cfg.DryRun = *dryRun
+
+	// In modalità demo l'applicazione lavora solo sul server finto
+	if *demo {
+		demoCfg, stop, err := startDemo(cfg, *demoFixture, *demoSeed)
+		if err != nil {
+			fmt.Printf("Errore nell'avvio della demo: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+		cfg = demoCfg
+	}
 
 	// Se la configurazione non esiste, mostra il setup iniziale
 	if cfg.BaseURL == "" || cfg.APIKey == "" {
@@ -29,8 +48,8 @@ func main() {
 		return
 	}
 
-	// Avvia l'applicazione principale
-	p := tea.NewProgram(ui.NewMainModel(cfg))
+	// Avvia l'applicazione principale, passando prima dal controllo dei merge interrotti
+	p := tea.NewProgram(ui.NewRecoveryModel(cfg))
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Errore: %v\n", err)
 		os.Exit(1)
@@ -2,9 +2,12 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // Config rappresenta la configurazione dell'applicazione
@@ -12,6 +15,42 @@ type Config struct {
 	BaseURL  string `json:"base_url"`
 	APIKey   string `json:"api_key"`
 	Language string `json:"language"` // "auto", "en", "it"
+
+	// Autenticazione verso un reverse proxy davanti a Paperless-ngx (es. Authelia, Traefik).
+	// ExtraHeaders sono aggiunti a ogni richiesta (es. "Cookie" con la sessione del proxy),
+	// BasicAuthUser e BasicAuthPassword sono le credenziali HTTP basic del proxy e
+	// TokenHeader è l'header in cui inviare il token quando il proxy usa Authorization.
+	ExtraHeaders      map[string]string `json:"extra_headers,omitempty"`
+	BasicAuthUser     string            `json:"basic_auth_user,omitempty"`
+	BasicAuthPassword string            `json:"basic_auth_password,omitempty"`
+	TokenHeader       string            `json:"token_header,omitempty"`
+
+	// IgnoredGroups elenca i gruppi di elementi simili da non proporre più
+	IgnoredGroups []string `json:"ignored_groups,omitempty"`
+
+	// RequestTimeout è il tempo massimo di attesa di ogni richiesta a Paperless-ngx,
+	// in secondi (0 per il valore predefinito del client)
+	RequestTimeout int `json:"request_timeout,omitempty"`
+	// MaxRetries è il numero di tentativi aggiuntivi per le richieste fallite per un errore
+	// temporaneo (502, 503, 504, 429, errori di rete); nil per il valore predefinito
+	MaxRetries *int `json:"max_retries,omitempty"`
+	// MaxRetryDelay è l'attesa massima tra due tentativi in secondi, anche quando l'header
+	// Retry-After del server chiede di più (0 per il valore predefinito del client)
+	MaxRetryDelay int `json:"max_retry_delay,omitempty"`
+	// RequestsPerSecond e MaxConcurrency limitano le richieste al secondo e quelle
+	// contemporanee (0 per nessun limite), per non sovraccaricare un'istanza piccola
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	MaxConcurrency    int     `json:"max_concurrency,omitempty"`
+	// DocumentWorkers è il numero di documenti aggiornati contemporaneamente quando
+	// il server non supporta bulk_edit (0 per il valore predefinito)
+	DocumentWorkers int `json:"document_workers,omitempty"`
+
+	// DryRun è impostato da riga di comando (--dry-run) e non viene salvato
+	DryRun bool `json:"-"`
+	// Demo è impostato da riga di comando (--demo): l'applicazione usa il server finto,
+	// la configurazione non viene salvata e i journal restano in DemoDir
+	Demo    bool   `json:"-"`
+	DemoDir string `json:"-"`
 }
 
 // GetConfigPath restituisce il percorso del file di configurazione
@@ -29,6 +68,25 @@ func GetConfigPath() (string, error) {
 	return filepath.Join(configDir, "config.json"), nil
 }
 
+// GetJournalDir restituisce la directory in cui vengono salvati i journal dei merge
+func GetJournalDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".config", "paperless-merger", "journal"), nil
+}
+
+// JournalDir restituisce la directory dei journal: quella della modalità demo,
+// se attiva, altrimenti quella nella directory di configurazione
+func (c *Config) JournalDir() (string, error) {
+	if c.Demo {
+		return filepath.Join(c.DemoDir, "journal"), nil
+	}
+	return GetJournalDir()
+}
+
 // Load carica la configurazione dal file
 func Load() (*Config, error) {
 	configPath, err := GetConfigPath()
@@ -50,12 +108,20 @@ func Load() (*Config, error) {
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("errore nel parsing del file di configurazione: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("configurazione non valida in %s: %w", configPath, err)
+	}
 
 	return &cfg, nil
 }
 
-// Save salva la configurazione nel file
+// Save salva la configurazione nel file. In modalità demo non salva nulla,
+// per non sostituire la configurazione reale.
 func (c *Config) Save() error {
+	if c.Demo {
+		return nil
+	}
+
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return err
@@ -72,3 +138,53 @@ func (c *Config) Save() error {
 
 	return nil
 }
+
+// HasProxyAuth indica se è configurata l'autenticazione verso un reverse proxy
+func (c *Config) HasProxyAuth() bool {
+	return len(c.ExtraHeaders) > 0 || c.BasicAuthUser != "" || c.TokenHeader != ""
+}
+
+// Validate verifica le impostazioni che il client non potrebbe applicare
+func (c *Config) Validate() error {
+	// Con la basic auth l'header Authorization porta le credenziali del proxy:
+	// il token sostituirebbe le credenziali
+	if c.BasicAuthUser != "" && (c.TokenHeader == "" || strings.EqualFold(c.TokenHeader, "Authorization")) {
+		return errors.New("basic_auth_user richiede un token_header diverso da Authorization")
+	}
+	return nil
+}
+
+// Timeout restituisce il tempo massimo di attesa di ogni richiesta (0 se non impostato)
+func (c *Config) Timeout() time.Duration {
+	return time.Duration(c.RequestTimeout) * time.Second
+}
+
+// RetryDelay restituisce l'attesa massima tra due tentativi (0 per il valore predefinito)
+func (c *Config) RetryDelay() time.Duration {
+	return time.Duration(c.MaxRetryDelay) * time.Second
+}
+
+// IsIgnored indica se il gruppo con la chiave indicata non va più proposto
+func (c *Config) IsIgnored(key string) bool {
+	for _, ignored := range c.IgnoredGroups {
+		if ignored == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIgnored aggiunge o toglie un gruppo da quelli da non proporre più e salva la configurazione
+func (c *Config) SetIgnored(key string, ignored bool) error {
+	groups := make([]string, 0, len(c.IgnoredGroups)+1)
+	for _, g := range c.IgnoredGroups {
+		if g != key {
+			groups = append(groups, g)
+		}
+	}
+	if ignored {
+		groups = append(groups, key)
+	}
+	c.IgnoredGroups = groups
+	return c.Save()
+}
@@ -0,0 +1,145 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// Attribute identifica un attributo del sopravvissuto che può essere preso da un altro elemento del merge
+type Attribute string
+
+const (
+	AttrColor  Attribute = "color"  // Colore (solo tag)
+	AttrInbox  Attribute = "inbox"  // Tag della posta in arrivo (solo tag)
+	AttrOwner  Attribute = "owner"  // Proprietario
+	AttrParent Attribute = "parent" // Tag padre (solo tag)
+)
+
+// Attributes restituisce gli attributi che si possono scegliere per il tipo di entità.
+// La regola di matching si sceglie a parte (vedi Plan.Matching).
+func Attributes(kind Kind) []Attribute {
+	switch kind {
+	case KindTags:
+		return []Attribute{AttrColor, AttrInbox, AttrOwner, AttrParent}
+	case KindCorrespondents, KindDocumentTypes, KindStoragePaths:
+		return []Attribute{AttrOwner}
+	}
+	return nil
+}
+
+// SameValue indica se due elementi hanno lo stesso valore per l'attributo
+func (a Attribute) SameValue(x, y Item) bool {
+	switch a {
+	case AttrColor:
+		return x.Color == y.Color
+	case AttrInbox:
+		return x.IsInboxTag == y.IsInboxTag
+	case AttrOwner:
+		return sameID(x.Owner, y.Owner)
+	case AttrParent:
+		return sameID(x.Parent, y.Parent)
+	}
+	return true
+}
+
+// sameID confronta due ID opzionali
+func sameID(x, y *int) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return *x == *y
+}
+
+// copyAttribute copia il valore dell'attributo da src a dst
+func copyAttribute(dst *Item, src Item, attr Attribute) {
+	switch attr {
+	case AttrColor:
+		dst.Color = src.Color
+	case AttrInbox:
+		dst.IsInboxTag = src.IsInboxTag
+	case AttrOwner:
+		dst.Owner = src.Owner
+	case AttrParent:
+		dst.Parent = src.Parent
+	}
+}
+
+// attributeValues raccoglie i valori scelti per gli attributi del piano. Gli elementi
+// assorbiti vengono letti dal journal, se presente, perché in ripresa potrebbero
+// essere già stati eliminati.
+func (e *Executor) attributeValues(ctx context.Context, plan Plan, journal *Journal) (Item, error) {
+	var values Item
+	for attr, sourceID := range plan.Sources {
+		source, found := Item{}, false
+		if journal != nil {
+			for _, absorbed := range journal.Absorbed {
+				if absorbed.Item.ID == sourceID {
+					source, found = absorbed.Item, true
+				}
+			}
+		}
+		if !found {
+			item, err := fetchItem(ctx, e.client, plan.Kind, sourceID)
+			if err != nil {
+				return values, &StepError{Step: StepSnapshot, ItemID: sourceID, Err: err}
+			}
+			source = item
+		}
+		copyAttribute(&values, source, attr)
+	}
+
+	// Un tag non può stare sotto se stesso né sotto un tag che il merge elimina
+	if values.Parent != nil && (*values.Parent == plan.SurvivorID || containsID(plan.AbsorbIDs, *values.Parent)) {
+		values.Parent = nil
+	}
+	return values, nil
+}
+
+// updateAttributes assegna a un elemento i valori degli attributi indicati
+func updateAttributes(ctx context.Context, client *paperless.Client, kind Kind, id int, values Item, attrs []Attribute) error {
+	for _, attr := range attrs {
+		var err error
+		switch attr {
+		case AttrColor:
+			err = client.UpdateTagColor(ctx, id, values.Color)
+		case AttrInbox:
+			err = client.UpdateTagInbox(ctx, id, values.IsInboxTag)
+		case AttrParent:
+			err = client.UpdateTagParent(ctx, id, values.Parent)
+		case AttrOwner:
+			err = updateOwner(ctx, client, kind, id, values.Owner)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateOwner assegna il proprietario di un elemento del tipo indicato
+func updateOwner(ctx context.Context, client *paperless.Client, kind Kind, id int, owner *int) error {
+	switch kind {
+	case KindTags:
+		return client.UpdateTagOwner(ctx, id, owner)
+	case KindCorrespondents:
+		return client.UpdateCorrespondentOwner(ctx, id, owner)
+	case KindDocumentTypes:
+		return client.UpdateDocumentTypeOwner(ctx, id, owner)
+	case KindStoragePaths:
+		return client.UpdateStoragePathOwner(ctx, id, owner)
+	}
+	return fmt.Errorf("tipo di entità senza proprietario: %d", kind)
+}
+
+// sourceAttributes restituisce gli attributi presi da altri elementi, nell'ordine di Attributes
+func (p Plan) sourceAttributes() []Attribute {
+	var attrs []Attribute
+	for _, attr := range Attributes(p.Kind) {
+		if _, ok := p.Sources[attr]; ok {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,48 @@
+package merge
+
+import (
+	"context"
+	"errors"
+)
+
+// Un'operazione si annulla (tasto Esc) solo nei punti sicuri tra un passo e l'altro:
+// le richieste ricevono un contesto che non viene mai annullato, così quella in corso
+// arriva sempre a termine e il server non resta con una modifica a metà.
+
+// ErrRolledBack indica che un'operazione senza journal (conversione, suddivisione, unione
+// di opzioni) è stata annullata e che le modifiche già eseguite sono state ripristinate
+var ErrRolledBack = errors.New("operazione annullata: le modifiche già eseguite sono state ripristinate")
+
+// stopKey è la chiave del contesto originale, il cui annullamento ferma l'operazione
+type stopKey struct{}
+
+// safeContext restituisce il contesto da usare per le richieste di un'operazione: non viene
+// annullato insieme a ctx, il cui annullamento viene controllato da stopped nei punti sicuri
+func safeContext(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(stopKey{}).(context.Context); ok {
+		return ctx
+	}
+	return context.WithValue(context.WithoutCancel(ctx), stopKey{}, ctx)
+}
+
+// withoutStop restituisce un contesto per i passi da completare anche dopo un annullamento,
+// come il ripristino delle modifiche già eseguite
+func withoutStop(ctx context.Context) context.Context {
+	return context.WithValue(safeContext(ctx), stopKey{}, context.Background())
+}
+
+// stopped restituisce l'errore dell'annullamento, se è stato chiesto
+func stopped(ctx context.Context) error {
+	if stop, ok := ctx.Value(stopKey{}).(context.Context); ok {
+		return stop.Err()
+	}
+	return ctx.Err()
+}
+
+// stopRequested restituisce il canale che viene chiuso quando è chiesto l'annullamento
+func stopRequested(ctx context.Context) <-chan struct{} {
+	if stop, ok := ctx.Value(stopKey{}).(context.Context); ok {
+		return stop.Done()
+	}
+	return ctx.Done()
+}
@@ -0,0 +1,243 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/paperless/fake"
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// bulkModes sono le due strade per aggiornare i documenti
+var bulkModes = []struct {
+	name            string
+	disableBulkEdit bool
+}{
+	{"bulk_edit", false},
+	{"per documento", true},
+}
+
+// cancelAfter restituisce un contesto e un reporter che lo annulla appena la fase step
+// ha aggiornato almeno un documento (dell'elemento item, se diverso da 0)
+func cancelAfter(step Step, item int) (context.Context, Reporter) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return ctx, ReporterFunc(func(p Progress) {
+		if p.Step == step && p.Document > 0 && (item == 0 || p.Item == item) {
+			cancel()
+		}
+	})
+}
+
+// itemNames restituisce i nomi degli elementi del tipo indicato
+func itemNames(t *testing.T, client *paperless.Client, kind Kind) []string {
+	t.Helper()
+
+	items, err := listItems(context.Background(), client, kind)
+	if err != nil {
+		t.Fatalf("listItems: %v", err)
+	}
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestConvertRollsBackOnCancel(t *testing.T) {
+	for _, mode := range bulkModes {
+		t.Run(mode.name, func(t *testing.T) {
+			old := 1
+			client := newTestClient(t, &fake.Fixture{
+				DisableBulkEdit: mode.disableBulkEdit,
+				Tags:            []paperless.Tag{{ID: 1, Name: "Fornitore"}, {ID: 2, Name: "X"}},
+				Correspondents:  []paperless.Correspondent{{ID: 1, Name: "Vecchio"}},
+				Documents: []paperless.Document{
+					{ID: 10, Title: "Senza corrispondente", Tags: []int{1, 2}},
+					{ID: 11, Title: "Con corrispondente", Tags: []int{1}, Correspondent: &old},
+					{ID: 12, Title: "Senza tag", Tags: []int{2}},
+				},
+			})
+
+			// L'annullamento arriva durante l'assegnazione della destinazione
+			ctx, reporter := cancelAfter(StepUpdateDocuments, 0)
+			executor := NewExecutor(client, NewJournalStore(t.TempDir()), reporter)
+
+			_, err := executor.Convert(ctx, Conversion{TagID: 1, Target: KindCorrespondents, Name: "Nuovo", Policy: ConflictOverwrite})
+			if !errors.Is(err, ErrRolledBack) {
+				t.Fatalf("Convert: errore %v, atteso ErrRolledBack", err)
+			}
+
+			wantTags := map[int][]int{10: {1, 2}, 11: {1}, 12: {2}}
+			wantCorrespondents := map[int]*int{10: nil, 11: &old, 12: nil}
+			for docID, want := range wantTags {
+				if got := documentTags(t, client, docID); !reflect.DeepEqual(got, want) {
+					t.Errorf("documento %d: tag %v, attesi %v", docID, got, want)
+				}
+				doc, err := client.GetDocument(context.Background(), docID)
+				if err != nil {
+					t.Fatalf("GetDocument(%d): %v", docID, err)
+				}
+				if !reflect.DeepEqual(doc.Correspondent, wantCorrespondents[docID]) {
+					t.Errorf("documento %d: corrispondente %v, atteso %v", docID, doc.Correspondent, wantCorrespondents[docID])
+				}
+			}
+			if got := itemNames(t, client, KindCorrespondents); !reflect.DeepEqual(got, []string{"Vecchio"}) {
+				t.Errorf("corrispondenti %v, atteso solo quello originale", got)
+			}
+		})
+	}
+}
+
+func TestSplitRollsBackOnCancel(t *testing.T) {
+	for _, mode := range bulkModes {
+		t.Run(mode.name, func(t *testing.T) {
+			client := newTestClient(t, &fake.Fixture{
+				DisableBulkEdit: mode.disableBulkEdit,
+				Tags:            []paperless.Tag{{ID: 1, Name: "Documenti"}, {ID: 2, Name: "Contratti"}},
+				Documents: []paperless.Document{
+					{ID: 10, Title: "Fattura 1", Tags: []int{1}},
+					{ID: 11, Title: "Fattura 2", Tags: []int{1}},
+					{ID: 12, Title: "Contratto 1", Tags: []int{1, 2}},
+					{ID: 13, Title: "Contratto 2", Tags: []int{1}},
+					{ID: 14, Title: "Altro", Tags: []int{1}},
+				},
+			})
+
+			source, err := LoadSplit(context.Background(), client, KindTags, 1)
+			if err != nil {
+				t.Fatalf("LoadSplit: %v", err)
+			}
+			invoices, err := source.NewRule(SplitByTitle, "^Fattura", "Fatture")
+			if err != nil {
+				t.Fatalf("NewRule: %v", err)
+			}
+			contracts, err := source.NewRule(SplitByTitle, "^Contratto", "Contratti")
+			if err != nil {
+				t.Fatalf("NewRule: %v", err)
+			}
+			others, err := source.NewRule(SplitByTitle, "^Altro", "Varie")
+			if err != nil {
+				t.Fatalf("NewRule: %v", err)
+			}
+
+			// L'annullamento arriva durante la seconda regola e la ferma prima della terza
+			ctx, reporter := cancelAfter(StepUpdateDocuments, 2)
+			executor := NewExecutor(client, NewJournalStore(t.TempDir()), reporter)
+
+			_, err = executor.Split(ctx, Split{Kind: KindTags, SourceID: 1, Rules: []SplitRule{invoices, contracts, others}})
+			if !errors.Is(err, ErrRolledBack) {
+				t.Fatalf("Split: errore %v, atteso ErrRolledBack", err)
+			}
+
+			// Il documento 12 aveva già la destinazione esistente e la mantiene
+			want := map[int][]int{10: {1}, 11: {1}, 12: {1, 2}, 13: {1}, 14: {1}}
+			for docID, tags := range want {
+				if got := documentTags(t, client, docID); !reflect.DeepEqual(got, tags) {
+					t.Errorf("documento %d: tag %v, attesi %v", docID, got, tags)
+				}
+			}
+			if got := itemNames(t, client, KindTags); !reflect.DeepEqual(got, []string{"Contratti", "Documenti"}) {
+				t.Errorf("tag %v, attesi solo quelli originali", got)
+			}
+		})
+	}
+}
+
+func TestMergeOptionsRollsBackOnCancel(t *testing.T) {
+	extraData := []byte(`{"select_options":[{"id":"a","label":"Rosso"},{"id":"b","label":"rosso"},{"id":"c","label":"ROSSO"}]}`)
+	values := map[int]string{10: `"b"`, 11: `"b"`, 12: `"c"`, 13: `"a"`}
+
+	documents := make([]paperless.Document, 0, len(values))
+	for docID := 10; docID <= 13; docID++ {
+		documents = append(documents, paperless.Document{
+			ID:           docID,
+			Title:        "Documento",
+			CustomFields: []paperless.CustomFieldInstance{{Field: 1, Value: []byte(values[docID])}},
+		})
+	}
+	client := newTestClient(t, &fake.Fixture{
+		CustomFields: []paperless.CustomField{{ID: 1, Name: "Colore", DataType: paperless.CustomFieldSelect, ExtraData: extraData}},
+		Documents:    documents,
+	})
+
+	field, err := LoadSelectField(context.Background(), client, 1)
+	if err != nil {
+		t.Fatalf("LoadSelectField: %v", err)
+	}
+	optionMerge, err := NewOptionMerge(field, []int{0, 1, 2}, "Rosso")
+	if err != nil {
+		t.Fatalf("NewOptionMerge: %v", err)
+	}
+
+	ctx, reporter := cancelAfter(StepUpdateDocuments, 0)
+	executor := NewExecutor(client, NewJournalStore(t.TempDir()), reporter)
+
+	if _, err := executor.MergeOptions(ctx, optionMerge); !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("MergeOptions: errore %v, atteso ErrRolledBack", err)
+	}
+
+	for docID, value := range values {
+		if got := fieldValues(t, client, docID); !reflect.DeepEqual(got, map[int]string{1: value}) {
+			t.Errorf("documento %d: valori %v, atteso %s", docID, got, value)
+		}
+	}
+	restored, err := LoadSelectField(context.Background(), client, 1)
+	if err != nil {
+		t.Fatalf("LoadSelectField: %v", err)
+	}
+	if want := []string{"Rosso", "rosso", "ROSSO"}; !reflect.DeepEqual(restored.Options, want) {
+		t.Errorf("opzioni %v, attese %v", restored.Options, want)
+	}
+}
+
+func TestUndoStopsOnCancelAndRepeats(t *testing.T) {
+	for _, mode := range bulkModes {
+		t.Run(mode.name, func(t *testing.T) {
+			client := newTestClient(t, e2eFixture(KindTags, mode.disableBulkEdit))
+			store := NewJournalStore(t.TempDir())
+			original := documentRefs(t, client, KindTags)
+
+			plan, err := NewPlan(KindTags, []similarity.SimilarItem{
+				{ID: 1, Name: e2eNames[0]},
+				{ID: 2, Name: e2eNames[1]},
+				{ID: 3, Name: e2eNames[2]},
+			}, 1, e2eNames[0])
+			if err != nil {
+				t.Fatalf("NewPlan: %v", err)
+			}
+			if _, err := NewExecutor(client, store, nil).Execute(context.Background(), plan); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+
+			// L'annullamento arriva mentre tornano i documenti del primo assorbito:
+			// il secondo non viene toccato e l'undo resta da ripetere
+			journal, err := store.LastUndoable()
+			if err != nil {
+				t.Fatalf("LastUndoable: %v", err)
+			}
+			ctx, reporter := cancelAfter(StepRestoreDocuments, 1)
+			if err := NewExecutor(client, store, reporter).Undo(ctx, journal); !errors.Is(err, context.Canceled) {
+				t.Fatalf("Undo: errore %v, atteso context.Canceled", err)
+			}
+			if got := documentRefs(t, client, KindTags); reflect.DeepEqual(got, original) {
+				t.Fatal("Undo: l'annullamento non ha fermato l'undo")
+			}
+
+			journal, err = store.LastUndoable()
+			if err != nil {
+				t.Fatalf("LastUndoable dopo l'annullamento: %v", err)
+			}
+			if err := NewExecutor(client, store, nil).Undo(context.Background(), journal); err != nil {
+				t.Fatalf("Undo ripetuto: %v", err)
+			}
+			if got := documentRefs(t, client, KindTags); !reflect.DeepEqual(got, original) {
+				t.Errorf("dopo l'undo ripetuto: documenti %v, attesi %v", got, original)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package merge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// ErrCleanupUnsupported indica che la pulizia non è disponibile per il tipo di elemento
+var ErrCleanupUnsupported = errors.New("la pulizia degli elementi inutilizzati non è disponibile per i campi personalizzati")
+
+// CleanupResult riassume una pulizia completata
+type CleanupResult struct {
+	Deleted   []Item // Elementi eliminati
+	Kept      []Item // Elementi che nel frattempo hanno ricevuto documenti e non sono stati eliminati
+	JournalID string // ID del journal della pulizia (vuoto se il journal non è attivo)
+}
+
+// FindUnused cerca gli elementi senza documenti secondo il conteggio del server.
+// Sono esclusi i tag inbox e gli elementi usati da workflow o regole mail,
+// che servono anche quando nessun documento li usa ancora.
+func FindUnused(ctx context.Context, client *paperless.Client, kind Kind) ([]Item, error) {
+	if kind == KindCustomFields {
+		return nil, ErrCleanupUnsupported
+	}
+
+	items, err := listItems(ctx, client, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []Item
+	for _, item := range items {
+		if item.DocumentCount == 0 && !item.IsInboxTag {
+			candidates = append(candidates, item)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	used, err := automationIDs(ctx, client, kind, itemIDs(candidates))
+	if err != nil {
+		return nil, err
+	}
+
+	var unused []Item
+	for _, item := range candidates {
+		if !used[item.ID] {
+			unused = append(unused, item)
+		}
+	}
+	return unused, nil
+}
+
+// automationIDs restituisce gli elementi, tra quelli indicati, usati da workflow o regole mail.
+// Le istanze che non espongono workflow o regole mail (404) vengono ignorate.
+func automationIDs(ctx context.Context, client *paperless.Client, kind Kind, ids []int) (map[int]bool, error) {
+	workflows, err := client.GetWorkflows(ctx)
+	if err != nil && !paperless.IsNotFound(err) {
+		return nil, err
+	}
+	rules, err := client.GetMailRules(ctx)
+	if err != nil && !paperless.IsNotFound(err) {
+		return nil, err
+	}
+
+	// La riscrittura modifica l'oggetto: ogni controllo lavora su una copia
+	var objects [][]byte
+	for _, workflow := range workflows {
+		data, _ := json.Marshal(workflow)
+		objects = append(objects, data)
+	}
+	workflowCount := len(objects)
+	for _, rule := range rules {
+		data, _ := json.Marshal(rule)
+		objects = append(objects, data)
+	}
+
+	used := make(map[int]bool)
+	for _, id := range ids {
+		mapping := map[int]int{id: -id}
+		for i, data := range objects {
+			if i < workflowCount {
+				var workflow paperless.Workflow
+				if json.Unmarshal(data, &workflow) == nil && rewriteWorkflow(&workflow, kind, mapping) {
+					used[id] = true
+					break
+				}
+				continue
+			}
+			var rule paperless.MailRule
+			if json.Unmarshal(data, &rule) == nil && rewriteMailRule(&rule, kind, mapping) {
+				used[id] = true
+				break
+			}
+		}
+	}
+	return used, nil
+}
+
+// Cleanup elimina gli elementi indicati, registrandoli prima nel journal così che la pulizia
+// possa essere ripresa o annullata. Un elemento che nel frattempo ha ricevuto documenti
+// non viene eliminato.
+func (e *Executor) Cleanup(ctx context.Context, kind Kind, items []Item) (CleanupResult, error) {
+	if kind == KindCustomFields {
+		return CleanupResult{}, ErrCleanupUnsupported
+	}
+	ctx = safeContext(ctx)
+
+	// Senza journal attivo il journal resta solo in memoria
+	j := newJournal(Plan{Kind: kind, AbsorbIDs: itemIDs(items)})
+	j.Cleanup = true
+	for _, item := range items {
+		j.Absorbed = append(j.Absorbed, AbsorbedItem{Item: item})
+	}
+	if err := e.saveJournal(j); err != nil {
+		return CleanupResult{}, err
+	}
+
+	result, err := e.runCleanup(ctx, j)
+	if e.journal == nil {
+		return result, err
+	}
+	result.JournalID = j.ID
+	return result, e.closeJournal(j, err)
+}
+
+// runCleanup elimina gli elementi del journal non ancora eliminati
+func (e *Executor) runCleanup(ctx context.Context, j *Journal) (CleanupResult, error) {
+	var result CleanupResult
+
+	kind := j.Plan.Kind
+	current := 0
+	total := len(j.Absorbed) * 2
+
+	for idx := range j.Absorbed {
+		absorbed := &j.Absorbed[idx]
+		if absorbed.Deleted {
+			current += 2
+			result.Deleted = append(result.Deleted, absorbed.Item)
+			continue
+		}
+		// Un annullamento si ferma tra un elemento e l'altro: il journal registra
+		// quelli già eliminati e la pulizia può essere ripresa o annullata
+		if err := stopped(ctx); err != nil {
+			return result, err
+		}
+
+		// Step 1: Il conteggio del server potrebbe essere cambiato dopo l'elenco
+		current++
+		e.report(Progress{Step: StepGetDocuments, Current: current, Total: total, Item: idx + 1, Items: len(j.Absorbed)})
+
+		docs, err := itemDocuments(ctx, e.client, kind, absorbed.Item.ID)
+		if err != nil {
+			return result, &StepError{Step: StepGetDocuments, ItemID: absorbed.Item.ID, Err: err}
+		}
+		current++
+		if len(docs) > 0 {
+			result.Kept = append(result.Kept, absorbed.Item)
+			continue
+		}
+
+		// Step 2: Eliminazione (un 404 significa che è già stato eliminato)
+		e.report(Progress{Step: StepDelete, Current: current, Total: total, Item: idx + 1, Items: len(j.Absorbed)})
+
+		if err := deleteItem(ctx, e.client, kind, absorbed.Item.ID); err != nil && !paperless.IsNotFound(err) {
+			return result, &StepError{Step: StepDelete, ItemID: absorbed.Item.ID, Err: err}
+		}
+		absorbed.Deleted = true
+		result.Deleted = append(result.Deleted, absorbed.Item)
+		if err := e.saveJournal(j); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// undoCleanup annulla una pulizia ricreando gli elementi eliminati.
+// In caso di errore l'undo può essere ripetuto: gli elementi già ricreati non vengono duplicati.
+func (e *Executor) undoCleanup(ctx context.Context, j *Journal) error {
+	for idx := range j.Absorbed {
+		absorbed := &j.Absorbed[idx]
+		if !absorbed.Deleted || absorbed.RestoredID != 0 {
+			continue
+		}
+		if err := stopped(ctx); err != nil {
+			return err
+		}
+
+		e.report(Progress{Step: StepRecreate, Current: idx + 1, Total: len(j.Absorbed), Item: idx + 1, Items: len(j.Absorbed)})
+
+		id, err := createItem(ctx, e.client, j.Plan.Kind, absorbed.Item)
+		if err != nil {
+			return &StepError{Step: StepRecreate, ItemID: absorbed.Item.ID, Err: err}
+		}
+		absorbed.RestoredID = id
+		if err := e.saveJournal(j); err != nil {
+			return err
+		}
+	}
+
+	j.Status = StatusUndone
+	return e.saveJournal(j)
+}
+
+// itemIDs restituisce gli ID degli elementi
+func itemIDs(items []Item) []int {
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
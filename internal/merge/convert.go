@@ -0,0 +1,364 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// ConflictPolicy decide cosa fare dei documenti che hanno già un corrispondente
+// (o tipo documento) diverso da quello di destinazione della conversione
+type ConflictPolicy int
+
+const (
+	ConflictOverwrite ConflictPolicy = iota // Il valore esistente viene sostituito
+	ConflictKeep                            // Il valore esistente resta, il tag viene comunque tolto
+	ConflictSkip                            // Il documento non viene toccato e mantiene il tag, che quindi non viene eliminato
+)
+
+// ConflictPolicies elenca le politiche disponibili, nell'ordine in cui vengono proposte
+var ConflictPolicies = []ConflictPolicy{ConflictOverwrite, ConflictKeep, ConflictSkip}
+
+// ErrInvalidTarget indica che la conversione non ha come destinazione corrispondenti o tipi documento
+var ErrInvalidTarget = errors.New("un tag può essere convertito solo in corrispondente o tipo documento")
+
+// Conversion descrive la conversione di un tag in un corrispondente o tipo documento
+type Conversion struct {
+	TagID  int
+	Target Kind   // KindCorrespondents o KindDocumentTypes
+	Name   string // Nome della destinazione: se esiste già un elemento con questo nome i documenti passano a quello
+	Policy ConflictPolicy
+}
+
+// ConversionPreview descrive cosa farà una conversione, senza modificare nulla
+type ConversionPreview struct {
+	Conversion Conversion
+	Tag        Item
+	Existing   *Item // Destinazione già esistente (nil se verrà creata)
+	Documents  int   // Documenti con il tag
+	Already    int   // Documenti che hanno già la destinazione
+	Conflicts  int   // Documenti con un valore diverso dalla destinazione
+	// Viste salvate, workflow e regole mail che usano il tag, da riscrivere verso la
+	// destinazione se il tag viene eliminato
+	References []Reference
+}
+
+// ConversionResult riassume una conversione completata
+type ConversionResult struct {
+	TargetID   int  // Elemento di destinazione
+	Created    bool // true se la destinazione è stata creata dalla conversione
+	Converted  int  // Documenti a cui è stata assegnata la destinazione
+	Kept       int  // Documenti che hanno mantenuto il proprio valore
+	TagDeleted bool // false se qualche documento mantiene il tag (ConflictSkip)
+	// Viste salvate, workflow e regole mail riscritti verso la destinazione e quelli da cui
+	// il tag è stato solo tolto, perché il campo della destinazione aveva già un altro
+	// valore o non è esposto dal server
+	References []Reference
+	Dropped    []Reference
+}
+
+// checkConversion verifica che la conversione sia eseguibile
+func checkConversion(conv Conversion) error {
+	if conv.Target != KindCorrespondents && conv.Target != KindDocumentTypes {
+		return ErrInvalidTarget
+	}
+	if strings.TrimSpace(conv.Name) == "" {
+		return ErrEmptyName
+	}
+	return nil
+}
+
+// PreviewConversion calcola l'anteprima di una conversione contando documenti e conflitti
+func PreviewConversion(ctx context.Context, client *paperless.Client, conv Conversion) (ConversionPreview, error) {
+	if err := checkConversion(conv); err != nil {
+		return ConversionPreview{}, err
+	}
+
+	preview := ConversionPreview{Conversion: conv}
+
+	tag, err := fetchItem(ctx, client, KindTags, conv.TagID)
+	if err != nil {
+		return ConversionPreview{}, &StepError{Step: StepSnapshot, ItemID: conv.TagID, Err: err}
+	}
+	preview.Tag = tag
+
+	existing, err := findByName(ctx, client, conv.Target, conv.Name)
+	if err != nil {
+		return ConversionPreview{}, &StepError{Step: StepSnapshot, ItemID: conv.TagID, Err: err}
+	}
+	preview.Existing = existing
+
+	docs, err := client.GetDocumentsByTag(ctx, conv.TagID)
+	if err != nil {
+		return ConversionPreview{}, &StepError{Step: StepGetDocuments, ItemID: conv.TagID, Err: err}
+	}
+	preview.Documents = len(docs)
+	for _, doc := range docs {
+		value := documentValue(doc, conv.Target)
+		switch {
+		case value == nil:
+		case existing != nil && *value == existing.ID:
+			preview.Already++
+		default:
+			preview.Conflicts++
+		}
+	}
+
+	refs, err := FindReferences(ctx, client, KindTags, []int{conv.TagID})
+	if err != nil {
+		return ConversionPreview{}, &StepError{Step: StepReferences, ItemID: conv.TagID, Err: err}
+	}
+	preview.References = refs
+
+	return preview, nil
+}
+
+// Convert converte un tag: crea la destinazione (o usa quella con lo stesso nome),
+// la assegna ai documenti con il tag secondo la politica dei conflitti, toglie il tag
+// dai documenti, riscrive verso la destinazione viste salvate, workflow e regole mail
+// che usano il tag e infine elimina il tag. La nuova destinazione eredita la regola di
+// matching e il proprietario del tag. Le conversioni non vengono registrate nel journal:
+// se vengono annullate prima dell'eliminazione del tag le modifiche già eseguite vengono
+// ripristinate e l'errore è ErrRolledBack.
+func (e *Executor) Convert(ctx context.Context, conv Conversion) (ConversionResult, error) {
+	var result ConversionResult
+
+	if err := checkConversion(conv); err != nil {
+		return result, err
+	}
+	ctx = safeContext(ctx)
+
+	current := 0
+	total := 5
+
+	// Step 1: Lettura del tag e della destinazione
+	current++
+	e.report(Progress{Step: StepSnapshot, Current: current, Total: total})
+
+	tag, err := fetchItem(ctx, e.client, KindTags, conv.TagID)
+	if err != nil {
+		return result, &StepError{Step: StepSnapshot, ItemID: conv.TagID, Err: err}
+	}
+	existing, err := findByName(ctx, e.client, conv.Target, conv.Name)
+	if err != nil {
+		return result, &StepError{Step: StepSnapshot, ItemID: conv.TagID, Err: err}
+	}
+
+	// Step 2: Creazione della destinazione, se non esiste
+	current++
+	if existing != nil {
+		result.TargetID = existing.ID
+	} else {
+		e.report(Progress{Step: StepCreate, Current: current, Total: total})
+
+		id, err := createItem(ctx, e.client, conv.Target, Item{
+			Name:              strings.TrimSpace(conv.Name),
+			Match:             tag.Match,
+			MatchingAlgorithm: tag.MatchingAlgorithm,
+			IsInsensitive:     tag.IsInsensitive,
+			Owner:             tag.Owner,
+		})
+		if err != nil {
+			return result, &StepError{Step: StepCreate, ItemID: conv.TagID, Err: err}
+		}
+		result.TargetID = id
+		result.Created = true
+	}
+
+	// Step 3: Recupero documenti e applicazione della politica dei conflitti
+	current++
+	e.report(Progress{Step: StepGetDocuments, Current: current, Total: total, Item: 1, Items: 1})
+
+	docs, err := e.client.GetDocumentsByTag(ctx, conv.TagID)
+	if err != nil {
+		return result, &StepError{Step: StepGetDocuments, ItemID: conv.TagID, Err: err}
+	}
+
+	var assign, untag []int
+	for _, doc := range docs {
+		value := documentValue(doc, conv.Target)
+		switch {
+		case value != nil && *value == result.TargetID:
+			untag = append(untag, doc.ID)
+		case value == nil || conv.Policy == ConflictOverwrite:
+			assign = append(assign, doc.ID)
+			untag = append(untag, doc.ID)
+		case conv.Policy == ConflictKeep:
+			untag = append(untag, doc.ID)
+			result.Kept++
+		default:
+			result.Kept++
+		}
+	}
+
+	// Un annullamento riporta l'istanza allo stato precedente: vengono ripristinati
+	// solo i passi già iniziati
+	var assigned, untagged []int
+	rollback := func() (ConversionResult, error) {
+		e.report(Progress{Step: StepRollback, Current: current, Total: total})
+		if err := e.rollbackConversion(ctx, conv, result, docs, assigned, untagged); err != nil {
+			return result, err
+		}
+		return ConversionResult{}, ErrRolledBack
+	}
+	if stopped(ctx) != nil {
+		return rollback()
+	}
+
+	// Step 4: Assegnazione della destinazione
+	current++
+	if len(assign) > 0 {
+		progress := Progress{Step: StepUpdateDocuments, Current: current, Total: total, Item: 1, Items: 1, Documents: len(assign)}
+		e.report(progress)
+
+		assigned = assign
+		if docID, err := e.moveDocuments(ctx, conv.Target, assign, 0, result.TargetID, e.documentProgress(progress, nil)); err != nil {
+			if stopped(ctx) != nil {
+				return rollback()
+			}
+			return result, &StepError{Step: StepUpdateDocuments, ItemID: conv.TagID, DocumentID: docID, Err: err}
+		}
+		result.Converted = len(assign)
+	}
+	if stopped(ctx) != nil {
+		return rollback()
+	}
+
+	// Step 5: Rimozione del tag dai documenti ed eliminazione del tag
+	current++
+	if len(untag) > 0 {
+		progress := Progress{Step: StepRemoveTag, Current: current, Total: total, Documents: len(untag)}
+		e.report(progress)
+
+		untagged = untag
+		docID, err := e.updateDocuments(ctx, untag,
+			func(chunk []int) error {
+				return e.client.BulkModifyTags(ctx, chunk, nil, []int{conv.TagID})
+			},
+			func(docID int) error {
+				return e.client.RemoveDocumentTag(ctx, docID, conv.TagID)
+			},
+			e.documentProgress(progress, nil))
+		if err != nil {
+			if stopped(ctx) != nil {
+				return rollback()
+			}
+			return result, &StepError{Step: StepRemoveTag, ItemID: conv.TagID, DocumentID: docID, Err: err}
+		}
+	}
+
+	// Tolto il tag dai documenti restano i riferimenti e la sua eliminazione, che non
+	// vengono più fermati. Se il tag resta su qualche documento, resta anche nei riferimenti.
+	if len(untag) == len(docs) {
+		e.report(Progress{Step: StepReferences, Current: current, Total: total})
+
+		if err := e.convertReferences(ctx, conv, &result); err != nil {
+			return result, err
+		}
+
+		e.report(Progress{Step: StepDelete, Current: current, Total: total, Item: 1, Items: 1})
+
+		if err := deleteItem(ctx, e.client, KindTags, conv.TagID); err != nil && !paperless.IsNotFound(err) {
+			return result, &StepError{Step: StepDelete, ItemID: conv.TagID, Err: err}
+		}
+		result.TagDeleted = true
+	}
+
+	return result, nil
+}
+
+// convertReferences riscrive verso la destinazione viste salvate, workflow e regole mail
+// che usano il tag, registrando nel risultato quelli riscritti e quelli che lo perdono
+func (e *Executor) convertReferences(ctx context.Context, conv Conversion, result *ConversionResult) error {
+	refs, err := FindReferences(ctx, e.client, KindTags, []int{conv.TagID})
+	if err != nil {
+		return &StepError{Step: StepReferences, ItemID: conv.TagID, Err: err}
+	}
+	for _, ref := range refs {
+		dropped, err := convertReference(ctx, e.client, ref, conv.TagID, conv.Target, result.TargetID)
+		if err != nil {
+			return &StepError{Step: StepReferences, ItemID: conv.TagID, Err: err}
+		}
+		if dropped {
+			result.Dropped = append(result.Dropped, ref)
+		} else {
+			result.References = append(result.References, ref)
+		}
+	}
+	return nil
+}
+
+// rollbackConversion ripristina lo stato precedente a una conversione annullata: rimette il
+// tag ai documenti da cui era stato tolto, riporta al valore originale (o a nessuno) quelli a
+// cui era stata assegnata la destinazione ed elimina la destinazione se era stata creata.
+// I passi sono ripetibili: i documenti non ancora modificati restano come sono.
+func (e *Executor) rollbackConversion(ctx context.Context, conv Conversion, result ConversionResult, docs []paperless.Document, assigned, untagged []int) error {
+	ctx = withoutStop(ctx)
+
+	if len(untagged) > 0 {
+		docID, err := e.updateDocuments(ctx, untagged,
+			func(chunk []int) error {
+				return e.client.BulkModifyTags(ctx, chunk, []int{conv.TagID}, nil)
+			},
+			func(docID int) error {
+				return e.client.AddDocumentTag(ctx, docID, conv.TagID)
+			},
+			nil)
+		if err != nil {
+			return &StepError{Step: StepRollback, ItemID: conv.TagID, DocumentID: docID, Err: err}
+		}
+	}
+
+	// I documenti vengono raggruppati per valore originale (0 se non ne avevano)
+	var values []int
+	byValue := make(map[int][]int)
+	for _, doc := range docs {
+		if !containsID(assigned, doc.ID) {
+			continue
+		}
+		value := 0
+		if v := documentValue(doc, conv.Target); v != nil {
+			value = *v
+		}
+		if _, ok := byValue[value]; !ok {
+			values = append(values, value)
+		}
+		byValue[value] = append(byValue[value], doc.ID)
+	}
+	for _, value := range values {
+		if docID, err := e.moveDocuments(ctx, conv.Target, byValue[value], result.TargetID, value, nil); err != nil {
+			return &StepError{Step: StepRollback, ItemID: conv.TagID, DocumentID: docID, Err: err}
+		}
+	}
+
+	if result.Created {
+		if err := deleteItem(ctx, e.client, conv.Target, result.TargetID); err != nil && !paperless.IsNotFound(err) {
+			return &StepError{Step: StepRollback, ItemID: result.TargetID, Err: err}
+		}
+	}
+	return nil
+}
+
+// documentValue restituisce il corrispondente o il tipo documento di un documento
+func documentValue(doc paperless.Document, kind Kind) *int {
+	switch kind {
+	case KindCorrespondents:
+		return doc.Correspondent
+	case KindDocumentTypes:
+		return doc.DocumentType
+	case KindStoragePaths:
+		return doc.StoragePath
+	}
+	return nil
+}
+
+// findByName cerca un elemento con il nome indicato, senza distinguere maiuscole e minuscole
+func findByName(ctx context.Context, client *paperless.Client, kind Kind, name string) (*Item, error) {
+	items, err := listItems(ctx, client, kind)
+	if err != nil {
+		return nil, err
+	}
+	return itemByName(items, name), nil
+}
@@ -0,0 +1,145 @@
+package merge
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/paperless/fake"
+)
+
+// rawJSON codifica un valore per i campi generici di trigger e azioni dei workflow
+func rawJSON(t *testing.T, v any) json.RawMessage {
+	t.Helper()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return raw
+}
+
+func TestConvertRewritesReferences(t *testing.T) {
+	ctx := context.Background()
+
+	tagRule := func(ruleType, id int) paperless.FilterRule {
+		value := strconv.Itoa(id)
+		return paperless.FilterRule{RuleType: ruleType, Value: &value}
+	}
+	enel := 5
+	fixture := &fake.Fixture{
+		Tags:           []paperless.Tag{{ID: 1, Name: "Acme"}, {ID: 2, Name: "Fatture"}},
+		Correspondents: []paperless.Correspondent{{ID: enel, Name: "Enel"}},
+		Documents:      []paperless.Document{{ID: 10, Title: "Documento 10", Tags: []int{1, 2}}},
+		SavedViews: []paperless.SavedView{
+			{ID: 1, Name: "Acme", FilterRules: []paperless.FilterRule{tagRule(6, 1), tagRule(6, 2)}},
+			{ID: 2, Name: "Senza Acme", FilterRules: []paperless.FilterRule{tagRule(17, 1)}},
+		},
+		Workflows: []paperless.Workflow{
+			{
+				ID:   1,
+				Name: "Fatture Acme",
+				Triggers: []map[string]json.RawMessage{{
+					"id":                       rawJSON(t, 1),
+					"filter_has_tags":          rawJSON(t, []int{1, 2}),
+					"filter_has_correspondent": rawJSON(t, nil),
+				}},
+				Actions: []map[string]json.RawMessage{{
+					"id":                   rawJSON(t, 1),
+					"assign_tags":          rawJSON(t, []int{1}),
+					"assign_correspondent": rawJSON(t, nil),
+				}},
+			},
+			{
+				// Assegna già un altro corrispondente: il tag viene solo tolto
+				ID:   2,
+				Name: "Bollette Enel",
+				Actions: []map[string]json.RawMessage{{
+					"id":                   rawJSON(t, 2),
+					"assign_tags":          rawJSON(t, []int{1}),
+					"assign_correspondent": rawJSON(t, enel),
+				}},
+			},
+		},
+		MailRules: []paperless.MailRule{
+			{ID: 1, Name: "Mail Acme", AssignTags: []int{1, 2}},
+			{ID: 2, Name: "Mail Enel", AssignTags: []int{1}, AssignCorrespondent: &enel},
+		},
+	}
+	client := newTestClient(t, fixture)
+	executor := NewExecutor(client, NewJournalStore(t.TempDir()), nil)
+
+	result, err := executor.Convert(ctx, Conversion{TagID: 1, Target: KindCorrespondents, Name: "Acme", Policy: ConflictOverwrite})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if !result.TagDeleted {
+		t.Fatal("Convert: il tag non è stato eliminato")
+	}
+	target := result.TargetID
+
+	names := func(refs []Reference) []string {
+		var out []string
+		for _, ref := range refs {
+			out = append(out, ref.Name)
+		}
+		return out
+	}
+	if got, want := names(result.References), []string{"Acme", "Senza Acme", "Fatture Acme", "Mail Acme"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("riferimenti riscritti %v, attesi %v", got, want)
+	}
+	if got, want := names(result.Dropped), []string{"Bollette Enel", "Mail Enel"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("riferimenti che perdono solo il tag %v, attesi %v", got, want)
+	}
+
+	views, err := client.GetSavedViews(ctx)
+	if err != nil {
+		t.Fatalf("GetSavedViews: %v", err)
+	}
+	wantViews := [][]paperless.FilterRule{
+		{tagRule(3, target), tagRule(6, 2)},
+		{tagRule(27, target)},
+	}
+	for i, view := range views {
+		if !reflect.DeepEqual(view.FilterRules, wantViews[i]) {
+			t.Errorf("vista %q: regole %+v, attese %+v", view.Name, view.FilterRules, wantViews[i])
+		}
+	}
+
+	workflows, err := client.GetWorkflows(ctx)
+	if err != nil {
+		t.Fatalf("GetWorkflows: %v", err)
+	}
+	fields := []struct {
+		obj  map[string]json.RawMessage
+		name string
+		want any
+	}{
+		{workflows[0].Triggers[0], "filter_has_tags", []int{2}},
+		{workflows[0].Triggers[0], "filter_has_correspondent", target},
+		{workflows[0].Actions[0], "assign_tags", []int{}},
+		{workflows[0].Actions[0], "assign_correspondent", target},
+		{workflows[1].Actions[0], "assign_tags", []int{}},
+		{workflows[1].Actions[0], "assign_correspondent", enel},
+	}
+	for _, f := range fields {
+		if got, want := string(f.obj[f.name]), string(rawJSON(t, f.want)); got != want {
+			t.Errorf("workflow, campo %s: %s, atteso %s", f.name, got, want)
+		}
+	}
+
+	rules, err := client.GetMailRules(ctx)
+	if err != nil {
+		t.Fatalf("GetMailRules: %v", err)
+	}
+	wantRules := []paperless.MailRule{
+		{ID: 1, Name: "Mail Acme", AssignTags: []int{2}, AssignCorrespondent: &target},
+		{ID: 2, Name: "Mail Enel", AssignTags: []int{}, AssignCorrespondent: &enel},
+	}
+	if !reflect.DeepEqual(rules, wantRules) {
+		t.Errorf("regole mail %+v, attese %+v", rules, wantRules)
+	}
+}
@@ -0,0 +1,600 @@
+package merge
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+var (
+	// ErrNotSelectField indica che il campo personalizzato non è di tipo select
+	ErrNotSelectField = errors.New("il campo personalizzato non è di tipo select")
+	// ErrInvalidOption indica un'opzione che non esiste (più) nel campo select
+	ErrInvalidOption = errors.New("opzione del campo select non valida")
+)
+
+// valueMapper converte il valore di un campo assorbito nel valore equivalente
+// per il sopravvissuto (per i select, l'opzione con la stessa etichetta)
+type valueMapper func(json.RawMessage) json.RawMessage
+
+// selectOption è un'opzione di un campo select
+type selectOption struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// selectOptions sono le opzioni di un campo select. Fino a Paperless 2.13 le opzioni
+// sono semplici etichette e i documenti salvano l'indice dell'opzione; dalla 2.14
+// ogni opzione ha un id, che è il valore salvato nei documenti.
+type selectOptions struct {
+	options []selectOption
+	legacy  bool
+	extra   map[string]json.RawMessage // extra_data completo, per conservare gli altri dati del campo
+}
+
+// parseSelectOptions legge le opzioni dall'extra_data di un campo select
+func parseSelectOptions(extraData json.RawMessage) (*selectOptions, error) {
+	s := &selectOptions{extra: make(map[string]json.RawMessage)}
+	if isNull(extraData) {
+		return s, nil
+	}
+	if err := json.Unmarshal(extraData, &s.extra); err != nil {
+		return nil, err
+	}
+
+	raw := s.extra["select_options"]
+	if isNull(raw) {
+		return s, nil
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		var label string
+		if json.Unmarshal(entry, &label) == nil {
+			s.legacy = true
+			s.options = append(s.options, selectOption{Label: label})
+			continue
+		}
+		var option selectOption
+		if err := json.Unmarshal(entry, &option); err != nil {
+			return nil, err
+		}
+		s.options = append(s.options, option)
+	}
+
+	return s, nil
+}
+
+// value restituisce il valore salvato nei documenti per l'opzione i
+func (s *selectOptions) value(i int) json.RawMessage {
+	if s.legacy {
+		return json.RawMessage(strconv.Itoa(i))
+	}
+	data, _ := json.Marshal(s.options[i].ID)
+	return data
+}
+
+// index restituisce l'opzione corrispondente al valore di un documento, o -1
+func (s *selectOptions) index(value json.RawMessage) int {
+	if isNull(value) {
+		return -1
+	}
+	if s.legacy {
+		i, err := strconv.Atoi(string(bytes.TrimSpace(value)))
+		if err != nil || i < 0 || i >= len(s.options) {
+			return -1
+		}
+		return i
+	}
+
+	var id string
+	if err := json.Unmarshal(value, &id); err != nil {
+		return -1
+	}
+	for i, option := range s.options {
+		if option.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// find restituisce l'opzione con l'etichetta indicata (ignorando maiuscole e spazi), o -1
+func (s *selectOptions) find(label string) int {
+	for i, option := range s.options {
+		if strings.EqualFold(strings.TrimSpace(option.Label), strings.TrimSpace(label)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// add aggiunge un'opzione e ne restituisce l'indice
+func (s *selectOptions) add(label string) int {
+	option := selectOption{Label: label}
+	if !s.legacy {
+		option.ID = randomOptionID()
+	}
+	s.options = append(s.options, option)
+	return len(s.options) - 1
+}
+
+// remove elimina le opzioni indicate
+func (s *selectOptions) remove(indexes []int) {
+	kept := make([]selectOption, 0, len(s.options))
+	for i, option := range s.options {
+		if !containsID(indexes, i) {
+			kept = append(kept, option)
+		}
+	}
+	s.options = kept
+}
+
+// labels restituisce le etichette delle opzioni
+func (s *selectOptions) labels() []string {
+	labels := make([]string, len(s.options))
+	for i, option := range s.options {
+		labels[i] = option.Label
+	}
+	return labels
+}
+
+// extraData restituisce l'extra_data del campo con le opzioni correnti
+func (s *selectOptions) extraData() (json.RawMessage, error) {
+	var options interface{} = s.options
+	if s.legacy {
+		options = s.labels()
+	}
+
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	s.extra["select_options"] = data
+	return json.Marshal(s.extra)
+}
+
+// randomOptionID genera un id per una nuova opzione, come fa Paperless
+func randomOptionID() string {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	id := make([]byte, 16)
+	for i := range id {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			n = big.NewInt(int64(i))
+		}
+		id[i] = chars[n.Int64()]
+	}
+	return string(id)
+}
+
+// isNull indica se un valore JSON è assente o null
+func isNull(value json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(value)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
+
+// checkFieldTypes verifica che i campi personalizzati del piano abbiano lo stesso tipo di dato:
+// i valori di un campo possono passare solo a un campo dello stesso tipo
+func checkFieldTypes(ctx context.Context, client *paperless.Client, plan Plan) error {
+	survivor, err := client.GetCustomField(ctx, plan.SurvivorID)
+	if err != nil {
+		return &StepError{Step: StepSnapshot, ItemID: plan.SurvivorID, Err: err}
+	}
+	for _, id := range plan.AbsorbIDs {
+		field, err := client.GetCustomField(ctx, id)
+		if err != nil {
+			return &StepError{Step: StepSnapshot, ItemID: id, Err: err}
+		}
+		if field.DataType != survivor.DataType {
+			return ErrIncompatibleFields
+		}
+	}
+	return nil
+}
+
+// prepareFields prepara il merge di campi select: aggiunge al sopravvissuto le opzioni
+// degli assorbiti che non ha e restituisce, per ogni campo assorbito, la conversione
+// dei valori verso le opzioni del sopravvissuto. Può essere ripetuto in ripresa.
+func (e *Executor) prepareFields(ctx context.Context, plan Plan) (map[int]valueMapper, error) {
+	survivor, err := e.client.GetCustomField(ctx, plan.SurvivorID)
+	if err != nil {
+		return nil, &StepError{Step: StepPrepare, ItemID: plan.SurvivorID, Err: err}
+	}
+	if survivor.DataType != paperless.CustomFieldSelect {
+		return nil, nil
+	}
+
+	target, err := parseSelectOptions(survivor.ExtraData)
+	if err != nil {
+		return nil, &StepError{Step: StepPrepare, ItemID: plan.SurvivorID, Err: err}
+	}
+
+	mappers := make(map[int]valueMapper)
+	added := false
+	for _, id := range plan.AbsorbIDs {
+		field, err := e.client.GetCustomField(ctx, id)
+		if paperless.IsNotFound(err) {
+			// Già eliminato in un'esecuzione precedente
+			continue
+		}
+		if err != nil {
+			return nil, &StepError{Step: StepPrepare, ItemID: id, Err: err}
+		}
+
+		source, err := parseSelectOptions(field.ExtraData)
+		if err != nil {
+			return nil, &StepError{Step: StepPrepare, ItemID: id, Err: err}
+		}
+
+		mapping := make([]int, len(source.options))
+		for i, option := range source.options {
+			j := target.find(option.Label)
+			if j < 0 {
+				j = target.add(option.Label)
+				added = true
+			}
+			mapping[i] = j
+		}
+
+		mappers[id] = func(value json.RawMessage) json.RawMessage {
+			i := source.index(value)
+			if i < 0 {
+				return value
+			}
+			return target.value(mapping[i])
+		}
+	}
+
+	if added {
+		extraData, err := target.extraData()
+		if err != nil {
+			return nil, &StepError{Step: StepPrepare, ItemID: plan.SurvivorID, Err: err}
+		}
+		if err := e.client.UpdateCustomFieldExtraData(ctx, plan.SurvivorID, extraData); err != nil {
+			return nil, &StepError{Step: StepPrepare, ItemID: plan.SurvivorID, Err: err}
+		}
+	}
+
+	return mappers, nil
+}
+
+// fieldValue restituisce il valore di un campo su un documento
+func fieldValue(fields []paperless.CustomFieldInstance, fieldID int) (json.RawMessage, bool) {
+	for _, f := range fields {
+		if f.Field == fieldID {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// moveFieldValue sposta il valore del campo oldID sul campo newID. Se il documento
+// ha già un valore per newID viene mantenuto; il campo oldID viene rimosso.
+func moveFieldValue(fields []paperless.CustomFieldInstance, oldID, newID int, mapValue valueMapper) []paperless.CustomFieldInstance {
+	oldValue, _ := fieldValue(fields, oldID)
+	if mapValue != nil {
+		oldValue = mapValue(oldValue)
+	}
+
+	result := make([]paperless.CustomFieldInstance, 0, len(fields))
+	hasNew := false
+	for _, f := range fields {
+		switch f.Field {
+		case oldID:
+			continue
+		case newID:
+			hasNew = true
+			if isNull(f.Value) {
+				f.Value = oldValue
+			}
+		}
+		result = append(result, f)
+	}
+	if !hasNew {
+		result = append(result, paperless.CustomFieldInstance{Field: newID, Value: oldValue})
+	}
+	return result
+}
+
+// moveFieldValues sposta sul sopravvissuto i valori del campo oldID, documento per documento
+// (bulk_edit non permette di impostare valori diversi per documento). Ogni documento viene
+// riletto: l'elenco iniziale non comprende i valori già spostati dagli altri assorbiti.
+func (e *Executor) moveFieldValues(ctx context.Context, docIDs []int, oldID, newID int, mapValue valueMapper, done func(n int)) (int, error) {
+	return e.eachDocument(ctx, docIDs, func(docID int) error {
+		doc, err := e.client.GetDocument(ctx, docID)
+		if err != nil {
+			return err
+		}
+		return e.client.UpdateDocumentCustomFields(ctx, docID, moveFieldValue(doc.CustomFields, oldID, newID, mapValue))
+	}, done)
+}
+
+// restoreFieldValues riporta sul campo ricreato i valori originali registrati nel journal
+// e toglie il sopravvissuto dai documenti che non lo avevano prima del merge (hadSurvivor)
+func (e *Executor) restoreFieldValues(ctx context.Context, absorbed *AbsorbedItem, hadSurvivor map[int]bool, survivorID, restoredID int, done func(n int)) (int, error) {
+	return e.eachDocument(ctx, absorbed.Documents, func(docID int) error {
+		doc, err := e.client.GetDocument(ctx, docID)
+		if err != nil {
+			return err
+		}
+
+		keepSurvivor := hadSurvivor[docID]
+		fields := make([]paperless.CustomFieldInstance, 0, len(doc.CustomFields)+1)
+		for _, f := range doc.CustomFields {
+			if f.Field == restoredID || (f.Field == survivorID && !keepSurvivor) {
+				continue
+			}
+			fields = append(fields, f)
+		}
+		fields = append(fields, paperless.CustomFieldInstance{Field: restoredID, Value: absorbed.Values[docID]})
+
+		return e.client.UpdateDocumentCustomFields(ctx, docID, fields)
+	}, done)
+}
+
+// SelectField è un campo personalizzato di tipo select con le sue opzioni
+type SelectField struct {
+	ID      int
+	Name    string
+	Options []string // Etichette delle opzioni, nell'ordine del server
+	Usage   []int    // Documenti che usano ciascuna opzione
+}
+
+// LoadSelectField legge un campo select e conta i documenti che usano ogni opzione
+func LoadSelectField(ctx context.Context, client *paperless.Client, fieldID int) (SelectField, error) {
+	field, err := client.GetCustomField(ctx, fieldID)
+	if err != nil {
+		return SelectField{}, err
+	}
+	if field.DataType != paperless.CustomFieldSelect {
+		return SelectField{}, ErrNotSelectField
+	}
+
+	options, err := parseSelectOptions(field.ExtraData)
+	if err != nil {
+		return SelectField{}, err
+	}
+
+	docs, err := client.GetDocumentsByCustomField(ctx, fieldID)
+	if err != nil {
+		return SelectField{}, &StepError{Step: StepGetDocuments, ItemID: fieldID, Err: err}
+	}
+
+	usage := make([]int, len(options.options))
+	for _, doc := range docs {
+		value, _ := fieldValue(doc.CustomFields, fieldID)
+		if i := options.index(value); i >= 0 {
+			usage[i]++
+		}
+	}
+
+	return SelectField{ID: field.ID, Name: field.Name, Options: options.labels(), Usage: usage}, nil
+}
+
+// OptionMerge descrive l'unione di opzioni duplicate di un campo select
+type OptionMerge struct {
+	FieldID int
+	Keep    int    // Indice dell'opzione che sopravvive
+	Absorb  []int  // Indici delle opzioni assorbite
+	Label   string // Etichetta dell'opzione sopravvissuta a merge concluso
+}
+
+// NewOptionMerge costruisce l'unione delle opzioni selezionate (indici in field.Options).
+// Se una delle opzioni ha già l'etichetta finale sopravvive lei, altrimenti la prima.
+func NewOptionMerge(field SelectField, selected []int, label string) (OptionMerge, error) {
+	if strings.TrimSpace(label) == "" {
+		return OptionMerge{}, ErrEmptyName
+	}
+
+	var indexes []int
+	for _, i := range selected {
+		if i < 0 || i >= len(field.Options) {
+			return OptionMerge{}, ErrInvalidOption
+		}
+		if !containsID(indexes, i) {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) < 2 {
+		return OptionMerge{}, ErrTooFewItems
+	}
+
+	keep := indexes[0]
+	for _, i := range indexes {
+		if field.Options[i] == label {
+			keep = i
+			break
+		}
+	}
+
+	optionMerge := OptionMerge{FieldID: field.ID, Keep: keep, Label: label}
+	for _, i := range indexes {
+		if i != keep {
+			optionMerge.Absorb = append(optionMerge.Absorb, i)
+		}
+	}
+	return optionMerge, nil
+}
+
+// MergeOptions unisce opzioni duplicate di un campo select: i documenti che usano
+// un'opzione assorbita passano all'opzione sopravvissuta, poi le opzioni assorbite
+// vengono rimosse dal campo. L'operazione non viene registrata nel journal: un annullamento
+// durante lo spostamento dei documenti riporta i documenti già spostati all'opzione originale
+// e l'errore è ErrRolledBack; dopo la modifica delle opzioni l'unione non si ferma più.
+func (e *Executor) MergeOptions(ctx context.Context, m OptionMerge) (Result, error) {
+	var result Result
+	ctx = safeContext(ctx)
+
+	field, err := e.client.GetCustomField(ctx, m.FieldID)
+	if err != nil {
+		return result, &StepError{Step: StepSnapshot, ItemID: m.FieldID, Err: err}
+	}
+	if field.DataType != paperless.CustomFieldSelect {
+		return result, ErrNotSelectField
+	}
+
+	options, err := parseSelectOptions(field.ExtraData)
+	if err != nil {
+		return result, &StepError{Step: StepSnapshot, ItemID: m.FieldID, Err: err}
+	}
+	if m.Keep < 0 || m.Keep >= len(options.options) || containsID(m.Absorb, m.Keep) {
+		return result, ErrInvalidOption
+	}
+	for _, i := range m.Absorb {
+		if i < 0 || i >= len(options.options) {
+			return result, ErrInvalidOption
+		}
+	}
+
+	e.report(Progress{Step: StepGetDocuments, Current: 1, Total: 3, Item: 1, Items: 1})
+
+	docs, err := e.client.GetDocumentsByCustomField(ctx, m.FieldID)
+	if err != nil {
+		return result, &StepError{Step: StepGetDocuments, ItemID: m.FieldID, Err: err}
+	}
+
+	// Fase 1: i documenti con un'opzione assorbita passano all'opzione sopravvissuta
+	// (con i valori attuali, validi finché le opzioni non cambiano)
+	keepValue := options.value(m.Keep)
+	var moved []paperless.Document
+	for _, doc := range docs {
+		value, _ := fieldValue(doc.CustomFields, m.FieldID)
+		if containsID(m.Absorb, options.index(value)) {
+			moved = append(moved, doc)
+		}
+	}
+
+	progress := Progress{Step: StepUpdateDocuments, Current: 2, Total: 3, Item: 1, Items: 1, Documents: len(moved)}
+	e.report(progress)
+
+	fields := make(map[int][]paperless.CustomFieldInstance, len(docs))
+	for _, doc := range docs {
+		fields[doc.ID] = doc.CustomFields
+	}
+	report := e.documentProgress(progress, nil)
+	docID, err := e.eachDocument(ctx, documentIDs(moved), func(docID int) error {
+		return e.client.UpdateDocumentCustomFields(ctx, docID, setFieldValue(fields[docID], m.FieldID, keepValue))
+	}, func(n int) {
+		result.DocumentsMoved += n
+		report(n)
+	})
+	if err != nil && stopped(ctx) == nil {
+		return result, &StepError{Step: StepUpdateDocuments, ItemID: m.FieldID, DocumentID: docID, Err: err}
+	}
+	if stopped(ctx) != nil {
+		e.report(Progress{Step: StepRollback, Current: 2, Total: 3})
+		if err := e.rollbackOptions(ctx, m.FieldID, moved, fields); err != nil {
+			return result, err
+		}
+		return Result{}, ErrRolledBack
+	}
+
+	// Fase 2: rinomina l'opzione sopravvissuta e rimuove quelle assorbite. Da qui i valori dei
+	// documenti dipendono dalle nuove opzioni e l'unione viene completata anche se annullata.
+	ctx = withoutStop(ctx)
+	e.report(Progress{Step: StepFinalName, Current: 3, Total: 3})
+
+	oldIndex := make(map[int]int, len(docs)) // Documento -> opzione prima della rimozione
+	for _, doc := range docs {
+		value, _ := fieldValue(doc.CustomFields, m.FieldID)
+		i := options.index(value)
+		if containsID(m.Absorb, i) {
+			i = m.Keep
+		}
+		oldIndex[doc.ID] = i
+	}
+
+	options.options[m.Keep].Label = m.Label
+	remaining := make([]int, 0, len(options.options))
+	for i := range options.options {
+		if !containsID(m.Absorb, i) {
+			remaining = append(remaining, i)
+		}
+	}
+	options.remove(m.Absorb)
+
+	extraData, err := options.extraData()
+	if err != nil {
+		return result, &StepError{Step: StepFinalName, ItemID: m.FieldID, Err: err}
+	}
+	if err := e.client.UpdateCustomFieldExtraData(ctx, m.FieldID, extraData); err != nil {
+		return result, &StepError{Step: StepFinalName, ItemID: m.FieldID, Err: err}
+	}
+
+	// Fase 3 (solo Paperless fino alla 2.13): i documenti salvano l'indice dell'opzione,
+	// che si sposta quando le opzioni precedenti vengono rimosse
+	if options.legacy {
+		newIndexes := make(map[int]int) // Documento -> opzione dopo la rimozione
+		var shifted []int
+		for _, doc := range docs {
+			old := oldIndex[doc.ID]
+			if old < 0 {
+				continue
+			}
+			newIndex := 0
+			for newIndex < len(remaining) && remaining[newIndex] != old {
+				newIndex++
+			}
+			if newIndex == old {
+				continue
+			}
+			newIndexes[doc.ID] = newIndex
+			shifted = append(shifted, doc.ID)
+		}
+
+		docID, err := e.eachDocument(ctx, shifted, func(docID int) error {
+			return e.client.UpdateDocumentCustomFields(ctx, docID, setFieldValue(fields[docID], m.FieldID, options.value(newIndexes[docID])))
+		}, nil)
+		if err != nil {
+			return result, &StepError{Step: StepUpdateDocuments, ItemID: m.FieldID, DocumentID: docID, Err: err}
+		}
+	}
+
+	return result, nil
+}
+
+// rollbackOptions riporta i documenti spostati da un'unione di opzioni annullata ai valori
+// originali del campo (fields, letti prima dello spostamento). I documenti non ancora
+// spostati hanno ancora quei valori e non vengono toccati.
+func (e *Executor) rollbackOptions(ctx context.Context, fieldID int, moved []paperless.Document, fields map[int][]paperless.CustomFieldInstance) error {
+	ctx = withoutStop(ctx)
+
+	docID, err := e.eachDocument(ctx, documentIDs(moved), func(docID int) error {
+		doc, err := e.client.GetDocument(ctx, docID)
+		if err != nil {
+			return err
+		}
+		value, _ := fieldValue(fields[docID], fieldID)
+		if current, _ := fieldValue(doc.CustomFields, fieldID); string(current) == string(value) {
+			return nil
+		}
+		return e.client.UpdateDocumentCustomFields(ctx, docID, setFieldValue(doc.CustomFields, fieldID, value))
+	}, nil)
+	if err != nil {
+		return &StepError{Step: StepRollback, ItemID: fieldID, DocumentID: docID, Err: err}
+	}
+	return nil
+}
+
+// setFieldValue imposta il valore di un campo lasciando invariati gli altri
+func setFieldValue(fields []paperless.CustomFieldInstance, fieldID int, value json.RawMessage) []paperless.CustomFieldInstance {
+	result := make([]paperless.CustomFieldInstance, len(fields))
+	for i, f := range fields {
+		if f.Field == fieldID {
+			f.Value = value
+		}
+		result[i] = f
+	}
+	return result
+}
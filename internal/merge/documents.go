@@ -0,0 +1,248 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// bulkChunkSize è il numero di documenti aggiornati con ogni chiamata a bulk_edit
+const bulkChunkSize = 100
+
+// DefaultWorkers è il numero predefinito di documenti aggiornati contemporaneamente
+// quando il server non supporta bulk_edit
+const DefaultWorkers = 4
+
+// maxListedErrors è il numero massimo di documenti elencati nel messaggio di DocumentErrors
+const maxListedErrors = 3
+
+// DocumentError è l'errore nell'aggiornamento di un singolo documento
+type DocumentError struct {
+	DocumentID int
+	Err        error
+}
+
+func (e DocumentError) Error() string {
+	return fmt.Sprintf("documento %d: %v", e.DocumentID, e.Err)
+}
+
+func (e DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// DocumentErrors raccoglie gli errori dei documenti non aggiornati, in ordine di ID
+type DocumentErrors []DocumentError
+
+func (e DocumentErrors) Error() string {
+	parts := make([]string, 0, maxListedErrors+1)
+	for i, docErr := range e {
+		if i == maxListedErrors {
+			parts = append(parts, fmt.Sprintf("e altri %d", len(e)-i))
+			break
+		}
+		parts = append(parts, docErr.Error())
+	}
+	return fmt.Sprintf("%d documenti non aggiornati: %s", len(e), strings.Join(parts, "; "))
+}
+
+func (e DocumentErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, docErr := range e {
+		errs[i] = docErr
+	}
+	return errs
+}
+
+// SetWorkers imposta quanti documenti aggiornare contemporaneamente quando il server
+// non supporta bulk_edit (0 per il valore predefinito)
+func (e *Executor) SetWorkers(workers int) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	e.workers = workers
+}
+
+// documentProgress restituisce la funzione che riporta l'avanzamento della fase p
+// documento per documento. Se current non è nil i documenti aggiornati vengono
+// contati anche tra le operazioni del merge.
+func (e *Executor) documentProgress(p Progress, current *int) func(n int) {
+	return func(n int) {
+		p.Document += n
+		if current != nil {
+			*current += n
+			p.Current = *current
+		}
+		e.report(p)
+	}
+}
+
+// updateDocuments aggiorna i documenti a blocchi tramite bulk; se il server non supporta
+// bulk_edit passa (per il resto dell'esecuzione) all'aggiornamento dei singoli documenti.
+// done, se non nil, riceve il numero di documenti aggiornati man mano che procedono.
+// In caso di errore restituisce il primo documento del blocco (o il documento) fallito;
+// se falliscono più documenti l'errore è DocumentErrors e il documento è 0.
+func (e *Executor) updateDocuments(ctx context.Context, docIDs []int, bulk func(chunk []int) error, single func(docID int) error, done func(n int)) (int, error) {
+	for start := 0; start < len(docIDs); start += bulkChunkSize {
+		// Un annullamento si ferma tra un blocco e l'altro
+		if err := stopped(ctx); err != nil {
+			return 0, err
+		}
+		end := start + bulkChunkSize
+		if end > len(docIDs) {
+			end = len(docIDs)
+		}
+		chunk := docIDs[start:end]
+
+		if !e.perDocument {
+			err := bulk(chunk)
+			if err == nil {
+				if done != nil {
+					done(len(chunk))
+				}
+				continue
+			}
+			if !errors.Is(err, paperless.ErrBulkEditUnsupported) {
+				return chunk[0], err
+			}
+			e.perDocument = true
+		}
+
+		// Senza bulk_edit i documenti rimasti vengono aggiornati tutti insieme dal pool
+		return e.eachDocument(ctx, docIDs[start:], single, done)
+	}
+
+	return 0, nil
+}
+
+// eachDocument esegue update su ogni documento con un numero limitato di aggiornamenti
+// contemporanei. Un documento fallito non ferma gli altri: gli errori vengono raccolti
+// e restituiti insieme alla fine, come in updateDocuments.
+func (e *Executor) eachDocument(ctx context.Context, docIDs []int, update func(docID int) error, done func(n int)) (int, error) {
+	workers := e.workers
+	// In dry-run le richieste simulate restano nell'ordine dei documenti
+	if e.client.DryRun {
+		workers = 1
+	}
+	if workers > len(docIDs) {
+		workers = len(docIDs)
+	}
+
+	jobs := make(chan int)
+	results := make(chan DocumentError)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for docID := range jobs {
+				results <- DocumentError{DocumentID: docID, Err: update(docID)}
+			}
+		}()
+	}
+
+	// Dopo un annullamento non vengono avviati altri aggiornamenti
+	go func() {
+		defer close(jobs)
+		for _, docID := range docIDs {
+			select {
+			case jobs <- docID:
+			case <-stopRequested(ctx):
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// L'avanzamento viene riportato da questa goroutine, una sola alla volta
+	var failed DocumentErrors
+	for result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+			continue
+		}
+		if done != nil {
+			done(1)
+		}
+	}
+
+	if err := stopped(ctx); err != nil {
+		return 0, err
+	}
+	switch len(failed) {
+	case 0:
+		return 0, nil
+	case 1:
+		return failed[0].DocumentID, failed[0].Err
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].DocumentID < failed[j].DocumentID
+	})
+	return 0, failed
+}
+
+// moveDocuments sposta i documenti dall'elemento oldID all'elemento newID
+func (e *Executor) moveDocuments(ctx context.Context, kind Kind, docIDs []int, oldID, newID int, done func(n int)) (int, error) {
+	return e.updateDocuments(ctx, docIDs,
+		func(chunk []int) error {
+			switch kind {
+			case KindTags:
+				return e.client.BulkModifyTags(ctx, chunk, []int{newID}, []int{oldID})
+			case KindCorrespondents:
+				return e.client.BulkSetCorrespondent(ctx, chunk, newID)
+			case KindDocumentTypes:
+				return e.client.BulkSetDocumentType(ctx, chunk, newID)
+			case KindStoragePaths:
+				return e.client.BulkSetStoragePath(ctx, chunk, newID)
+			}
+			return paperless.ErrBulkEditUnsupported
+		},
+		func(docID int) error {
+			return reassignDocument(ctx, e.client, kind, docID, oldID, newID)
+		},
+		done)
+}
+
+// restoreDocuments riporta i documenti dal sopravvissuto all'elemento ricreato.
+// keepSurvivor indica (per i tag) che i documenti avevano già il sopravvissuto prima del merge.
+func (e *Executor) restoreDocuments(ctx context.Context, kind Kind, docIDs []int, survivorID, restoredID int, keepSurvivor bool, done func(n int)) (int, error) {
+	return e.updateDocuments(ctx, docIDs,
+		func(chunk []int) error {
+			switch kind {
+			case KindTags:
+				if keepSurvivor {
+					return e.client.BulkModifyTags(ctx, chunk, []int{restoredID}, nil)
+				}
+				return e.client.BulkModifyTags(ctx, chunk, []int{restoredID}, []int{survivorID})
+			case KindCorrespondents:
+				return e.client.BulkSetCorrespondent(ctx, chunk, restoredID)
+			case KindDocumentTypes:
+				return e.client.BulkSetDocumentType(ctx, chunk, restoredID)
+			case KindStoragePaths:
+				return e.client.BulkSetStoragePath(ctx, chunk, restoredID)
+			}
+			return paperless.ErrBulkEditUnsupported
+		},
+		func(docID int) error {
+			return restoreDocument(ctx, e.client, kind, docID, survivorID, restoredID, keepSurvivor)
+		},
+		done)
+}
+
+// documentIDs estrae gli ID da una lista di documenti
+func documentIDs(docs []paperless.Document) []int {
+	ids := make([]int, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids
+}
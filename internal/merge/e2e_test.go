@@ -0,0 +1,255 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/paperless/fake"
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// Gli elementi delle fixture end to end: il sopravvissuto 1, gli assorbiti 2 e 3
+// e un elemento estraneo 4
+var e2eNames = []string{"Acme", "ACME Srl", "Acme S.r.l.", "Altro"}
+
+// e2eEndpoints sono i percorsi dell'API per tipo di elemento
+var e2eEndpoints = map[Kind]string{
+	KindTags:           "tags",
+	KindCorrespondents: "correspondents",
+	KindDocumentTypes:  "document_types",
+	KindStoragePaths:   "storage_paths",
+	KindCustomFields:   "custom_fields",
+}
+
+// e2eFixture costruisce la fixture per il tipo indicato. I documenti hanno:
+// 10 il primo assorbito, 11 il secondo (e per tag e campi l'estraneo),
+// 12 il sopravvissuto (e per tag e campi il primo assorbito), 13 l'estraneo.
+func e2eFixture(kind Kind, disableBulkEdit bool) *fake.Fixture {
+	fixture := &fake.Fixture{DisableBulkEdit: disableBulkEdit}
+	for i, name := range e2eNames {
+		id := i + 1
+		switch kind {
+		case KindTags:
+			fixture.Tags = append(fixture.Tags, paperless.Tag{ID: id, Name: name})
+		case KindCorrespondents:
+			fixture.Correspondents = append(fixture.Correspondents, paperless.Correspondent{ID: id, Name: name})
+		case KindDocumentTypes:
+			fixture.DocumentTypes = append(fixture.DocumentTypes, paperless.DocumentType{ID: id, Name: name})
+		case KindStoragePaths:
+			fixture.StoragePaths = append(fixture.StoragePaths, paperless.StoragePath{ID: id, Name: name, Path: "{title}"})
+		case KindCustomFields:
+			fixture.CustomFields = append(fixture.CustomFields, paperless.CustomField{ID: id, Name: name, DataType: "string"})
+		}
+	}
+
+	refs := map[int][]int{10: {2}, 11: {3, 4}, 12: {1, 2}, 13: {4}}
+	if kind != KindTags && kind != KindCustomFields {
+		refs = map[int][]int{10: {2}, 11: {3}, 12: {1}, 13: {4}}
+	}
+	for docID := 10; docID <= 13; docID++ {
+		doc := paperless.Document{ID: docID, Title: fmt.Sprintf("Documento %d", docID)}
+		id := refs[docID][0]
+		switch kind {
+		case KindTags:
+			doc.Tags = refs[docID]
+		case KindCorrespondents:
+			doc.Correspondent = &id
+		case KindDocumentTypes:
+			doc.DocumentType = &id
+		case KindStoragePaths:
+			doc.StoragePath = &id
+		case KindCustomFields:
+			for _, fieldID := range refs[docID] {
+				value := fmt.Sprintf(`"%d-%d"`, docID, fieldID)
+				doc.CustomFields = append(doc.CustomFields, paperless.CustomFieldInstance{Field: fieldID, Value: []byte(value)})
+			}
+		}
+		fixture.Documents = append(fixture.Documents, doc)
+	}
+	return fixture
+}
+
+// documentRefs descrive per ogni documento gli elementi del tipo indicato con il loro
+// nome, così che il confronto non dipenda dagli ID assegnati agli elementi ricreati.
+// Per i campi personalizzati ogni nome è seguito dal valore.
+func documentRefs(t *testing.T, client *paperless.Client, kind Kind) map[int]string {
+	t.Helper()
+
+	ctx := context.Background()
+	items, err := listItems(ctx, client, kind)
+	if err != nil {
+		t.Fatalf("listItems: %v", err)
+	}
+	names := make(map[int]string, len(items))
+	for _, item := range items {
+		names[item.ID] = item.Name
+	}
+
+	refs := make(map[int]string)
+	for docID := 10; docID <= 13; docID++ {
+		doc, err := client.GetDocument(ctx, docID)
+		if err != nil {
+			t.Fatalf("GetDocument(%d): %v", docID, err)
+		}
+		var parts []string
+		switch kind {
+		case KindTags:
+			for _, id := range doc.Tags {
+				parts = append(parts, names[id])
+			}
+		case KindCustomFields:
+			for _, f := range doc.CustomFields {
+				parts = append(parts, names[f.Field]+"="+string(f.Value))
+			}
+		default:
+			if id := documentValue(*doc, kind); id != nil {
+				parts = append(parts, names[*id])
+			}
+		}
+		sort.Strings(parts)
+		refs[docID] = strings.Join(parts, ", ")
+	}
+	return refs
+}
+
+// e2eMerged restituisce lo stato atteso dei documenti a merge concluso
+func e2eMerged(kind Kind) map[int]string {
+	switch kind {
+	case KindTags:
+		return map[int]string{10: "Acme", 11: "Acme, Altro", 12: "Acme", 13: "Altro"}
+	case KindCustomFields:
+		// Sul documento 12 resta il valore del sopravvissuto
+		return map[int]string{10: `Acme="10-2"`, 11: `Acme="11-3", Altro="11-4"`, 12: `Acme="12-1"`, 13: `Altro="13-4"`}
+	}
+	return map[int]string{10: "Acme", 11: "Acme", 12: "Acme", 13: "Altro"}
+}
+
+// failOnce fa fallire con un 500 la prima richiesta con metodo e percorso indicati,
+// come un server che cade a metà merge
+type failOnce struct {
+	handler      http.Handler
+	method, path string
+
+	mu     sync.Mutex
+	failed bool
+}
+
+func (f *failOnce) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	fail := !f.failed && r.Method == f.method && r.URL.Path == f.path
+	if fail {
+		f.failed = true
+	}
+	f.mu.Unlock()
+
+	if fail {
+		http.Error(w, "errore simulato", http.StatusInternalServerError)
+		return
+	}
+	f.handler.ServeHTTP(w, r)
+}
+
+func TestMergeUndoResumeEndToEnd(t *testing.T) {
+	kinds := []Kind{KindTags, KindCorrespondents, KindDocumentTypes, KindStoragePaths, KindCustomFields}
+	for _, kind := range kinds {
+		for _, mode := range bulkModes {
+			for _, interrupted := range []bool{false, true} {
+				name := fmt.Sprintf("%s/%s/completo", e2eEndpoints[kind], mode.name)
+				if interrupted {
+					name = fmt.Sprintf("%s/%s/ripreso", e2eEndpoints[kind], mode.name)
+				}
+				t.Run(name, func(t *testing.T) {
+					testMergeUndoResume(t, kind, mode.disableBulkEdit, interrupted)
+				})
+			}
+		}
+	}
+}
+
+// testMergeUndoResume esegue un merge (interrotto e ripreso se richiesto), ne verifica
+// il risultato, lo annulla e verifica che i documenti tornino come prima
+func testMergeUndoResume(t *testing.T, kind Kind, disableBulkEdit, interrupted bool) {
+	ctx := context.Background()
+
+	srv, err := fake.NewServer(e2eFixture(kind, disableBulkEdit))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	var handler http.Handler = srv
+	if interrupted {
+		// L'eliminazione del primo assorbito fallisce: i documenti del secondo non
+		// sono ancora stati spostati
+		handler = &failOnce{handler: srv, method: http.MethodDelete, path: fmt.Sprintf("/api/%s/2/", e2eEndpoints[kind])}
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	client := paperless.NewClient(ts.URL, fake.DefaultToken)
+
+	store := NewJournalStore(t.TempDir())
+	executor := NewExecutor(client, store, nil)
+	original := documentRefs(t, client, kind)
+
+	plan, err := NewPlan(kind, []similarity.SimilarItem{
+		{ID: 1, Name: e2eNames[0]},
+		{ID: 2, Name: e2eNames[1]},
+		{ID: 3, Name: e2eNames[2]},
+	}, 1, e2eNames[0])
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	result, err := executor.Execute(ctx, plan)
+	if interrupted {
+		if err == nil {
+			t.Fatal("Execute: il merge interrotto non ha restituito errori")
+		}
+		unfinished, err := store.Unfinished()
+		if err != nil {
+			t.Fatalf("Unfinished: %v", err)
+		}
+		if len(unfinished) != 1 {
+			t.Fatalf("%d merge interrotti nel journal, atteso 1", len(unfinished))
+		}
+		if result, err = executor.Resume(ctx, unfinished[0]); err != nil {
+			t.Fatalf("Resume: %v", err)
+		}
+	} else if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(result.Discrepancies) > 0 {
+		t.Fatalf("discrepanze dopo il merge: %v", result.Discrepancies)
+	}
+
+	if got, merged := documentRefs(t, client, kind), e2eMerged(kind); !reflect.DeepEqual(got, merged) {
+		t.Errorf("dopo il merge: documenti %v, attesi %v", got, merged)
+	}
+	if got := itemNames(t, client, kind); !reflect.DeepEqual(got, []string{"Acme", "Altro"}) {
+		t.Errorf("dopo il merge: elementi %v, attesi solo sopravvissuto ed estraneo", got)
+	}
+
+	journal, err := store.LastUndoable()
+	if err != nil {
+		t.Fatalf("LastUndoable: %v", err)
+	}
+	if err := executor.Undo(ctx, journal); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	// Gli elementi ricreati hanno nuovi ID ma gli stessi nomi
+	if got := documentRefs(t, client, kind); !reflect.DeepEqual(got, original) {
+		t.Errorf("dopo l'undo: documenti %v, attesi %v", got, original)
+	}
+	want := append([]string{}, e2eNames...)
+	sort.Strings(want)
+	if got := itemNames(t, client, kind); !reflect.DeepEqual(got, want) {
+		t.Errorf("dopo l'undo: elementi %v, attesi %v", got, want)
+	}
+}
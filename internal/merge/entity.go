@@ -0,0 +1,312 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// renameItem rinomina un elemento del tipo indicato
+func renameItem(ctx context.Context, client *paperless.Client, kind Kind, id int, name string) error {
+	switch kind {
+	case KindTags:
+		return client.UpdateTag(ctx, id, name)
+	case KindCorrespondents:
+		return client.UpdateCorrespondent(ctx, id, name)
+	case KindDocumentTypes:
+		return client.UpdateDocumentType(ctx, id, name)
+	case KindStoragePaths:
+		return client.UpdateStoragePath(ctx, id, name)
+	case KindCustomFields:
+		return client.UpdateCustomField(ctx, id, name)
+	}
+	return fmt.Errorf("tipo di entità non supportato: %d", kind)
+}
+
+// deleteItem elimina un elemento del tipo indicato
+func deleteItem(ctx context.Context, client *paperless.Client, kind Kind, id int) error {
+	switch kind {
+	case KindTags:
+		return client.DeleteTag(ctx, id)
+	case KindCorrespondents:
+		return client.DeleteCorrespondent(ctx, id)
+	case KindDocumentTypes:
+		return client.DeleteDocumentType(ctx, id)
+	case KindStoragePaths:
+		return client.DeleteStoragePath(ctx, id)
+	case KindCustomFields:
+		return client.DeleteCustomField(ctx, id)
+	}
+	return fmt.Errorf("tipo di entità non supportato: %d", kind)
+}
+
+// itemDocuments recupera i documenti che usano un elemento
+func itemDocuments(ctx context.Context, client *paperless.Client, kind Kind, id int) ([]paperless.Document, error) {
+	switch kind {
+	case KindTags:
+		return client.GetDocumentsByTag(ctx, id)
+	case KindCorrespondents:
+		return client.GetDocumentsByCorrespondent(ctx, id)
+	case KindDocumentTypes:
+		return client.GetDocumentsByType(ctx, id)
+	case KindStoragePaths:
+		return client.GetDocumentsByStoragePath(ctx, id)
+	case KindCustomFields:
+		return client.GetDocumentsByCustomField(ctx, id)
+	}
+	return nil, fmt.Errorf("tipo di entità non supportato: %d", kind)
+}
+
+// reassignDocument sposta un documento dall'elemento oldID all'elemento newID.
+// Per i tag il nuovo tag viene aggiunto come fa modify_tags di bulk_edit, sullo stato
+// attuale del documento: un documento con più tag assorbiti li perde tutti senza
+// ricevere il sopravvissuto due volte.
+func reassignDocument(ctx context.Context, client *paperless.Client, kind Kind, docID, oldID, newID int) error {
+	switch kind {
+	case KindTags:
+		return client.ModifyDocumentTags(ctx, docID, []int{newID}, []int{oldID})
+	case KindCorrespondents:
+		return client.UpdateDocumentCorrespondent(ctx, docID, newID)
+	case KindDocumentTypes:
+		return client.UpdateDocumentTypeForDoc(ctx, docID, newID)
+	case KindStoragePaths:
+		return client.UpdateDocumentStoragePath(ctx, docID, newID)
+	case KindCustomFields:
+		doc, err := client.GetDocument(ctx, docID)
+		if err != nil {
+			return err
+		}
+		return client.UpdateDocumentCustomFields(ctx, docID, moveFieldValue(doc.CustomFields, oldID, newID, nil))
+	}
+	return fmt.Errorf("tipo di entità non supportato: %d", kind)
+}
+
+// listItems recupera tutti gli elementi del tipo indicato
+func listItems(ctx context.Context, client *paperless.Client, kind Kind) ([]Item, error) {
+	var items []Item
+
+	switch kind {
+	case KindTags:
+		tags, err := client.GetTags(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			items = append(items, tagItem(tag))
+		}
+
+	case KindCorrespondents:
+		correspondents, err := client.GetCorrespondents(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, corr := range correspondents {
+			items = append(items, Item{ID: corr.ID, Name: corr.Name, Match: corr.Match, MatchingAlgorithm: corr.MatchingAlgorithm, IsInsensitive: corr.IsInsensitive, Owner: corr.Owner, DocumentCount: corr.DocumentCount})
+		}
+
+	case KindDocumentTypes:
+		docTypes, err := client.GetDocumentTypes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, dt := range docTypes {
+			items = append(items, Item{ID: dt.ID, Name: dt.Name, Match: dt.Match, MatchingAlgorithm: dt.MatchingAlgorithm, IsInsensitive: dt.IsInsensitive, Owner: dt.Owner, DocumentCount: dt.DocumentCount})
+		}
+
+	case KindStoragePaths:
+		storagePaths, err := client.GetStoragePaths(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, sp := range storagePaths {
+			items = append(items, Item{ID: sp.ID, Name: sp.Name, Path: sp.Path, Match: sp.Match, MatchingAlgorithm: sp.MatchingAlgorithm, IsInsensitive: sp.IsInsensitive, Owner: sp.Owner, DocumentCount: sp.DocumentCount})
+		}
+
+	case KindCustomFields:
+		fields, err := client.GetCustomFields(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			items = append(items, Item{ID: field.ID, Name: field.Name, DataType: field.DataType, ExtraData: field.ExtraData})
+		}
+
+	default:
+		return nil, fmt.Errorf("tipo di entità non supportato: %d", kind)
+	}
+
+	return items, nil
+}
+
+// fetchItem recupera lo stato corrente di un elemento
+func fetchItem(ctx context.Context, client *paperless.Client, kind Kind, id int) (Item, error) {
+	switch kind {
+	case KindTags:
+		tag, err := client.GetTag(ctx, id)
+		if err != nil {
+			return Item{}, err
+		}
+		return tagItem(*tag), nil
+
+	case KindCorrespondents:
+		corr, err := client.GetCorrespondent(ctx, id)
+		if err != nil {
+			return Item{}, err
+		}
+		return Item{
+			ID:                corr.ID,
+			Name:              corr.Name,
+			Match:             corr.Match,
+			MatchingAlgorithm: corr.MatchingAlgorithm,
+			IsInsensitive:     corr.IsInsensitive,
+			Owner:             corr.Owner,
+		}, nil
+
+	case KindDocumentTypes:
+		docType, err := client.GetDocumentType(ctx, id)
+		if err != nil {
+			return Item{}, err
+		}
+		return Item{
+			ID:                docType.ID,
+			Name:              docType.Name,
+			Match:             docType.Match,
+			MatchingAlgorithm: docType.MatchingAlgorithm,
+			IsInsensitive:     docType.IsInsensitive,
+			Owner:             docType.Owner,
+		}, nil
+
+	case KindStoragePaths:
+		storagePath, err := client.GetStoragePath(ctx, id)
+		if err != nil {
+			return Item{}, err
+		}
+		return Item{
+			ID:                storagePath.ID,
+			Name:              storagePath.Name,
+			Path:              storagePath.Path,
+			Match:             storagePath.Match,
+			MatchingAlgorithm: storagePath.MatchingAlgorithm,
+			IsInsensitive:     storagePath.IsInsensitive,
+			Owner:             storagePath.Owner,
+		}, nil
+
+	case KindCustomFields:
+		field, err := client.GetCustomField(ctx, id)
+		if err != nil {
+			return Item{}, err
+		}
+		return Item{
+			ID:        field.ID,
+			Name:      field.Name,
+			DataType:  field.DataType,
+			ExtraData: field.ExtraData,
+		}, nil
+	}
+	return Item{}, fmt.Errorf("tipo di entità non supportato: %d", kind)
+}
+
+// tagItem fotografa un tag
+func tagItem(tag paperless.Tag) Item {
+	return Item{
+		ID:                tag.ID,
+		Name:              tag.Name,
+		Color:             tag.Color,
+		Match:             tag.Match,
+		MatchingAlgorithm: tag.MatchingAlgorithm,
+		IsInsensitive:     tag.IsInsensitive,
+		IsInboxTag:        tag.IsInboxTag,
+		Owner:             tag.Owner,
+		Parent:            tag.Parent,
+		DocumentCount:     tag.DocumentCount,
+	}
+}
+
+// createItem ricrea un elemento a partire dalla sua fotografia e restituisce il nuovo ID
+func createItem(ctx context.Context, client *paperless.Client, kind Kind, item Item) (int, error) {
+	switch kind {
+	case KindTags:
+		tag, err := client.CreateTag(ctx, paperless.Tag{
+			Name:              item.Name,
+			Color:             item.Color,
+			Match:             item.Match,
+			MatchingAlgorithm: item.MatchingAlgorithm,
+			IsInsensitive:     item.IsInsensitive,
+			IsInboxTag:        item.IsInboxTag,
+			Owner:             item.Owner,
+			Parent:            item.Parent,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return tag.ID, nil
+
+	case KindCorrespondents:
+		corr, err := client.CreateCorrespondent(ctx, paperless.Correspondent{
+			Name:              item.Name,
+			Match:             item.Match,
+			MatchingAlgorithm: item.MatchingAlgorithm,
+			IsInsensitive:     item.IsInsensitive,
+			Owner:             item.Owner,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return corr.ID, nil
+
+	case KindDocumentTypes:
+		docType, err := client.CreateDocumentType(ctx, paperless.DocumentType{
+			Name:              item.Name,
+			Match:             item.Match,
+			MatchingAlgorithm: item.MatchingAlgorithm,
+			IsInsensitive:     item.IsInsensitive,
+			Owner:             item.Owner,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return docType.ID, nil
+
+	case KindStoragePaths:
+		storagePath, err := client.CreateStoragePath(ctx, paperless.StoragePath{
+			Name:              item.Name,
+			Path:              item.Path,
+			Match:             item.Match,
+			MatchingAlgorithm: item.MatchingAlgorithm,
+			IsInsensitive:     item.IsInsensitive,
+			Owner:             item.Owner,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return storagePath.ID, nil
+
+	case KindCustomFields:
+		field, err := client.CreateCustomField(ctx, paperless.CustomField{
+			Name:      item.Name,
+			DataType:  item.DataType,
+			ExtraData: item.ExtraData,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return field.ID, nil
+	}
+	return 0, fmt.Errorf("tipo di entità non supportato: %d", kind)
+}
+
+// restoreDocument riporta un documento dal sopravvissuto all'elemento ricreato.
+// keepSurvivor indica (per i tag) che il documento aveva già il sopravvissuto prima del merge.
+// Il tag ricreato viene sempre aggiunto, anche se il ripristino di un altro elemento
+// assorbito ha già tolto il sopravvissuto dal documento.
+func restoreDocument(ctx context.Context, client *paperless.Client, kind Kind, docID, survivorID, restoredID int, keepSurvivor bool) error {
+	if kind == KindTags {
+		var remove []int
+		if !keepSurvivor {
+			remove = []int{survivorID}
+		}
+		return client.ModifyDocumentTags(ctx, docID, []int{restoredID}, remove)
+	}
+	return reassignDocument(ctx, client, kind, docID, survivorID, restoredID)
+}
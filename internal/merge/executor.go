@@ -0,0 +1,457 @@
+package merge
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// Executor esegue i piani di merge tramite il client Paperless
+type Executor struct {
+	client      *paperless.Client
+	journal     *JournalStore
+	reporter    Reporter
+	perDocument bool // true se il server non supporta bulk_edit
+	workers     int  // Documenti aggiornati contemporaneamente senza bulk_edit
+}
+
+// NewExecutor crea un nuovo executor. journal e reporter possono essere nil:
+// senza journal i merge non vengono registrati e non possono essere annullati.
+func NewExecutor(client *paperless.Client, journal *JournalStore, reporter Reporter) *Executor {
+	return &Executor{
+		client:   client,
+		journal:  journal,
+		reporter: reporter,
+		workers:  DefaultWorkers,
+	}
+}
+
+// report inoltra l'avanzamento al reporter, se presente
+func (e *Executor) report(p Progress) {
+	if e.reporter != nil {
+		e.reporter.Report(p)
+	}
+}
+
+// saveJournal salva il journal, se il journal è attivo
+func (e *Executor) saveJournal(j *Journal) error {
+	if e.journal == nil || j == nil {
+		return nil
+	}
+	if err := e.journal.Save(j); err != nil {
+		return &StepError{Step: StepJournal, Err: err}
+	}
+	return nil
+}
+
+// startJournal fotografa gli elementi del piano e registra l'inizio del merge
+func (e *Executor) startJournal(ctx context.Context, plan Plan) (*Journal, error) {
+	if e.journal == nil {
+		return nil, nil
+	}
+
+	j := newJournal(plan)
+
+	survivor, err := fetchItem(ctx, e.client, plan.Kind, plan.SurvivorID)
+	if err != nil {
+		return nil, &StepError{Step: StepSnapshot, ItemID: plan.SurvivorID, Err: err}
+	}
+	j.Survivor = survivor
+
+	for _, id := range plan.AbsorbIDs {
+		item, err := fetchItem(ctx, e.client, plan.Kind, id)
+		if err != nil {
+			return nil, &StepError{Step: StepSnapshot, ItemID: id, Err: err}
+		}
+		j.Absorbed = append(j.Absorbed, AbsorbedItem{Item: item})
+	}
+
+	if err := e.saveJournal(j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Execute esegue il piano: rinomina temporaneamente il sopravvissuto (se serve),
+// sposta i documenti di ogni elemento assorbito, elimina gli assorbiti e
+// infine assegna al sopravvissuto il nome finale, la regola di matching unita e
+// gli attributi presi dagli altri elementi
+func (e *Executor) Execute(ctx context.Context, plan Plan) (Result, error) {
+	var result Result
+	ctx = safeContext(ctx)
+
+	if plan.FinalName == "" {
+		return result, ErrEmptyName
+	}
+	if len(plan.AbsorbIDs) == 0 {
+		return result, ErrTooFewItems
+	}
+	if plan.Kind == KindCustomFields {
+		if err := checkFieldTypes(ctx, e.client, plan); err != nil {
+			return result, err
+		}
+	}
+
+	// Il journal viene scritto prima di qualsiasi modifica
+	journal, err := e.startJournal(ctx, plan)
+	if err != nil {
+		return result, err
+	}
+
+	result, err = e.run(ctx, plan, journal, false)
+	return e.finish(journal, result, err)
+}
+
+// Resume riprende un merge interrotto dal punto in cui il journal si è fermato:
+// gli elementi già eliminati vengono saltati e i documenti rimasti sugli altri
+// vengono spostati sul sopravvissuto. Tutte le fasi possono essere ripetute senza danni.
+func (e *Executor) Resume(ctx context.Context, j *Journal) (Result, error) {
+	if !j.Unfinished() {
+		return Result{}, ErrNothingToResume
+	}
+	ctx = safeContext(ctx)
+
+	j.Status = StatusRunning
+	j.Error = ""
+	if err := e.saveJournal(j); err != nil {
+		return Result{}, err
+	}
+
+	if j.Cleanup {
+		cleanup, err := e.runCleanup(ctx, j)
+		result := Result{Deleted: itemIDs(cleanup.Deleted)}
+		return e.finish(j, result, err)
+	}
+
+	result, err := e.run(ctx, j.Plan, j, true)
+	return e.finish(j, result, err)
+}
+
+// finish registra nel journal l'esito del merge
+func (e *Executor) finish(journal *Journal, result Result, err error) (Result, error) {
+	if journal == nil {
+		return result, err
+	}
+
+	result.JournalID = journal.ID
+	return result, e.closeJournal(journal, err)
+}
+
+// closeJournal registra nel journal l'esito dell'operazione e restituisce l'errore
+// dell'operazione o, se non c'è, quello del salvataggio
+func (e *Executor) closeJournal(journal *Journal, err error) error {
+	journal.Status = StatusCompleted
+	journal.Error = ""
+	if err != nil {
+		journal.Status = StatusFailed
+		journal.Error = err.Error()
+	}
+	if saveErr := e.saveJournal(journal); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// run esegue le fasi del merge aggiornando il journal (se presente).
+// resume indica che si sta riprendendo un merge interrotto.
+func (e *Executor) run(ctx context.Context, plan Plan, journal *Journal, resume bool) (Result, error) {
+	var result Result
+
+	// 2 operazioni per elemento (get, delete) più una per ogni documento da aggiornare,
+	// aggiunte quando i documenti sono noti, e preparazione e nome finale se serve rinominare
+	current := 0
+	total := len(plan.AbsorbIDs) * 2
+	if plan.Rename {
+		total += 2
+	}
+	total++ // Riferimenti in viste salvate, workflow e regole mail
+	if plan.Matching != nil {
+		total++
+	}
+	if len(plan.Sources) > 0 {
+		total++
+	}
+	// In dry-run non c'è nulla da verificare
+	verify := !e.client.DryRun
+	if verify {
+		total++
+	}
+
+	// I documenti del sopravvissuto prima degli spostamenti servono alla verifica finale
+	var survivorDocs []int
+	if verify {
+		docs, err := itemDocuments(ctx, e.client, plan.Kind, plan.SurvivorID)
+		if err != nil {
+			return result, &StepError{Step: StepGetDocuments, ItemID: plan.SurvivorID, Err: err}
+		}
+		survivorDocs = documentIDs(docs)
+	}
+	movedDocs := make([][]int, len(plan.AbsorbIDs))
+
+	// I valori degli attributi scelti vanno letti prima che gli assorbiti vengano eliminati
+	var attributes Item
+	if len(plan.Sources) > 0 {
+		var err error
+		if attributes, err = e.attributeValues(ctx, plan, journal); err != nil {
+			return result, err
+		}
+	}
+
+	// I valori dei select assorbiti vanno convertiti nelle opzioni del sopravvissuto
+	var mappers map[int]valueMapper
+	if plan.Kind == KindCustomFields {
+		var err error
+		if mappers, err = e.prepareFields(ctx, plan); err != nil {
+			return result, err
+		}
+	}
+
+	// Se il nome finale non appartiene al sopravvissuto, liberalo con un nome temporaneo
+	// (il nome finale potrebbe essere quello di un elemento da eliminare)
+	if plan.Rename {
+		current++
+		e.report(Progress{Step: StepPrepare, Current: current, Total: total})
+
+		if err := renameItem(ctx, e.client, plan.Kind, plan.SurvivorID, plan.TempName()); err != nil {
+			return result, &StepError{Step: StepPrepare, ItemID: plan.SurvivorID, Err: err}
+		}
+	}
+
+	// Viste salvate, workflow e regole mail passano al sopravvissuto prima che
+	// l'eliminazione degli assorbiti li lasci senza riferimento
+	current++
+	e.report(Progress{Step: StepReferences, Current: current, Total: total})
+
+	refs, err := e.rewriteReferences(ctx, plan, journal)
+	if err != nil {
+		return result, err
+	}
+	result.References = refs
+
+	// Step 1: Recupero dei documenti di tutti gli elementi assorbiti, così il totale
+	// comprende un'operazione per ogni documento da aggiornare. Il journal registra quindi
+	// lo stato precedente al merge, su cui si basa l'undo; gli aggiornamenti dei singoli
+	// documenti partono invece dal loro stato attuale.
+	absorbedDocs := make([][]paperless.Document, len(plan.AbsorbIDs))
+	for idx, oldID := range plan.AbsorbIDs {
+		if err := stopped(ctx); err != nil {
+			return result, err
+		}
+
+		// In ripresa gli elementi già eliminati sono completi
+		if journal != nil && resume && !journal.Absorbed[idx].Deleted {
+			// L'eliminazione potrebbe essere avvenuta senza che il journal sia stato aggiornato
+			if _, err := fetchItem(ctx, e.client, plan.Kind, oldID); paperless.IsNotFound(err) {
+				journal.Absorbed[idx].Deleted = true
+			}
+		}
+		current++
+		if journal != nil && journal.Absorbed[idx].Deleted {
+			continue
+		}
+
+		e.report(Progress{Step: StepGetDocuments, Current: current, Total: total, Item: idx + 1, Items: len(plan.AbsorbIDs)})
+
+		docs, err := itemDocuments(ctx, e.client, plan.Kind, oldID)
+		if err != nil {
+			return result, &StepError{Step: StepGetDocuments, ItemID: oldID, Err: err}
+		}
+		absorbedDocs[idx] = docs
+		movedDocs[idx] = documentIDs(docs)
+		total += len(docs)
+	}
+
+	for idx, oldID := range plan.AbsorbIDs {
+		// Un annullamento si ferma qui, tra un elemento e l'altro: il journal
+		// registra fin dove è arrivato il merge, che può essere ripreso o annullato
+		if err := stopped(ctx); err != nil {
+			return result, err
+		}
+
+		if journal != nil && journal.Absorbed[idx].Deleted {
+			current++
+			result.Deleted = append(result.Deleted, oldID)
+			continue
+		}
+		docs := absorbedDocs[idx]
+
+		// Registra i documenti prima di spostarli, così l'undo sa quali riportare indietro.
+		// In ripresa si aggiungono a quelli già registrati (e magari già spostati).
+		if journal != nil {
+			absorbed := &journal.Absorbed[idx]
+			for _, doc := range docs {
+				if containsID(absorbed.Documents, doc.ID) {
+					continue
+				}
+				absorbed.Documents = append(absorbed.Documents, doc.ID)
+				if plan.Kind == KindTags && hasTag(doc, plan.SurvivorID) {
+					absorbed.HadSurvivor = append(absorbed.HadSurvivor, doc.ID)
+				}
+				if plan.Kind == KindCustomFields {
+					if absorbed.Values == nil {
+						absorbed.Values = make(map[int]json.RawMessage)
+					}
+					absorbed.Values[doc.ID], _ = fieldValue(doc.CustomFields, oldID)
+					// Il valore già presente sul sopravvissuto viene mantenuto
+					if value, ok := fieldValue(doc.CustomFields, plan.SurvivorID); ok && !isNull(value) {
+						absorbed.HadSurvivor = append(absorbed.HadSurvivor, doc.ID)
+					}
+				}
+			}
+			if err := e.saveJournal(journal); err != nil {
+				return result, err
+			}
+		}
+
+		// Step 2: Aggiornamento documenti, uno per operazione. I documenti non aggiornati
+		// non fermano gli altri, ma l'elemento non viene eliminato: il merge può essere ripreso.
+		if len(docs) > 0 {
+			progress := Progress{Step: StepUpdateDocuments, Current: current, Total: total, Item: idx + 1, Items: len(plan.AbsorbIDs), Documents: len(docs)}
+			e.report(progress)
+			done := e.documentProgress(progress, &current)
+
+			var docID int
+			var err error
+			if plan.Kind == KindCustomFields {
+				docID, err = e.moveFieldValues(ctx, documentIDs(docs), oldID, plan.SurvivorID, mappers[oldID], done)
+			} else {
+				docID, err = e.moveDocuments(ctx, plan.Kind, documentIDs(docs), oldID, plan.SurvivorID, done)
+			}
+			if err != nil {
+				return result, &StepError{Step: StepUpdateDocuments, ItemID: oldID, DocumentID: docID, Err: err}
+			}
+			result.DocumentsMoved += len(docs)
+		}
+
+		// Step 3: Eliminazione elemento assorbito (un 404 significa che è già stato eliminato)
+		current++
+		e.report(Progress{Step: StepDelete, Current: current, Total: total, Item: idx + 1, Items: len(plan.AbsorbIDs)})
+
+		if err := deleteItem(ctx, e.client, plan.Kind, oldID); err != nil && !paperless.IsNotFound(err) {
+			return result, &StepError{Step: StepDelete, ItemID: oldID, Err: err}
+		}
+		result.Deleted = append(result.Deleted, oldID)
+
+		if journal != nil {
+			journal.Absorbed[idx].Deleted = true
+			if err := e.saveJournal(journal); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Ora non ci sono più conflitti: assegna il nome finale
+	if plan.Rename {
+		current++
+		e.report(Progress{Step: StepFinalName, Current: current, Total: total})
+
+		if err := renameItem(ctx, e.client, plan.Kind, plan.SurvivorID, plan.FinalName); err != nil {
+			return result, &StepError{Step: StepFinalName, ItemID: plan.SurvivorID, Name: plan.FinalName, Err: err}
+		}
+	}
+
+	// La regola unita sostituisce quella del sopravvissuto solo a merge concluso
+	if plan.Matching != nil {
+		current++
+		e.report(Progress{Step: StepMatching, Current: current, Total: total})
+
+		if err := updateMatching(ctx, e.client, plan.Kind, plan.SurvivorID, *plan.Matching); err != nil {
+			return result, &StepError{Step: StepMatching, ItemID: plan.SurvivorID, Err: err}
+		}
+	}
+
+	if len(plan.Sources) > 0 {
+		current++
+		e.report(Progress{Step: StepAttributes, Current: current, Total: total})
+
+		if err := updateAttributes(ctx, e.client, plan.Kind, plan.SurvivorID, attributes, plan.sourceAttributes()); err != nil {
+			return result, &StepError{Step: StepAttributes, ItemID: plan.SurvivorID, Err: err}
+		}
+	}
+
+	// Rilegge tutto dal server invece di fidarsi dell'esito delle singole richieste
+	if verify {
+		current++
+		e.report(Progress{Step: StepVerify, Current: current, Total: total})
+
+		// In ripresa il journal conosce anche i documenti spostati nelle esecuzioni precedenti
+		if journal != nil {
+			for idx, absorbed := range journal.Absorbed {
+				movedDocs[idx] = absorbed.Documents
+			}
+		}
+
+		discrepancies, err := e.verify(ctx, plan, expectedDocuments(survivorDocs, movedDocs...))
+		if err != nil {
+			return result, err
+		}
+		result.Discrepancies = discrepancies
+		if journal != nil {
+			journal.Discrepancies = discrepancies
+		}
+	}
+
+	return result, nil
+}
+
+// rewriteReferences riscrive verso il sopravvissuto i riferimenti agli elementi assorbiti,
+// registrandone prima la versione originale nel journal. Restituisce tutti i riferimenti
+// riscritti dal merge (in ripresa anche quelli delle esecuzioni precedenti).
+func (e *Executor) rewriteReferences(ctx context.Context, plan Plan, journal *Journal) ([]Reference, error) {
+	refs, err := FindReferences(ctx, e.client, plan.Kind, plan.AbsorbIDs)
+	if err != nil {
+		return nil, &StepError{Step: StepReferences, ItemID: plan.SurvivorID, Err: err}
+	}
+
+	if journal != nil {
+		for _, ref := range refs {
+			if !hasReference(journal.References, ref) {
+				journal.References = append(journal.References, ref)
+			}
+		}
+		if err := e.saveJournal(journal); err != nil {
+			return nil, err
+		}
+	}
+
+	mapping := make(map[int]int, len(plan.AbsorbIDs))
+	for _, id := range plan.AbsorbIDs {
+		mapping[id] = plan.SurvivorID
+	}
+	for _, ref := range refs {
+		if err := applyReference(ctx, e.client, plan.Kind, ref, mapping); err != nil {
+			return nil, &StepError{Step: StepReferences, ItemID: plan.SurvivorID, Err: err}
+		}
+	}
+
+	if journal != nil {
+		return journal.References, nil
+	}
+	return refs, nil
+}
+
+// hasReference indica se la lista contiene già lo stesso oggetto
+func hasReference(refs []Reference, ref Reference) bool {
+	for _, r := range refs {
+		if r.Source == ref.Source && r.ID == ref.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTag indica se il documento ha il tag indicato
+func hasTag(doc paperless.Document, tagID int) bool {
+	return containsID(doc.Tags, tagID)
+}
+
+// containsID indica se la lista contiene l'ID indicato
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package merge
+
+import "encoding/json"
+
+// Item è la fotografia di un elemento (tag, corrispondente, tipo documento, ...)
+// indipendente dal suo tipo, usata dal journal per poterlo ricreare
+type Item struct {
+	ID                int             `json:"id"`
+	Name              string          `json:"name"`
+	Color             string          `json:"color,omitempty"`      // Solo tag
+	Path              string          `json:"path,omitempty"`       // Solo percorsi di archiviazione
+	DataType          string          `json:"data_type,omitempty"`  // Solo campi personalizzati
+	ExtraData         json.RawMessage `json:"extra_data,omitempty"` // Solo campi personalizzati (opzioni dei select)
+	Match             string          `json:"match"`
+	MatchingAlgorithm int             `json:"matching_algorithm"`
+	IsInsensitive     bool            `json:"is_insensitive"`
+	IsInboxTag        bool            `json:"is_inbox_tag,omitempty"` // Solo tag
+	Owner             *int            `json:"owner,omitempty"`
+	Parent            *int            `json:"parent,omitempty"` // Solo tag
+
+	// Documenti che usano l'elemento secondo il server: serve alla pulizia e non viene salvato
+	DocumentCount int `json:"-"`
+}
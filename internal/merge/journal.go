@@ -0,0 +1,212 @@
+package merge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status rappresenta lo stato di un merge registrato nel journal
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	StatusUndone    Status = "undone"
+)
+
+var (
+	// ErrNothingToUndo indica che non esiste un merge annullabile nel journal
+	ErrNothingToUndo = errors.New("nessun merge da annullare")
+	// ErrNothingToResume indica che il merge non è stato interrotto
+	ErrNothingToResume = errors.New("il merge non è stato interrotto")
+)
+
+// AbsorbedItem registra un elemento assorbito e i documenti spostati sul sopravvissuto
+type AbsorbedItem struct {
+	Item        Item  `json:"item"`
+	Documents   []int `json:"documents"`              // Documenti riassegnati al sopravvissuto
+	HadSurvivor []int `json:"had_survivor,omitempty"` // (Tag e campi personalizzati) documenti che avevano già il sopravvissuto
+	Deleted     bool  `json:"deleted"`
+	RestoredID  int   `json:"restored_id,omitempty"` // ID dell'elemento ricreato dall'undo
+
+	// (Solo campi personalizzati) valore originale del campo assorbito per documento
+	Values map[int]json.RawMessage `json:"values,omitempty"`
+}
+
+// Journal registra un merge con tutto il necessario per annullarlo
+type Journal struct {
+	ID        string         `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Status    Status         `json:"status"`
+	Plan      Plan           `json:"plan"`
+	Survivor  Item           `json:"survivor"` // Stato del sopravvissuto prima del merge
+	Absorbed  []AbsorbedItem `json:"absorbed"`
+	// Viste salvate, workflow e regole mail riscritti, nella versione precedente al merge
+	References []Reference `json:"references,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	// Cleanup indica la pulizia di elementi inutilizzati invece di un merge:
+	// gli elementi eliminati sono in Absorbed e non c'è un sopravvissuto
+	Cleanup bool `json:"cleanup,omitempty"`
+	// Differenze trovate dalla verifica a merge concluso
+	Discrepancies []Discrepancy `json:"discrepancies,omitempty"`
+}
+
+// newJournal crea un journal per il piano indicato
+func newJournal(plan Plan) *Journal {
+	now := time.Now()
+	return &Journal{
+		ID:        fmt.Sprintf("%s-%d", now.Format("20060102-150405.000"), plan.SurvivorID),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Status:    StatusRunning,
+		Plan:      plan,
+	}
+}
+
+// Undoable indica se il merge può essere annullato
+func (j *Journal) Undoable() bool {
+	return j.Status == StatusCompleted || j.Unfinished()
+}
+
+// Unfinished indica se il merge è stato interrotto (crash, errore di rete, ...)
+// e va ripreso o annullato per riportare l'istanza in uno stato coerente
+func (j *Journal) Unfinished() bool {
+	return j.Status == StatusRunning || j.Status == StatusFailed
+}
+
+// DocumentCount restituisce il numero di documenti riassegnati dal merge
+func (j *Journal) DocumentCount() int {
+	total := 0
+	for _, absorbed := range j.Absorbed {
+		total += len(absorbed.Documents)
+	}
+	return total
+}
+
+// survivorDocuments restituisce i documenti che avevano già il sopravvissuto prima del merge.
+// Vale quanto registrato dal primo elemento assorbito che elenca il documento: in ripresa
+// gli elementi successivi lo registrano quando il merge gli ha già dato il sopravvissuto.
+func (j *Journal) survivorDocuments() map[int]bool {
+	had := make(map[int]bool)
+	seen := make(map[int]bool)
+	for _, absorbed := range j.Absorbed {
+		for _, docID := range absorbed.Documents {
+			if seen[docID] {
+				continue
+			}
+			seen[docID] = true
+			had[docID] = containsID(absorbed.HadSurvivor, docID)
+		}
+	}
+	return had
+}
+
+// JournalStore salva i journal dei merge come file JSON in una directory
+type JournalStore struct {
+	dir string
+}
+
+// NewJournalStore crea uno store che salva i journal nella directory indicata
+func NewJournalStore(dir string) *JournalStore {
+	return &JournalStore{dir: dir}
+}
+
+// Save scrive il journal su disco in modo atomico
+func (s *JournalStore) Save(j *Journal) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("errore nella creazione della directory del journal: %w", err)
+	}
+
+	j.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("errore nella serializzazione del journal: %w", err)
+	}
+
+	// Scrive su un file temporaneo e lo rinomina, così un crash non lascia file troncati
+	path := filepath.Join(s.dir, j.ID+".json")
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("errore nel salvataggio del journal: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("errore nel salvataggio del journal: %w", err)
+	}
+
+	return nil
+}
+
+// List restituisce tutti i journal, dal più recente al più vecchio
+func (s *JournalStore) List() ([]*Journal, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("errore nella lettura del journal: %w", err)
+	}
+
+	var journals []*Journal
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("errore nella lettura del journal: %w", err)
+		}
+
+		var j Journal
+		if err := json.Unmarshal(data, &j); err != nil {
+			return nil, fmt.Errorf("errore nel parsing del journal %s: %w", entry.Name(), err)
+		}
+		journals = append(journals, &j)
+	}
+
+	sort.Slice(journals, func(a, b int) bool {
+		return journals[a].CreatedAt.After(journals[b].CreatedAt)
+	})
+
+	return journals, nil
+}
+
+// Unfinished restituisce i merge interrotti, dal più recente al più vecchio
+func (s *JournalStore) Unfinished() ([]*Journal, error) {
+	journals, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var unfinished []*Journal
+	for _, j := range journals {
+		if j.Unfinished() {
+			unfinished = append(unfinished, j)
+		}
+	}
+	return unfinished, nil
+}
+
+// LastUndoable restituisce il merge più recente che può essere annullato
+func (s *JournalStore) LastUndoable() (*Journal, error) {
+	journals, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, j := range journals {
+		if j.Undoable() {
+			return j, nil
+		}
+	}
+
+	return nil, ErrNothingToUndo
+}
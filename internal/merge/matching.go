@@ -0,0 +1,258 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// MatchRule è la regola di matching automatico di un elemento
+type MatchRule struct {
+	Match             string `json:"match"`
+	MatchingAlgorithm int    `json:"matching_algorithm"`
+	IsInsensitive     bool   `json:"is_insensitive"`
+}
+
+// MatchRule restituisce la regola di matching dell'elemento
+func (i Item) MatchRule() MatchRule {
+	return MatchRule{Match: i.Match, MatchingAlgorithm: i.MatchingAlgorithm, IsInsensitive: i.IsInsensitive}
+}
+
+// termPattern separa i termini di una regola come fa Paperless: frasi tra virgolette o parole singole
+var termPattern = regexp.MustCompile(`"([^"]+)"|(\S+)`)
+
+// splitTerms restituisce i termini di una regola "una qualsiasi delle parole"
+func splitTerms(match string) []string {
+	var terms []string
+	for _, m := range termPattern.FindAllStringSubmatch(match, -1) {
+		term := m[1]
+		if term == "" {
+			term = m[2]
+		}
+		if term = strings.Join(strings.Fields(term), " "); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// joinTerms ricompone i termini, mettendo tra virgolette quelli con più parole
+func joinTerms(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if strings.Contains(term, " ") {
+			term = `"` + term + `"`
+		}
+		parts = append(parts, term)
+	}
+	return strings.Join(parts, " ")
+}
+
+// appendTerms aggiunge i termini non ancora presenti (senza distinguere maiuscole e minuscole)
+func appendTerms(terms []string, add ...string) []string {
+	for _, term := range add {
+		duplicate := false
+		for _, existing := range terms {
+			if strings.EqualFold(existing, term) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// CombineMatching unisce le regole di matching degli elementi di un merge.
+// Restituisce una regola candidata per ogni algoritmo usato, nell'ordine in cui compare
+// (il sopravvissuto va passato per primo): più di un candidato indica un conflitto
+// che l'utente deve risolvere. Anche le regole con lo stesso algoritmo ma testi che non
+// si possono unire (tutte le parole, corrispondenza esatta e approssimata) restano
+// candidati separati. Le regole senza matching non partecipano all'unione; se nessun
+// elemento ha una regola il risultato è la sola regola "nessuno".
+func CombineMatching(rules []MatchRule) []MatchRule {
+	var algorithms []int
+	groups := make(map[int][]MatchRule)
+	for _, rule := range rules {
+		if rule.MatchingAlgorithm == paperless.MatchNone {
+			continue
+		}
+		if _, ok := groups[rule.MatchingAlgorithm]; !ok {
+			algorithms = append(algorithms, rule.MatchingAlgorithm)
+		}
+		groups[rule.MatchingAlgorithm] = append(groups[rule.MatchingAlgorithm], rule)
+	}
+
+	if len(algorithms) == 0 {
+		return []MatchRule{{MatchingAlgorithm: paperless.MatchNone}}
+	}
+
+	candidates := make([]MatchRule, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		candidates = append(candidates, combineGroup(algorithm, groups[algorithm])...)
+	}
+	return candidates
+}
+
+// SameAlgorithm indica se tutte le regole usano lo stesso algoritmo: un conflitto tra
+// candidati di questo tipo riguarda solo i testi
+func SameAlgorithm(rules []MatchRule) bool {
+	for _, rule := range rules {
+		if rule.MatchingAlgorithm != rules[0].MatchingAlgorithm {
+			return false
+		}
+	}
+	return true
+}
+
+// combineGroup unisce regole che usano lo stesso algoritmo.
+// Una regola che ignora maiuscole e minuscole rende insensibile anche quella unita,
+// così continuano a corrispondere tutti i documenti che corrispondevano prima.
+// Gli algoritmi con un solo testo non si possono unire: con testi diversi
+// restituisce un candidato per testo.
+func combineGroup(algorithm int, rules []MatchRule) []MatchRule {
+	combined := MatchRule{MatchingAlgorithm: algorithm}
+
+	var texts []string
+	for _, rule := range rules {
+		combined.IsInsensitive = combined.IsInsensitive || rule.IsInsensitive
+		if text := strings.TrimSpace(rule.Match); text != "" {
+			texts = appendTerms(texts, text)
+		}
+	}
+
+	switch algorithm {
+	case paperless.MatchAuto:
+		// L'apprendimento automatico non usa il testo della regola
+
+	case paperless.MatchAny:
+		var terms []string
+		for _, text := range texts {
+			terms = appendTerms(terms, splitTerms(text)...)
+		}
+		combined.Match = joinTerms(terms)
+
+	case paperless.MatchRegex:
+		if len(texts) == 1 {
+			combined.Match = texts[0]
+			break
+		}
+		parts := make([]string, len(texts))
+		for i, text := range texts {
+			parts[i] = "(?:" + text + ")"
+		}
+		combined.Match = strings.Join(parts, "|")
+
+	default:
+		// Tutte le parole, corrispondenza esatta e approssimata hanno un solo testo
+		if len(texts) <= 1 {
+			combined.Match = strings.Join(texts, "")
+			break
+		}
+		return splitGroup(algorithm, rules, texts)
+	}
+
+	return []MatchRule{combined}
+}
+
+// splitGroup restituisce un candidato per ciascuno dei testi, insensibile a maiuscole
+// e minuscole se lo è almeno una delle regole con quel testo
+func splitGroup(algorithm int, rules []MatchRule, texts []string) []MatchRule {
+	candidates := make([]MatchRule, len(texts))
+	for i, text := range texts {
+		candidates[i] = MatchRule{Match: text, MatchingAlgorithm: algorithm}
+		for _, rule := range rules {
+			if strings.EqualFold(strings.TrimSpace(rule.Match), text) {
+				candidates[i].IsInsensitive = candidates[i].IsInsensitive || rule.IsInsensitive
+			}
+		}
+	}
+	return candidates
+}
+
+// AcceptsNames indica se i vecchi nomi possono essere aggiunti alla regola come termini
+// senza cambiarne il significato
+func (r MatchRule) AcceptsNames() bool {
+	switch r.MatchingAlgorithm {
+	case paperless.MatchNone, paperless.MatchAny, paperless.MatchLiteral, paperless.MatchRegex:
+		return true
+	}
+	return false
+}
+
+// WithNames aggiunge alla regola i nomi indicati come termini da cercare.
+// Le regole senza matching e quelle a corrispondenza esatta diventano "una qualsiasi".
+func (r MatchRule) WithNames(names []string) MatchRule {
+	if !r.AcceptsNames() || len(names) == 0 {
+		return r
+	}
+
+	switch r.MatchingAlgorithm {
+	case paperless.MatchRegex:
+		parts := []string{}
+		if r.Match != "" {
+			parts = append(parts, "(?:"+r.Match+")")
+		}
+		for _, name := range names {
+			parts = append(parts, `(?:\b`+regexp.QuoteMeta(name)+`\b)`)
+		}
+		r.Match = strings.Join(parts, "|")
+		return r
+
+	case paperless.MatchLiteral:
+		var terms []string
+		if text := strings.Join(strings.Fields(r.Match), " "); text != "" {
+			terms = append(terms, text)
+		}
+		r.Match = joinTerms(appendTerms(terms, cleanNames(names)...))
+
+	case paperless.MatchNone:
+		r.Match = joinTerms(cleanNames(names))
+		// I nomi vengono confrontati come li scrive l'utente, maiuscole o minuscole che siano
+		r.IsInsensitive = true
+
+	default:
+		r.Match = joinTerms(appendTerms(splitTerms(r.Match), cleanNames(names)...))
+	}
+
+	r.MatchingAlgorithm = paperless.MatchAny
+	return r
+}
+
+// cleanNames normalizza gli spazi dei nomi e toglie le virgolette, che nelle regole
+// "una qualsiasi" delimitano le frasi
+func cleanNames(names []string) []string {
+	var cleaned []string
+	for _, name := range names {
+		name = strings.Join(strings.Fields(strings.ReplaceAll(name, `"`, " ")), " ")
+		if name != "" {
+			cleaned = appendTerms(cleaned, name)
+		}
+	}
+	return cleaned
+}
+
+// updateMatching aggiorna la regola di matching di un elemento del tipo indicato
+func updateMatching(ctx context.Context, client *paperless.Client, kind Kind, id int, rule MatchRule) error {
+	switch kind {
+	case KindTags:
+		return client.UpdateTagMatching(ctx, id, rule.Match, rule.MatchingAlgorithm, rule.IsInsensitive)
+	case KindCorrespondents:
+		return client.UpdateCorrespondentMatching(ctx, id, rule.Match, rule.MatchingAlgorithm, rule.IsInsensitive)
+	case KindDocumentTypes:
+		return client.UpdateDocumentTypeMatching(ctx, id, rule.Match, rule.MatchingAlgorithm, rule.IsInsensitive)
+	case KindStoragePaths:
+		return client.UpdateStoragePathMatching(ctx, id, rule.Match, rule.MatchingAlgorithm, rule.IsInsensitive)
+	}
+	return fmt.Errorf("tipo di entità senza regole di matching: %d", kind)
+}
+
+// HasMatching indica se il tipo di entità ha regole di matching
+func HasMatching(kind Kind) bool {
+	return kind != KindCustomFields
+}
@@ -0,0 +1,90 @@
+package merge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+func TestCombineMatching(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules []MatchRule
+		want  []MatchRule
+	}{
+		{
+			name:  "nessuna regola",
+			rules: []MatchRule{{}, {}},
+			want:  []MatchRule{{MatchingAlgorithm: paperless.MatchNone}},
+		},
+		{
+			name: "parole unite",
+			rules: []MatchRule{
+				{Match: `acme "acme srl"`, MatchingAlgorithm: paperless.MatchAny},
+				{Match: "ACME fornitore", MatchingAlgorithm: paperless.MatchAny, IsInsensitive: true},
+			},
+			want: []MatchRule{{Match: `acme "acme srl" fornitore`, MatchingAlgorithm: paperless.MatchAny, IsInsensitive: true}},
+		},
+		{
+			name: "espressioni regolari alternative",
+			rules: []MatchRule{
+				{Match: "^acme", MatchingAlgorithm: paperless.MatchRegex},
+				{Match: `srl\b`, MatchingAlgorithm: paperless.MatchRegex},
+			},
+			want: []MatchRule{{Match: `(?:^acme)|(?:srl\b)`, MatchingAlgorithm: paperless.MatchRegex}},
+		},
+		{
+			name: "algoritmi diversi",
+			rules: []MatchRule{
+				{Match: "acme", MatchingAlgorithm: paperless.MatchLiteral},
+				{},
+				{Match: "^acme", MatchingAlgorithm: paperless.MatchRegex},
+			},
+			want: []MatchRule{
+				{Match: "acme", MatchingAlgorithm: paperless.MatchLiteral},
+				{Match: "^acme", MatchingAlgorithm: paperless.MatchRegex},
+			},
+		},
+		{
+			name: "stesso testo esatto",
+			rules: []MatchRule{
+				{Match: "Acme Srl", MatchingAlgorithm: paperless.MatchLiteral},
+				{Match: " acme srl ", MatchingAlgorithm: paperless.MatchLiteral, IsInsensitive: true},
+			},
+			want: []MatchRule{{Match: "Acme Srl", MatchingAlgorithm: paperless.MatchLiteral, IsInsensitive: true}},
+		},
+		{
+			// I testi non si possono unire senza cambiare algoritmo: restano candidati
+			name: "testi esatti diversi",
+			rules: []MatchRule{
+				{Match: "Acme Srl", MatchingAlgorithm: paperless.MatchLiteral},
+				{Match: "Acme S.r.l.", MatchingAlgorithm: paperless.MatchLiteral, IsInsensitive: true},
+				{Match: "acme srl", MatchingAlgorithm: paperless.MatchLiteral},
+			},
+			want: []MatchRule{
+				{Match: "Acme Srl", MatchingAlgorithm: paperless.MatchLiteral},
+				{Match: "Acme S.r.l.", MatchingAlgorithm: paperless.MatchLiteral, IsInsensitive: true},
+			},
+		},
+		{
+			name: "testi di tutte le parole diversi",
+			rules: []MatchRule{
+				{Match: "fattura acme", MatchingAlgorithm: paperless.MatchAll},
+				{Match: "ricevuta acme", MatchingAlgorithm: paperless.MatchAll},
+			},
+			want: []MatchRule{
+				{Match: "fattura acme", MatchingAlgorithm: paperless.MatchAll},
+				{Match: "ricevuta acme", MatchingAlgorithm: paperless.MatchAll},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CombineMatching(tc.rules); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("CombineMatching: %+v, attese %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// Kind rappresenta il tipo di entità coinvolta in un merge
+type Kind int
+
+const (
+	KindTags Kind = iota
+	KindCorrespondents
+	KindDocumentTypes
+	KindStoragePaths
+	KindCustomFields
+)
+
+// TempPrefix è il prefisso del nome temporaneo assegnato al sopravvissuto durante il merge
+const TempPrefix = "__MERGING_"
+
+var (
+	// ErrEmptyName indica che il nome finale del merge è vuoto
+	ErrEmptyName = errors.New("il nome finale non può essere vuoto")
+	// ErrTooFewItems indica che sono stati selezionati meno di due elementi
+	ErrTooFewItems = errors.New("servono almeno 2 elementi da unire")
+	// ErrIncompatibleFields indica che i campi personalizzati da unire hanno tipi di dato diversi
+	ErrIncompatibleFields = errors.New("i campi personalizzati hanno tipi di dato diversi")
+	// ErrSurvivorNotSelected indica che il sopravvissuto scelto non è tra gli elementi selezionati
+	ErrSurvivorNotSelected = errors.New("il sopravvissuto scelto non è tra gli elementi selezionati")
+)
+
+// Plan descrive un merge da eseguire
+type Plan struct {
+	Kind       Kind   `json:"kind"`
+	SurvivorID int    `json:"survivor_id"` // Elemento che sopravvive al merge
+	AbsorbIDs  []int  `json:"absorb_ids"`  // Elementi assorbiti (i loro documenti passano al sopravvissuto, poi vengono eliminati)
+	FinalName  string `json:"final_name"`  // Nome del sopravvissuto a merge concluso
+	Rename     bool   `json:"rename"`      // true se il sopravvissuto deve essere rinominato in FinalName
+
+	// Regola di matching da assegnare al sopravvissuto (nil per lasciare quella attuale)
+	Matching *MatchRule `json:"matching,omitempty"`
+	// Attributi che il sopravvissuto prende da un elemento assorbito (attributo -> ID dell'elemento)
+	Sources map[Attribute]int `json:"sources,omitempty"`
+}
+
+// NewPlan costruisce un piano di merge dagli elementi selezionati.
+// survivorID indica esplicitamente l'elemento che sopravvive (0 per la scelta automatica):
+// in automatico sopravvive l'elemento che ha già il nome finale, altrimenti il primo
+// elemento, che viene rinominato.
+func NewPlan(kind Kind, selected []similarity.SimilarItem, survivorID int, finalName string) (Plan, error) {
+	if finalName == "" {
+		return Plan{}, ErrEmptyName
+	}
+
+	// Deduplica gli elementi mantenendo l'ordine
+	items := make([]similarity.SimilarItem, 0, len(selected))
+	seenIDs := make(map[int]bool)
+	for _, item := range selected {
+		if !seenIDs[item.ID] {
+			items = append(items, item)
+			seenIDs[item.ID] = true
+		}
+	}
+
+	if len(items) < 2 {
+		return Plan{}, ErrTooFewItems
+	}
+
+	survivor := items[0]
+	for _, item := range items {
+		if item.Name == finalName {
+			survivor = item
+			break
+		}
+	}
+	if survivorID != 0 {
+		found := false
+		for _, item := range items {
+			if item.ID == survivorID {
+				survivor, found = item, true
+				break
+			}
+		}
+		if !found {
+			return Plan{}, ErrSurvivorNotSelected
+		}
+	}
+
+	plan := Plan{
+		Kind:       kind,
+		SurvivorID: survivor.ID,
+		FinalName:  finalName,
+		Rename:     survivor.Name != finalName,
+	}
+	for _, item := range items {
+		if item.ID != survivor.ID {
+			plan.AbsorbIDs = append(plan.AbsorbIDs, item.ID)
+		}
+	}
+
+	return plan, nil
+}
+
+// TempName restituisce il nome temporaneo usato per il sopravvissuto durante il merge,
+// così da liberare il nome finale finché gli altri elementi non sono stati eliminati
+func (p Plan) TempName() string {
+	return tempName(p.SurvivorID, p.FinalName)
+}
+
+// tempName compone il nome temporaneo di un elemento in attesa del nome finale
+func tempName(id int, finalName string) string {
+	return fmt.Sprintf("%s%d_%s", TempPrefix, id, finalName)
+}
+
+// Step identifica la fase del merge in corso
+type Step int
+
+const (
+	StepPrepare Step = iota
+	StepGetDocuments
+	StepUpdateDocuments
+	StepDelete
+	StepFinalName
+	StepSnapshot          // Lettura dello stato degli elementi per il journal
+	StepJournal           // Scrittura del journal
+	StepRestoreSurvivor   // Undo: ripristino del nome originale del sopravvissuto
+	StepRecreate          // Undo: ricreazione di un elemento eliminato
+	StepRestoreDocuments  // Undo: riassegnazione dei documenti all'elemento ricreato
+	StepReferences        // Riscrittura di viste salvate, workflow e regole mail
+	StepRestoreReferences // Undo: ripristino di viste salvate, workflow e regole mail
+	StepMatching          // Assegnazione della regola di matching unita al sopravvissuto
+	StepRestoreMatching   // Undo: ripristino della regola di matching del sopravvissuto
+	StepAttributes        // Assegnazione al sopravvissuto degli attributi presi dagli assorbiti
+	StepRestoreAttributes // Undo: ripristino degli attributi originali del sopravvissuto
+	StepCreate            // Conversione: creazione dell'elemento di destinazione
+	StepRemoveTag         // Conversione: rimozione del tag dai documenti
+	StepRename            // Rinomina di massa: rinomina di un elemento
+	StepVerify            // Verifica dello stato degli elementi a merge concluso
+	StepRollback          // Ripristino dopo l'annullamento di un'operazione senza journal
+)
+
+// Progress descrive l'avanzamento del merge
+type Progress struct {
+	Step      Step
+	Current   int // Operazione corrente
+	Total     int // Numero totale (stimato) di operazioni
+	Item      int // Indice (da 1) dell'elemento assorbito in lavorazione
+	Items     int // Numero di elementi assorbiti
+	Documents int // Documenti coinvolti nella fase corrente
+	Document  int // Documenti della fase corrente già aggiornati
+	Plan      int // Indice (da 1) del merge in corso in una coda (0 fuori da una coda)
+	Plans     int // Numero di merge della coda
+}
+
+// Reporter riceve gli aggiornamenti di avanzamento del merge
+type Reporter interface {
+	Report(Progress)
+}
+
+// ReporterFunc adatta una funzione all'interfaccia Reporter
+type ReporterFunc func(Progress)
+
+// Report implementa Reporter
+func (f ReporterFunc) Report(p Progress) {
+	f(p)
+}
+
+// StepError è l'errore restituito quando una fase del merge fallisce
+type StepError struct {
+	Step       Step
+	ItemID     int    // Elemento su cui si stava lavorando
+	DocumentID int    // Documento in aggiornamento (0 se sono falliti più documenti, vedi DocumentErrors)
+	Name       string // Nome che si stava assegnando (solo per StepRename e StepFinalName)
+	Err        error
+}
+
+func (e *StepError) Error() string {
+	switch e.Step {
+	case StepPrepare:
+		return fmt.Sprintf("errore nell'aggiornamento temporaneo di %d: %v", e.ItemID, e.Err)
+	case StepGetDocuments:
+		return fmt.Sprintf("errore nel recupero documenti di %d: %v", e.ItemID, e.Err)
+	case StepUpdateDocuments:
+		if e.DocumentID == 0 {
+			return fmt.Sprintf("errore nell'aggiornamento dei documenti di %d: %v", e.ItemID, e.Err)
+		}
+		return fmt.Sprintf("errore nell'aggiornamento documento %d: %v", e.DocumentID, e.Err)
+	case StepDelete:
+		return fmt.Sprintf("errore nell'eliminazione di %d: %v", e.ItemID, e.Err)
+	case StepFinalName:
+		return fmt.Sprintf("errore nell'aggiornamento finale di %d: %v", e.ItemID, e.Err)
+	case StepSnapshot:
+		return fmt.Sprintf("errore nella lettura di %d: %v", e.ItemID, e.Err)
+	case StepJournal:
+		return fmt.Sprintf("errore nella scrittura del journal: %v", e.Err)
+	case StepRestoreSurvivor:
+		return fmt.Sprintf("errore nel ripristino del nome di %d: %v", e.ItemID, e.Err)
+	case StepRecreate:
+		return fmt.Sprintf("errore nella ricreazione di %d: %v", e.ItemID, e.Err)
+	case StepRestoreDocuments:
+		if e.DocumentID == 0 {
+			return fmt.Sprintf("errore nel ripristino dei documenti di %d: %v", e.ItemID, e.Err)
+		}
+		return fmt.Sprintf("errore nel ripristino del documento %d: %v", e.DocumentID, e.Err)
+	case StepReferences:
+		return fmt.Sprintf("errore nell'aggiornamento dei riferimenti a %d: %v", e.ItemID, e.Err)
+	case StepRestoreReferences:
+		return fmt.Sprintf("errore nel ripristino dei riferimenti a %d: %v", e.ItemID, e.Err)
+	case StepMatching:
+		return fmt.Sprintf("errore nell'aggiornamento delle regole di matching di %d: %v", e.ItemID, e.Err)
+	case StepRestoreMatching:
+		return fmt.Sprintf("errore nel ripristino delle regole di matching di %d: %v", e.ItemID, e.Err)
+	case StepAttributes:
+		return fmt.Sprintf("errore nell'aggiornamento degli attributi di %d: %v", e.ItemID, e.Err)
+	case StepRestoreAttributes:
+		return fmt.Sprintf("errore nel ripristino degli attributi di %d: %v", e.ItemID, e.Err)
+	case StepCreate:
+		return fmt.Sprintf("errore nella creazione della destinazione di %d: %v", e.ItemID, e.Err)
+	case StepRemoveTag:
+		if e.DocumentID == 0 {
+			return fmt.Sprintf("errore nella rimozione del tag %d dai documenti: %v", e.ItemID, e.Err)
+		}
+		return fmt.Sprintf("errore nella rimozione del tag %d dal documento %d: %v", e.ItemID, e.DocumentID, e.Err)
+	case StepRename:
+		return fmt.Sprintf("errore nella rinomina di %d: %v", e.ItemID, e.Err)
+	case StepVerify:
+		return fmt.Sprintf("errore nella verifica di %d: %v", e.ItemID, e.Err)
+	case StepRollback:
+		if e.DocumentID == 0 {
+			return fmt.Sprintf("errore nel ripristino dopo l'annullamento su %d: %v", e.ItemID, e.Err)
+		}
+		return fmt.Sprintf("errore nel ripristino del documento %d dopo l'annullamento: %v", e.DocumentID, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Result riassume un merge completato
+type Result struct {
+	DocumentsMoved int         // Documenti spostati sul sopravvissuto
+	Deleted        []int       // Elementi eliminati
+	References     []Reference // Viste salvate, workflow e regole mail riscritti
+	JournalID      string      // Journal del merge (vuoto se il journal non è attivo)
+	// Differenze trovate dalla verifica finale (nessuna se il merge è andato come previsto)
+	Discrepancies []Discrepancy
+}
@@ -0,0 +1,116 @@
+package merge
+
+import (
+	"context"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// ItemPreview descrive un elemento coinvolto nel merge e il suo utilizzo attuale
+type ItemPreview struct {
+	ID        int
+	Name      string
+	Documents int  // Documenti che usano attualmente l'elemento
+	Item      Item // Stato attuale dell'elemento (regola di matching, colore, proprietario, ...)
+}
+
+// Preview descrive cosa farà un piano di merge, senza modificare nulla
+type Preview struct {
+	Plan     Plan
+	Survivor ItemPreview
+	Absorbed []ItemPreview
+	// Viste salvate, workflow e regole mail che verranno riscritti verso il sopravvissuto
+	References []Reference
+}
+
+// NewPreview calcola l'anteprima di un piano contando i documenti di ogni elemento.
+// items fornisce i nomi correnti degli elementi del piano.
+func NewPreview(ctx context.Context, client *paperless.Client, plan Plan, items []similarity.SimilarItem) (Preview, error) {
+	names := make(map[int]string, len(items))
+	for _, item := range items {
+		names[item.ID] = item.Name
+	}
+
+	preview := Preview{Plan: plan}
+
+	if plan.Kind == KindCustomFields {
+		if err := checkFieldTypes(ctx, client, plan); err != nil {
+			return Preview{}, err
+		}
+	}
+
+	survivor, err := itemPreview(ctx, client, plan.Kind, plan.SurvivorID, names[plan.SurvivorID])
+	if err != nil {
+		return Preview{}, err
+	}
+	preview.Survivor = survivor
+
+	for _, id := range plan.AbsorbIDs {
+		absorbed, err := itemPreview(ctx, client, plan.Kind, id, names[id])
+		if err != nil {
+			return Preview{}, err
+		}
+		preview.Absorbed = append(preview.Absorbed, absorbed)
+	}
+
+	refs, err := FindReferences(ctx, client, plan.Kind, plan.AbsorbIDs)
+	if err != nil {
+		return Preview{}, &StepError{Step: StepReferences, ItemID: plan.SurvivorID, Err: err}
+	}
+	preview.References = refs
+
+	return preview, nil
+}
+
+// Items restituisce il sopravvissuto seguito dagli elementi assorbiti
+func (p Preview) Items() []ItemPreview {
+	return append([]ItemPreview{p.Survivor}, p.Absorbed...)
+}
+
+// DocumentsToMove restituisce il numero di documenti che verranno spostati sul sopravvissuto
+func (p Preview) DocumentsToMove() int {
+	total := 0
+	for _, item := range p.Absorbed {
+		total += item.Documents
+	}
+	return total
+}
+
+// MatchingChoices restituisce le regole di matching candidate per il sopravvissuto,
+// unendo le regole di tutti gli elementi (vedi CombineMatching)
+func (p Preview) MatchingChoices() []MatchRule {
+	rules := []MatchRule{p.Survivor.Item.MatchRule()}
+	for _, item := range p.Absorbed {
+		rules = append(rules, item.Item.MatchRule())
+	}
+	return CombineMatching(rules)
+}
+
+// OldNames restituisce i nomi che spariscono con il merge: quelli degli assorbiti
+// e quello del sopravvissuto se viene rinominato
+func (p Preview) OldNames() []string {
+	var names []string
+	if p.Plan.Rename {
+		names = append(names, p.Survivor.Name)
+	}
+	for _, item := range p.Absorbed {
+		if item.Name != p.Plan.FinalName {
+			names = append(names, item.Name)
+		}
+	}
+	return names
+}
+
+func itemPreview(ctx context.Context, client *paperless.Client, kind Kind, id int, name string) (ItemPreview, error) {
+	docs, err := itemDocuments(ctx, client, kind, id)
+	if err != nil {
+		return ItemPreview{}, &StepError{Step: StepGetDocuments, ItemID: id, Err: err}
+	}
+
+	item, err := fetchItem(ctx, client, kind, id)
+	if err != nil {
+		return ItemPreview{}, &StepError{Step: StepSnapshot, ItemID: id, Err: err}
+	}
+	return ItemPreview{ID: id, Name: name, Documents: len(docs), Item: item}, nil
+}
@@ -0,0 +1,83 @@
+package merge
+
+import "context"
+
+// QueueResult è l'esito di un merge della coda
+type QueueResult struct {
+	Plan   Plan
+	Result Result
+	Err    error
+}
+
+// ExecuteQueue esegue in sequenza i merge della coda: un merge fallito viene registrato
+// e non ferma i successivi. L'avanzamento riportato è quello complessivo della coda,
+// con Plan e Plans che indicano il merge in corso.
+func (e *Executor) ExecuteQueue(ctx context.Context, plans []Plan) []QueueResult {
+	reporter := e.reporter
+	defer func() { e.reporter = reporter }()
+	ctx = safeContext(ctx)
+
+	results := make([]QueueResult, 0, len(plans))
+	for i, plan := range plans {
+		e.reporter = ReporterFunc(func(p Progress) {
+			// Ogni merge occupa la stessa porzione della barra di avanzamento
+			p.Current += i * p.Total
+			p.Total *= len(plans)
+			p.Plan = i + 1
+			p.Plans = len(plans)
+			if reporter != nil {
+				reporter.Report(p)
+			}
+		})
+
+		result := QueueResult{Plan: plan}
+		// Dopo un annullamento i merge restanti non vengono avviati
+		if err := stopped(ctx); err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		if plan.Matching == nil {
+			matching, err := e.defaultMatching(ctx, plan)
+			if err != nil {
+				result.Err = err
+				results = append(results, result)
+				continue
+			}
+			result.Plan.Matching = matching
+		}
+
+		result.Result, result.Err = e.Execute(ctx, result.Plan)
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// defaultMatching sceglie la regola di matching di un merge senza chiederla: la regola
+// unita degli elementi o, se usano algoritmi diversi o testi che non si possono unire,
+// il primo candidato (quello del sopravvissuto). Restituisce nil se la regola non cambia.
+func (e *Executor) defaultMatching(ctx context.Context, plan Plan) (*MatchRule, error) {
+	if !HasMatching(plan.Kind) {
+		return nil, nil
+	}
+
+	var rules []MatchRule
+	var survivor MatchRule
+	for _, id := range append([]int{plan.SurvivorID}, plan.AbsorbIDs...) {
+		item, err := fetchItem(ctx, e.client, plan.Kind, id)
+		if err != nil {
+			return nil, &StepError{Step: StepSnapshot, ItemID: id, Err: err}
+		}
+		if id == plan.SurvivorID {
+			survivor = item.MatchRule()
+		}
+		rules = append(rules, item.MatchRule())
+	}
+
+	rule := CombineMatching(rules)[0]
+	if rule == survivor {
+		return nil, nil
+	}
+	return &rule, nil
+}
@@ -0,0 +1,89 @@
+package merge
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// Kinds elenca i tipi di entità gestiti dal merge
+var Kinds = []Kind{KindTags, KindCorrespondents, KindDocumentTypes, KindStoragePaths, KindCustomFields}
+
+// Leftover è un elemento rimasto con il nome temporaneo __MERGING_
+// senza un journal interrotto che permetta di riprenderne il merge
+type Leftover struct {
+	Kind      Kind
+	ID        int
+	Name      string // Nome temporaneo attuale
+	FinalName string // Nome finale ricavato dal nome temporaneo
+}
+
+// ParseTempName estrae ID del sopravvissuto e nome finale da un nome temporaneo
+// nel formato __MERGING_<id>_<nome>
+func ParseTempName(name string) (survivorID int, finalName string, ok bool) {
+	if !strings.HasPrefix(name, TempPrefix) {
+		return 0, "", false
+	}
+
+	rest := strings.TrimPrefix(name, TempPrefix)
+	idPart, finalName, found := strings.Cut(rest, "_")
+	if !found || finalName == "" {
+		return 0, "", false
+	}
+
+	survivorID, err := strconv.Atoi(idPart)
+	if err != nil {
+		return 0, "", false
+	}
+
+	return survivorID, finalName, true
+}
+
+// FindLeftovers cerca gli elementi con nome temporaneo __MERGING_ che non
+// appartengono a uno dei merge interrotti indicati (quelli vanno ripresi dal journal)
+func FindLeftovers(ctx context.Context, client *paperless.Client, unfinished []*Journal) ([]Leftover, error) {
+	covered := make(map[Kind]map[int]bool)
+	for _, j := range unfinished {
+		if covered[j.Plan.Kind] == nil {
+			covered[j.Plan.Kind] = make(map[int]bool)
+		}
+		covered[j.Plan.Kind][j.Plan.SurvivorID] = true
+	}
+
+	var leftovers []Leftover
+	for _, kind := range Kinds {
+		items, err := listItems(ctx, client, kind)
+		// Le versioni precedenti alla 2.0 non hanno i campi personalizzati (404)
+		if paperless.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			survivorID, finalName, ok := ParseTempName(item.Name)
+			if !ok || survivorID != item.ID || covered[kind][item.ID] {
+				continue
+			}
+			leftovers = append(leftovers, Leftover{
+				Kind:      kind,
+				ID:        item.ID,
+				Name:      item.Name,
+				FinalName: finalName,
+			})
+		}
+	}
+
+	return leftovers, nil
+}
+
+// FixLeftover assegna a un elemento rimasto col nome temporaneo il suo nome finale
+func (e *Executor) FixLeftover(ctx context.Context, l Leftover) error {
+	if err := renameItem(ctx, e.client, l.Kind, l.ID, l.FinalName); err != nil {
+		return &StepError{Step: StepFinalName, ItemID: l.ID, Err: err}
+	}
+	return nil
+}
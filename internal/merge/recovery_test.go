@@ -0,0 +1,42 @@
+package merge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/paperless/fake"
+)
+
+func TestFindLeftoversWithoutCustomFields(t *testing.T) {
+	srv, err := fake.NewServer(&fake.Fixture{
+		Tags: []paperless.Tag{
+			{ID: 1, Name: "__MERGING_1_Fatture"},
+			{ID: 2, Name: "Ricevute"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	// Un server precedente alla 2.0 non espone /api/custom_fields/
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/custom_fields/") {
+			http.NotFound(w, r)
+			return
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	leftovers, err := FindLeftovers(context.Background(), paperless.NewClient(ts.URL, fake.DefaultToken), nil)
+	if err != nil {
+		t.Fatalf("FindLeftovers: %v", err)
+	}
+	if len(leftovers) != 1 || leftovers[0].Kind != KindTags || leftovers[0].ID != 1 || leftovers[0].FinalName != "Fatture" {
+		t.Errorf("elementi rimasti %+v, atteso il tag 1 con nome finale Fatture", leftovers)
+	}
+}
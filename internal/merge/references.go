@@ -0,0 +1,451 @@
+package merge
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// RefSource identifica il tipo di oggetto che fa riferimento a un elemento
+type RefSource int
+
+const (
+	RefSavedView RefSource = iota
+	RefWorkflow
+	RefMailRule
+)
+
+// Reference è una vista salvata, un workflow o una regola mail che usa un elemento
+// assorbito e che il merge riscrive verso il sopravvissuto
+type Reference struct {
+	Source   RefSource       `json:"source"`
+	ID       int             `json:"id"`
+	Name     string          `json:"name"`
+	Original json.RawMessage `json:"original"` // Oggetto prima della riscrittura, per l'undo
+}
+
+// filterRuleTypes sono i tipi di regola delle viste salvate che contengono l'ID di un elemento
+// (vedi filter-rule-type.ts nel frontend di Paperless)
+var filterRuleTypes = map[Kind][]int{
+	KindTags:           {6, 17, 22},  // Ha tutti i tag, non ha il tag, ha uno dei tag
+	KindCorrespondents: {3, 26, 27},  // Corrispondente, uno dei corrispondenti, non ha il corrispondente
+	KindDocumentTypes:  {4, 28, 29},  // Tipo documento, uno dei tipi, non ha il tipo
+	KindStoragePaths:   {25, 30, 31}, // Percorso, uno dei percorsi, non ha il percorso
+	KindCustomFields:   {38, 39, 40}, // Ha tutti i campi, uno dei campi, non ha il campo
+}
+
+// triggerFields sono i campi dei trigger dei workflow che contengono ID di elementi
+var triggerFields = map[Kind][]string{
+	KindTags:           {"filter_has_tags", "filter_has_all_tags", "filter_has_not_tags"},
+	KindCorrespondents: {"filter_has_correspondent", "filter_has_not_correspondents"},
+	KindDocumentTypes:  {"filter_has_document_type", "filter_has_not_document_types"},
+	KindStoragePaths:   {"filter_has_storage_path", "filter_has_not_storage_paths"},
+}
+
+// actionFields sono i campi delle azioni dei workflow che contengono ID di elementi
+var actionFields = map[Kind][]string{
+	KindTags:           {"assign_tags", "remove_tags"},
+	KindCorrespondents: {"assign_correspondent", "remove_correspondents"},
+	KindDocumentTypes:  {"assign_document_type", "remove_document_types"},
+	KindStoragePaths:   {"assign_storage_path", "remove_storage_paths"},
+	KindCustomFields:   {"assign_custom_fields", "remove_custom_fields"},
+}
+
+// FindReferences cerca viste salvate, workflow e regole mail che usano gli elementi indicati.
+// Le istanze che non espongono workflow o regole mail (404) vengono ignorate.
+func FindReferences(ctx context.Context, client *paperless.Client, kind Kind, ids []int) ([]Reference, error) {
+	mapping := make(map[int]int, len(ids))
+	for _, id := range ids {
+		// Il valore non conta: serve solo sapere se qualcosa cambierebbe
+		mapping[id] = -id
+	}
+
+	var refs []Reference
+
+	views, err := client.GetSavedViews(ctx)
+	if err != nil && !paperless.IsNotFound(err) {
+		return nil, err
+	}
+	for _, view := range views {
+		original, _ := json.Marshal(view)
+		if rewriteSavedView(&view, kind, mapping) {
+			refs = append(refs, Reference{Source: RefSavedView, ID: view.ID, Name: view.Name, Original: original})
+		}
+	}
+
+	workflows, err := client.GetWorkflows(ctx)
+	if err != nil && !paperless.IsNotFound(err) {
+		return nil, err
+	}
+	for _, workflow := range workflows {
+		original, _ := json.Marshal(workflow)
+		if rewriteWorkflow(&workflow, kind, mapping) {
+			refs = append(refs, Reference{Source: RefWorkflow, ID: workflow.ID, Name: workflow.Name, Original: original})
+		}
+	}
+
+	rules, err := client.GetMailRules(ctx)
+	if err != nil && !paperless.IsNotFound(err) {
+		return nil, err
+	}
+	for _, rule := range rules {
+		original, _ := json.Marshal(rule)
+		if rewriteMailRule(&rule, kind, mapping) {
+			refs = append(refs, Reference{Source: RefMailRule, ID: rule.ID, Name: rule.Name, Original: original})
+		}
+	}
+
+	return refs, nil
+}
+
+// applyReference riscrive un riferimento partendo dalla sua versione originale,
+// sostituendo gli ID secondo mapping (vecchio ID -> nuovo ID)
+func applyReference(ctx context.Context, client *paperless.Client, kind Kind, ref Reference, mapping map[int]int) error {
+	switch ref.Source {
+	case RefSavedView:
+		var view paperless.SavedView
+		if err := json.Unmarshal(ref.Original, &view); err != nil {
+			return err
+		}
+		rewriteSavedView(&view, kind, mapping)
+		return client.UpdateSavedViewFilterRules(ctx, view.ID, view.FilterRules)
+
+	case RefWorkflow:
+		var workflow paperless.Workflow
+		if err := json.Unmarshal(ref.Original, &workflow); err != nil {
+			return err
+		}
+		rewriteWorkflow(&workflow, kind, mapping)
+		return client.UpdateWorkflow(ctx, workflow.ID, workflow.Triggers, workflow.Actions)
+
+	case RefMailRule:
+		var rule paperless.MailRule
+		if err := json.Unmarshal(ref.Original, &rule); err != nil {
+			return err
+		}
+		rewriteMailRule(&rule, kind, mapping)
+		return client.UpdateMailRule(ctx, rule)
+	}
+	return nil
+}
+
+// rewriteSavedView sostituisce gli ID nelle regole di filtro e indica se qualcosa è cambiato.
+// Le regole duplicate che si creano (due tag diventati lo stesso) vengono rimosse.
+func rewriteSavedView(view *paperless.SavedView, kind Kind, mapping map[int]int) bool {
+	type ruleKey struct {
+		ruleType int
+		value    string
+	}
+
+	changed := false
+	rules := make([]paperless.FilterRule, 0, len(view.FilterRules))
+	seen := make(map[ruleKey]bool)
+
+	for _, rule := range view.FilterRules {
+		if rule.Value != nil && containsID(filterRuleTypes[kind], rule.RuleType) {
+			if id, err := strconv.Atoi(*rule.Value); err == nil {
+				if newID, ok := mapping[id]; ok {
+					value := strconv.Itoa(newID)
+					rule.Value = &value
+					changed = true
+				}
+			}
+		}
+
+		if rule.Value != nil {
+			key := ruleKey{ruleType: rule.RuleType, value: *rule.Value}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		rules = append(rules, rule)
+	}
+
+	view.FilterRules = rules
+	return changed
+}
+
+// rewriteWorkflow sostituisce gli ID in trigger e azioni e indica se qualcosa è cambiato
+func rewriteWorkflow(workflow *paperless.Workflow, kind Kind, mapping map[int]int) bool {
+	changed := false
+	for _, trigger := range workflow.Triggers {
+		if rewriteFields(trigger, triggerFields[kind], mapping) {
+			changed = true
+		}
+	}
+	for _, action := range workflow.Actions {
+		if rewriteFields(action, actionFields[kind], mapping) {
+			changed = true
+		}
+		// I valori assegnati ai campi personalizzati sono indicizzati per ID del campo
+		if kind == KindCustomFields && rewriteValueKeys(action, "assign_custom_fields_values", mapping) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// rewriteMailRule sostituisce gli ID assegnati dalla regola e indica se qualcosa è cambiato
+func rewriteMailRule(rule *paperless.MailRule, kind Kind, mapping map[int]int) bool {
+	switch kind {
+	case KindTags:
+		tags, changed := mapIDs(rule.AssignTags, mapping)
+		rule.AssignTags = tags
+		return changed
+	case KindCorrespondents:
+		return mapIDPointer(rule.AssignCorrespondent, mapping)
+	case KindDocumentTypes:
+		return mapIDPointer(rule.AssignDocumentType, mapping)
+	}
+	return false
+}
+
+// rewriteFields sostituisce gli ID nei campi indicati di un oggetto generico.
+// Un campo può contenere un singolo ID (o null) oppure una lista di ID.
+func rewriteFields(obj map[string]json.RawMessage, keys []string, mapping map[int]int) bool {
+	changed := false
+	for _, key := range keys {
+		raw, ok := obj[key]
+		if !ok || isNull(raw) {
+			continue
+		}
+
+		var id int
+		if json.Unmarshal(raw, &id) == nil {
+			if newID, ok := mapping[id]; ok {
+				obj[key], _ = json.Marshal(newID)
+				changed = true
+			}
+			continue
+		}
+
+		var ids []int
+		if json.Unmarshal(raw, &ids) == nil {
+			if newIDs, ok := mapIDs(ids, mapping); ok {
+				obj[key], _ = json.Marshal(newIDs)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// rewriteValueKeys sostituisce gli ID usati come chiavi di un oggetto JSON
+func rewriteValueKeys(obj map[string]json.RawMessage, key string, mapping map[int]int) bool {
+	raw, ok := obj[key]
+	if !ok || isNull(raw) {
+		return false
+	}
+
+	var values map[string]json.RawMessage
+	if json.Unmarshal(raw, &values) != nil {
+		return false
+	}
+
+	changed := false
+	result := make(map[string]json.RawMessage, len(values))
+	for k, v := range values {
+		if id, err := strconv.Atoi(k); err == nil {
+			if newID, ok := mapping[id]; ok {
+				newKey := strconv.Itoa(newID)
+				// Il valore già assegnato al sopravvissuto ha la precedenza
+				if _, exists := values[newKey]; !exists {
+					result[newKey] = v
+				}
+				changed = true
+				continue
+			}
+		}
+		result[k] = v
+	}
+
+	if changed {
+		obj[key], _ = json.Marshal(result)
+	}
+	return changed
+}
+
+// mapIDs sostituisce gli ID di una lista rimuovendo i duplicati
+func mapIDs(ids []int, mapping map[int]int) ([]int, bool) {
+	changed := false
+	result := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if newID, ok := mapping[id]; ok {
+			id = newID
+			changed = true
+		}
+		if !containsID(result, id) {
+			result = append(result, id)
+		}
+	}
+	if !changed {
+		return ids, false
+	}
+	return result, true
+}
+
+// mapIDPointer sostituisce un ID opzionale
+func mapIDPointer(id *int, mapping map[int]int) bool {
+	if id == nil {
+		return false
+	}
+	if newID, ok := mapping[*id]; ok {
+		*id = newID
+		return true
+	}
+	return false
+}
+
+// convertReference riscrive un riferimento a un tag convertito verso la destinazione della
+// conversione (corrispondente o tipo documento), partendo dalla sua versione originale.
+// Dove la destinazione non trova posto (il campo ha già un altro valore o il server non lo
+// espone) il tag viene solo tolto: in quel caso dropped è true.
+func convertReference(ctx context.Context, client *paperless.Client, ref Reference, tagID int, target Kind, targetID int) (dropped bool, err error) {
+	switch ref.Source {
+	case RefSavedView:
+		var view paperless.SavedView
+		if err := json.Unmarshal(ref.Original, &view); err != nil {
+			return false, err
+		}
+		convertSavedView(&view, tagID, target, targetID)
+		return false, client.UpdateSavedViewFilterRules(ctx, view.ID, view.FilterRules)
+
+	case RefWorkflow:
+		var workflow paperless.Workflow
+		if err := json.Unmarshal(ref.Original, &workflow); err != nil {
+			return false, err
+		}
+		dropped = convertWorkflow(&workflow, tagID, target, targetID)
+		return dropped, client.UpdateWorkflow(ctx, workflow.ID, workflow.Triggers, workflow.Actions)
+
+	case RefMailRule:
+		var rule paperless.MailRule
+		if err := json.Unmarshal(ref.Original, &rule); err != nil {
+			return false, err
+		}
+		dropped = convertMailRule(&rule, tagID, target, targetID)
+		return dropped, client.UpdateMailRule(ctx, rule)
+	}
+	return false, nil
+}
+
+// convertSavedView trasforma le regole di filtro sul tag in regole sulla destinazione:
+// "ha il tag" diventa "ha il corrispondente" (o tipo), "ha uno dei tag" diventa "ha uno dei
+// corrispondenti" e "non ha il tag" diventa "non ha il corrispondente". Un filtro sul tag
+// insieme ad altri tag ne perde solo uno, quindi le regole restano sempre applicabili.
+func convertSavedView(view *paperless.SavedView, tagID int, target Kind, targetID int) {
+	// Tipi di regola del tag (vedi filterRuleTypes) -> posizione del tipo della destinazione
+	converted := map[int]int{6: 0, 22: 1, 17: 2}
+
+	tagValue := strconv.Itoa(tagID)
+	for i, rule := range view.FilterRules {
+		if rule.Value == nil || *rule.Value != tagValue {
+			continue
+		}
+		if j, ok := converted[rule.RuleType]; ok {
+			value := strconv.Itoa(targetID)
+			view.FilterRules[i] = paperless.FilterRule{RuleType: filterRuleTypes[target][j], Value: &value}
+		}
+	}
+	// Le regole duplicate che si creano vengono rimosse
+	rewriteSavedView(view, target, nil)
+}
+
+// convertWorkflow toglie il tag da trigger e azioni e vi mette la destinazione: i filtri
+// e le assegnazioni del tag passano al campo singolo della destinazione, se è vuoto, e le
+// esclusioni e rimozioni si aggiungono alla lista corrispondente. Indica se in qualche
+// campo il tag è stato solo tolto.
+func convertWorkflow(workflow *paperless.Workflow, tagID int, target Kind, targetID int) bool {
+	dropped := false
+	move := func(obj map[string]json.RawMessage, from, to string, single bool) {
+		if removed, moved := moveID(obj, from, to, single, tagID, targetID); removed && !moved {
+			dropped = true
+		}
+	}
+
+	for _, trigger := range workflow.Triggers {
+		move(trigger, "filter_has_tags", triggerFields[target][0], true)
+		move(trigger, "filter_has_all_tags", triggerFields[target][0], true)
+		move(trigger, "filter_has_not_tags", triggerFields[target][1], false)
+	}
+	for _, action := range workflow.Actions {
+		move(action, "assign_tags", actionFields[target][0], true)
+		move(action, "remove_tags", actionFields[target][1], false)
+	}
+	return dropped
+}
+
+// convertMailRule toglie il tag da quelli assegnati dalla regola e assegna la destinazione,
+// se la regola non assegna già un altro valore. Indica se il tag è stato solo tolto.
+func convertMailRule(rule *paperless.MailRule, tagID int, target Kind, targetID int) bool {
+	if !containsID(rule.AssignTags, tagID) {
+		return false
+	}
+	tags := make([]int, 0, len(rule.AssignTags))
+	for _, id := range rule.AssignTags {
+		if id != tagID {
+			tags = append(tags, id)
+		}
+	}
+	rule.AssignTags = tags
+
+	assigned := &rule.AssignCorrespondent
+	if target == KindDocumentTypes {
+		assigned = &rule.AssignDocumentType
+	}
+	switch {
+	case *assigned == nil:
+		*assigned = &targetID
+	case **assigned != targetID:
+		return true
+	}
+	return false
+}
+
+// moveID toglie tagID dalla lista del campo from e, se c'era, mette targetID nel campo to:
+// un campo singolo viene impostato solo se vuoto, in una lista l'ID viene aggiunto.
+// Restituisce se il tag è stato tolto e se la destinazione è stata impostata.
+func moveID(obj map[string]json.RawMessage, from, to string, single bool, tagID, targetID int) (removed, moved bool) {
+	var ids []int
+	if raw, ok := obj[from]; !ok || json.Unmarshal(raw, &ids) != nil || !containsID(ids, tagID) {
+		return false, false
+	}
+	remaining := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id != tagID {
+			remaining = append(remaining, id)
+		}
+	}
+	obj[from], _ = json.Marshal(remaining)
+
+	// Un campo che il server non espone non viene aggiunto
+	raw, ok := obj[to]
+	if !ok {
+		return true, false
+	}
+
+	if single {
+		var current int
+		switch {
+		case isNull(raw):
+			obj[to], _ = json.Marshal(targetID)
+			return true, true
+		case json.Unmarshal(raw, &current) == nil && current == targetID:
+			return true, true
+		}
+		return true, false
+	}
+
+	var list []int
+	if !isNull(raw) && json.Unmarshal(raw, &list) != nil {
+		return true, false
+	}
+	if !containsID(list, targetID) {
+		list = append(list, targetID)
+	}
+	obj[to], _ = json.Marshal(list)
+	return true, true
+}
@@ -0,0 +1,300 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// RenameOp indica come una regola di rinomina trasforma i nomi
+type RenameOp int
+
+const (
+	RenameReplace   RenameOp = iota // Sostituisce le corrispondenze dell'espressione regolare (con $1, $2... nella sostituzione)
+	RenamePrefix                    // Aggiunge un prefisso, se manca
+	RenameSuffix                    // Aggiunge un suffisso, se manca
+	RenameTitleCase                 // Iniziale maiuscola per ogni parola, il resto minuscolo
+	RenameLowerCase                 // Tutto minuscolo
+	RenameUpperCase                 // Tutto maiuscolo
+)
+
+// RenameOps elenca le operazioni disponibili, nell'ordine in cui vengono proposte
+var RenameOps = []RenameOp{RenameReplace, RenamePrefix, RenameSuffix, RenameTitleCase, RenameLowerCase, RenameUpperCase}
+
+// ErrEmptyAffix indica che il prefisso o suffisso di una regola è vuoto
+var ErrEmptyAffix = errors.New("il prefisso o suffisso non può essere vuoto")
+
+// RenameRule è una regola di rinomina; le regole si applicano in sequenza
+type RenameRule struct {
+	Op          RenameOp
+	Pattern     string // Espressione regolare (RenameReplace) o testo da aggiungere (RenamePrefix, RenameSuffix)
+	Replacement string // Sostituzione (solo RenameReplace)
+
+	re *regexp.Regexp
+}
+
+// NewRenameRule costruisce una regola verificandone i parametri
+func NewRenameRule(op RenameOp, pattern, replacement string) (RenameRule, error) {
+	rule := RenameRule{Op: op, Pattern: pattern, Replacement: replacement}
+
+	switch op {
+	case RenameReplace:
+		re, err := regexp.Compile(pattern)
+		if err != nil || pattern == "" {
+			return RenameRule{}, fmt.Errorf("%w: %s", ErrInvalidPattern, pattern)
+		}
+		rule.re = re
+	case RenamePrefix, RenameSuffix:
+		if strings.TrimSpace(pattern) == "" {
+			return RenameRule{}, ErrEmptyAffix
+		}
+	}
+
+	return rule, nil
+}
+
+// UsesPattern indica se l'operazione richiede un'espressione regolare o un testo
+func (op RenameOp) UsesPattern() bool {
+	return op == RenameReplace || op == RenamePrefix || op == RenameSuffix
+}
+
+// Apply applica la regola a un nome
+func (r RenameRule) Apply(name string) string {
+	switch r.Op {
+	case RenameReplace:
+		if r.re != nil {
+			return r.re.ReplaceAllString(name, r.Replacement)
+		}
+	case RenamePrefix:
+		if !strings.HasPrefix(name, r.Pattern) {
+			return r.Pattern + name
+		}
+	case RenameSuffix:
+		if !strings.HasSuffix(name, r.Pattern) {
+			return name + r.Pattern
+		}
+	case RenameTitleCase:
+		return titleCase(name)
+	case RenameLowerCase:
+		return strings.ToLower(name)
+	case RenameUpperCase:
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+// ApplyRenameRules applica le regole in sequenza e toglie gli spazi agli estremi
+func ApplyRenameRules(rules []RenameRule, name string) string {
+	for _, rule := range rules {
+		name = rule.Apply(name)
+	}
+	return strings.TrimSpace(name)
+}
+
+// titleCase mette in maiuscolo la prima lettera di ogni parola e in minuscolo le altre
+func titleCase(name string) string {
+	runes := []rune(name)
+	inWord := false
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if inWord {
+				runes[i] = unicode.ToLower(r)
+			} else {
+				runes[i] = unicode.ToUpper(r)
+			}
+			inWord = true
+		} else {
+			inWord = r == '\''
+		}
+	}
+	return string(runes)
+}
+
+// Rename è la rinomina di un elemento
+type Rename struct {
+	Item    similarity.SimilarItem
+	NewName string
+}
+
+// Collision raggruppa gli elementi che dopo la rinomina avrebbero lo stesso nome
+// (senza distinguere maiuscole e minuscole). Il primo elemento è quello che occupa
+// già il nome, se c'è.
+type Collision struct {
+	Name  string // Nome comune: quello dell'elemento che non cambia nome, se c'è
+	Items []Rename
+}
+
+// RenamePlan descrive una rinomina di massa
+type RenamePlan struct {
+	Kind       Kind
+	Renames    []Rename    // Rinomine senza collisioni
+	Collisions []Collision // Gruppi di elementi che finirebbero con lo stesso nome
+	Empty      []Rename    // Elementi il cui nome diventerebbe vuoto (non vengono rinominati)
+}
+
+// NewRenamePlan applica le regole agli elementi indicati da scope (tutti se scope è vuoto)
+// e cerca le collisioni con tutti gli elementi dello stesso tipo. Un elemento in collisione
+// mantiene il nome attuale finché la collisione non viene risolta con un merge, quindi
+// può a sua volta far collidere altre rinomine.
+func NewRenamePlan(kind Kind, items []similarity.SimilarItem, scope []int, rules []RenameRule) RenamePlan {
+	plan := RenamePlan{Kind: kind}
+
+	wanted := make([]string, len(items)) // Nome voluto dalle regole
+	names := make([]string, len(items))  // Nome che l'elemento avrà davvero
+	for i, item := range items {
+		wanted[i] = item.Name
+		if len(scope) == 0 || containsID(scope, item.ID) {
+			wanted[i] = ApplyRenameRules(rules, item.Name)
+			if wanted[i] == "" {
+				plan.Empty = append(plan.Empty, Rename{Item: item})
+				wanted[i] = item.Name
+			}
+		}
+		names[i] = wanted[i]
+	}
+
+	// Gli elementi rinominati che finiscono su un nome già usato tornano al nome attuale,
+	// finché non ci sono più nomi ripetuti
+	colliding := make([]bool, len(items))
+	for changed := true; changed; {
+		changed = false
+		count := make(map[string]int)
+		for _, name := range names {
+			count[strings.ToLower(name)]++
+		}
+		for i, item := range items {
+			if names[i] != item.Name && count[strings.ToLower(names[i])] > 1 {
+				colliding[i] = true
+				names[i] = item.Name
+				changed = true
+			}
+		}
+	}
+
+	for i, item := range items {
+		if !colliding[i] && names[i] != item.Name {
+			plan.Renames = append(plan.Renames, Rename{Item: item, NewName: names[i]})
+		}
+	}
+
+	// Le collisioni raggruppano gli elementi che vogliono lo stesso nome e quello che lo occupa
+	index := make(map[string]int)
+	for i, item := range items {
+		if !colliding[i] {
+			continue
+		}
+		key := strings.ToLower(wanted[i])
+		n, ok := index[key]
+		if !ok {
+			n = len(plan.Collisions)
+			index[key] = n
+			plan.Collisions = append(plan.Collisions, Collision{Name: wanted[i]})
+		}
+		plan.Collisions[n].Items = append(plan.Collisions[n].Items, Rename{Item: item, NewName: wanted[i]})
+	}
+	for i, item := range items {
+		key := strings.ToLower(names[i])
+		if colliding[i] && key == strings.ToLower(wanted[i]) {
+			continue
+		}
+		if n, ok := index[key]; ok {
+			plan.Collisions[n].Name = names[i]
+			plan.Collisions[n].Items = append([]Rename{{Item: item, NewName: names[i]}}, plan.Collisions[n].Items...)
+		}
+	}
+
+	return plan
+}
+
+// MergePlan costruisce il piano che unisce gli elementi in collisione con il nome comune
+func (p RenamePlan) MergePlan(c Collision) (Plan, error) {
+	items := make([]similarity.SimilarItem, len(c.Items))
+	for i, r := range c.Items {
+		items[i] = r.Item
+	}
+	return NewPlan(p.Kind, items, 0, c.Name)
+}
+
+// RenameResult riassume una rinomina di massa completata
+type RenameResult struct {
+	Renamed    int         // Elementi rinominati
+	Merged     int         // Collisioni risolte con un merge
+	References []Reference // Viste salvate, workflow e regole mail riscritti dai merge
+	// Differenze trovate dalla verifica dei merge
+	Discrepancies []Discrepancy
+}
+
+// Rename applica le rinomine del piano e poi esegue i merge delle collisioni indicate.
+// Un elemento che prende il nome attuale di un altro elemento rinominato passa prima
+// da un nome temporaneo, così da non violare l'unicità dei nomi sul server.
+// Le rinomine non vengono registrate nel journal; i merge sì.
+func (e *Executor) Rename(ctx context.Context, plan RenamePlan, merges []Plan) (RenameResult, error) {
+	var result RenameResult
+	ctx = safeContext(ctx)
+
+	// Nomi attuali degli elementi rinominati
+	oldNames := make(map[string]bool)
+	for _, r := range plan.Renames {
+		oldNames[strings.ToLower(r.Item.Name)] = true
+	}
+
+	var deferred []Rename
+	current := 0
+	total := len(plan.Renames)
+	for _, r := range plan.Renames {
+		if oldNames[strings.ToLower(r.NewName)] && !strings.EqualFold(r.NewName, r.Item.Name) {
+			total++
+		}
+	}
+
+	// Step 1: Rinomina diretta, o verso il nome temporaneo se il nome finale è ancora occupato
+	for _, r := range plan.Renames {
+		current++
+		e.report(Progress{Step: StepRename, Current: current, Total: total, Item: current, Items: total})
+
+		name := r.NewName
+		if oldNames[strings.ToLower(r.NewName)] && !strings.EqualFold(r.NewName, r.Item.Name) {
+			name = tempName(r.Item.ID, r.NewName)
+			deferred = append(deferred, r)
+		}
+		if err := renameItem(ctx, e.client, plan.Kind, r.Item.ID, name); err != nil {
+			return result, &StepError{Step: StepRename, ItemID: r.Item.ID, Name: name, Err: err}
+		}
+		if name == r.NewName {
+			result.Renamed++
+		}
+	}
+
+	// Step 2: Nome finale degli elementi passati dal nome temporaneo
+	for _, r := range deferred {
+		current++
+		e.report(Progress{Step: StepRename, Current: current, Total: total, Item: current, Items: total})
+
+		if err := renameItem(ctx, e.client, plan.Kind, r.Item.ID, r.NewName); err != nil {
+			return result, &StepError{Step: StepRename, ItemID: r.Item.ID, Name: r.NewName, Err: err}
+		}
+		result.Renamed++
+	}
+
+	// Step 3: Merge delle collisioni. Le rinomine non si fermano a metà (un elemento resterebbe
+	// col nome temporaneo); un annullamento si ferma prima del merge successivo.
+	for _, mergePlan := range merges {
+		if err := stopped(ctx); err != nil {
+			return result, err
+		}
+		merged, err := e.Execute(ctx, mergePlan)
+		if err != nil {
+			return result, err
+		}
+		result.Merged++
+		result.References = append(result.References, merged.References...)
+		result.Discrepancies = append(result.Discrepancies, merged.Discrepancies...)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,402 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// SplitCriterion indica come una regola di suddivisione sceglie i documenti
+type SplitCriterion int
+
+const (
+	SplitByCorrespondent SplitCriterion = iota // Documenti di un corrispondente
+	SplitByTitle                               // Documenti il cui titolo corrisponde a un'espressione regolare
+	SplitByDate                                // Documenti con data in un intervallo
+)
+
+var (
+	// ErrSplitUnsupported indica che il tipo di entità non può essere suddiviso
+	ErrSplitUnsupported = errors.New("i campi personalizzati non possono essere suddivisi")
+	// ErrUnknownCorrespondent indica che il corrispondente della regola non esiste
+	ErrUnknownCorrespondent = errors.New("corrispondente sconosciuto")
+	// ErrInvalidPattern indica che l'espressione regolare della regola non è valida
+	ErrInvalidPattern = errors.New("espressione regolare non valida")
+	// ErrInvalidDateRange indica che l'intervallo di date della regola non è valido
+	ErrInvalidDateRange = errors.New("intervallo di date non valido (usa AAAA-MM-GG..AAAA-MM-GG)")
+	// ErrSplitIntoSource indica che la destinazione di una regola è l'elemento da suddividere
+	ErrSplitIntoSource = errors.New("la destinazione non può essere l'elemento da suddividere")
+)
+
+// dateLayout è il formato delle date negli intervalli delle regole
+const dateLayout = "2006-01-02"
+
+// SplitCriteria restituisce i criteri utilizzabili per il tipo di entità:
+// i documenti di un corrispondente hanno tutti lo stesso corrispondente
+func SplitCriteria(kind Kind) []SplitCriterion {
+	if kind == KindCorrespondents {
+		return []SplitCriterion{SplitByTitle, SplitByDate}
+	}
+	return []SplitCriterion{SplitByCorrespondent, SplitByTitle, SplitByDate}
+}
+
+// SplitRule assegna a Target i documenti che soddisfano il criterio
+type SplitRule struct {
+	Criterion SplitCriterion
+	Value     string // Valore inserito: nome del corrispondente, espressione regolare o intervallo di date
+	Target    string // Nome della destinazione: se esiste già un elemento con questo nome i documenti passano a quello
+
+	correspondentID int
+	pattern         *regexp.Regexp
+	from, to        time.Time // Estremi inclusi (zero se aperti)
+}
+
+// SplitSource contiene l'elemento da suddividere con i suoi documenti e quanto serve
+// per costruire e valutare le regole
+type SplitSource struct {
+	Kind           Kind
+	Item           Item
+	Documents      []paperless.Document
+	Items          []Item // Elementi esistenti dello stesso tipo (possibili destinazioni)
+	Correspondents []Item
+}
+
+// Split descrive una suddivisione da eseguire. Ogni documento va alla prima regola che
+// soddisfa; i documenti che non soddisfano nessuna regola restano sull'elemento.
+type Split struct {
+	Kind     Kind
+	SourceID int
+	Rules    []SplitRule
+}
+
+// SplitTarget riassume cosa è successo alla destinazione di una regola
+type SplitTarget struct {
+	ID      int
+	Created bool // true se la destinazione è stata creata dalla suddivisione
+	Moved   int  // Documenti spostati sulla destinazione
+}
+
+// SplitResult riassume una suddivisione completata
+type SplitResult struct {
+	Targets   []SplitTarget // Una per regola, nello stesso ordine
+	Remaining int           // Documenti rimasti sull'elemento suddiviso
+}
+
+// LoadSplit legge l'elemento da suddividere, i suoi documenti, gli elementi dello
+// stesso tipo e i corrispondenti
+func LoadSplit(ctx context.Context, client *paperless.Client, kind Kind, id int) (SplitSource, error) {
+	if kind == KindCustomFields {
+		return SplitSource{}, ErrSplitUnsupported
+	}
+
+	source := SplitSource{Kind: kind}
+
+	item, err := fetchItem(ctx, client, kind, id)
+	if err != nil {
+		return SplitSource{}, &StepError{Step: StepSnapshot, ItemID: id, Err: err}
+	}
+	source.Item = item
+
+	docs, err := itemDocuments(ctx, client, kind, id)
+	if err != nil {
+		return SplitSource{}, &StepError{Step: StepGetDocuments, ItemID: id, Err: err}
+	}
+	source.Documents = docs
+
+	items, err := listItems(ctx, client, kind)
+	if err != nil {
+		return SplitSource{}, &StepError{Step: StepSnapshot, ItemID: id, Err: err}
+	}
+	source.Items = items
+
+	correspondents, err := listItems(ctx, client, KindCorrespondents)
+	if err != nil {
+		return SplitSource{}, &StepError{Step: StepSnapshot, ItemID: id, Err: err}
+	}
+	source.Correspondents = correspondents
+
+	return source, nil
+}
+
+// NewRule costruisce una regola verificandone il valore e la destinazione
+func (s SplitSource) NewRule(criterion SplitCriterion, value, target string) (SplitRule, error) {
+	rule := SplitRule{Criterion: criterion, Value: strings.TrimSpace(value), Target: strings.TrimSpace(target)}
+
+	if rule.Target == "" {
+		return SplitRule{}, ErrEmptyName
+	}
+	if strings.EqualFold(rule.Target, strings.TrimSpace(s.Item.Name)) {
+		return SplitRule{}, ErrSplitIntoSource
+	}
+
+	switch criterion {
+	case SplitByCorrespondent:
+		corr := itemByName(s.Correspondents, rule.Value)
+		if corr == nil {
+			return SplitRule{}, fmt.Errorf("%w: %s", ErrUnknownCorrespondent, rule.Value)
+		}
+		rule.correspondentID = corr.ID
+
+	case SplitByTitle:
+		// I titoli vengono confrontati senza distinguere maiuscole e minuscole
+		pattern, err := regexp.Compile("(?i)" + rule.Value)
+		if err != nil || rule.Value == "" {
+			return SplitRule{}, fmt.Errorf("%w: %s", ErrInvalidPattern, rule.Value)
+		}
+		rule.pattern = pattern
+
+	case SplitByDate:
+		from, to, err := parseDateRange(rule.Value)
+		if err != nil {
+			return SplitRule{}, err
+		}
+		rule.from, rule.to = from, to
+	}
+
+	return rule, nil
+}
+
+// Target restituisce l'elemento esistente con il nome indicato (nil se verrà creato)
+func (s SplitSource) Target(name string) *Item {
+	return itemByName(s.Items, name)
+}
+
+// Assign distribuisce i documenti tra le regole: restituisce i documenti di ogni regola,
+// nello stesso ordine delle regole, e quelli che restano sull'elemento
+func (s SplitSource) Assign(rules []SplitRule) ([][]paperless.Document, []paperless.Document) {
+	return assignDocuments(s.Documents, rules)
+}
+
+// Matches indica se il documento soddisfa la regola
+func (r SplitRule) Matches(doc paperless.Document) bool {
+	switch r.Criterion {
+	case SplitByCorrespondent:
+		return doc.Correspondent != nil && *doc.Correspondent == r.correspondentID
+	case SplitByTitle:
+		return r.pattern != nil && r.pattern.MatchString(doc.Title)
+	case SplitByDate:
+		date, ok := documentDate(doc)
+		if !ok {
+			return false
+		}
+		return (r.from.IsZero() || !date.Before(r.from)) && (r.to.IsZero() || !date.After(r.to))
+	}
+	return false
+}
+
+// Split suddivide un elemento: i documenti di ogni regola passano alla sua destinazione,
+// che viene creata se non esiste. I documenti vengono riletti e ridistribuiti al momento
+// dell'esecuzione. Le suddivisioni non vengono registrate nel journal: se vengono annullate
+// le modifiche già eseguite vengono ripristinate e l'errore è ErrRolledBack.
+func (e *Executor) Split(ctx context.Context, split Split) (SplitResult, error) {
+	var result SplitResult
+
+	if split.Kind == KindCustomFields {
+		return result, ErrSplitUnsupported
+	}
+	ctx = safeContext(ctx)
+
+	current := 0
+	total := 1 + 2*len(split.Rules)
+
+	// Step 1: Lettura dell'elemento, dei suoi documenti e delle destinazioni esistenti
+	current++
+	e.report(Progress{Step: StepGetDocuments, Current: current, Total: total, Item: 1, Items: 1})
+
+	source, err := fetchItem(ctx, e.client, split.Kind, split.SourceID)
+	if err != nil {
+		return result, &StepError{Step: StepSnapshot, ItemID: split.SourceID, Err: err}
+	}
+	docs, err := itemDocuments(ctx, e.client, split.Kind, split.SourceID)
+	if err != nil {
+		return result, &StepError{Step: StepGetDocuments, ItemID: split.SourceID, Err: err}
+	}
+	items, err := listItems(ctx, e.client, split.Kind)
+	if err != nil {
+		return result, &StepError{Step: StepSnapshot, ItemID: split.SourceID, Err: err}
+	}
+
+	groups, rest := assignDocuments(docs, split.Rules)
+	result.Remaining = len(rest)
+
+	// Regole con la stessa destinazione condividono l'elemento creato
+	created := make(map[string]int)
+
+	// Un annullamento, controllato prima di ogni regola e tra un blocco di documenti e
+	// l'altro, riporta l'istanza allo stato precedente
+	rollback := func(targets []SplitTarget) (SplitResult, error) {
+		e.report(Progress{Step: StepRollback, Current: current, Total: total})
+		if err := e.rollbackSplit(ctx, split, groups, targets); err != nil {
+			return result, err
+		}
+		return SplitResult{}, ErrRolledBack
+	}
+
+	for i, rule := range split.Rules {
+		target := SplitTarget{}
+		if stopped(ctx) != nil {
+			return rollback(result.Targets)
+		}
+
+		// Creazione della destinazione, se non esiste
+		current++
+		key := strings.ToLower(rule.Target)
+		if existing := itemByName(items, rule.Target); existing != nil {
+			target.ID = existing.ID
+		} else if id, ok := created[key]; ok {
+			target.ID = id
+		} else {
+			e.report(Progress{Step: StepCreate, Current: current, Total: total, Item: i + 1, Items: len(split.Rules)})
+
+			id, err := createItem(ctx, e.client, split.Kind, Item{
+				Name:  rule.Target,
+				Color: source.Color,
+				Path:  source.Path,
+				Owner: source.Owner,
+			})
+			if err != nil {
+				return result, &StepError{Step: StepCreate, ItemID: split.SourceID, Err: err}
+			}
+			created[key] = id
+			target.ID = id
+			target.Created = true
+		}
+
+		// Spostamento dei documenti della regola
+		current++
+		if len(groups[i]) > 0 {
+			progress := Progress{Step: StepUpdateDocuments, Current: current, Total: total, Item: i + 1, Items: len(split.Rules), Documents: len(groups[i])}
+			e.report(progress)
+
+			if docID, err := e.moveDocuments(ctx, split.Kind, documentIDs(groups[i]), split.SourceID, target.ID, e.documentProgress(progress, nil)); err != nil {
+				if stopped(ctx) != nil {
+					return rollback(append(result.Targets, target))
+				}
+				return result, &StepError{Step: StepUpdateDocuments, ItemID: split.SourceID, DocumentID: docID, Err: err}
+			}
+			target.Moved = len(groups[i])
+		}
+
+		result.Targets = append(result.Targets, target)
+	}
+
+	return result, nil
+}
+
+// rollbackSplit ripristina lo stato precedente a una suddivisione annullata: i documenti
+// delle regole già avviate (targets, nell'ordine delle regole) tornano sull'elemento
+// suddiviso e le destinazioni create vengono eliminate. I tag che un documento aveva già
+// prima della suddivisione restano. I passi sono ripetibili sui documenti non ancora spostati.
+func (e *Executor) rollbackSplit(ctx context.Context, split Split, groups [][]paperless.Document, targets []SplitTarget) error {
+	ctx = withoutStop(ctx)
+
+	for i, target := range targets {
+		var moved, kept []int
+		for _, doc := range groups[i] {
+			if split.Kind == KindTags && !target.Created && hasTag(doc, target.ID) {
+				kept = append(kept, doc.ID)
+			} else {
+				moved = append(moved, doc.ID)
+			}
+		}
+
+		if docID, err := e.restoreDocuments(ctx, split.Kind, moved, target.ID, split.SourceID, false, nil); err != nil {
+			return &StepError{Step: StepRollback, ItemID: split.SourceID, DocumentID: docID, Err: err}
+		}
+		if docID, err := e.restoreDocuments(ctx, split.Kind, kept, target.ID, split.SourceID, true, nil); err != nil {
+			return &StepError{Step: StepRollback, ItemID: split.SourceID, DocumentID: docID, Err: err}
+		}
+	}
+
+	// Più regole possono condividere la stessa destinazione creata
+	deleted := make(map[int]bool)
+	for _, target := range targets {
+		if !target.Created || deleted[target.ID] {
+			continue
+		}
+		if err := deleteItem(ctx, e.client, split.Kind, target.ID); err != nil && !paperless.IsNotFound(err) {
+			return &StepError{Step: StepRollback, ItemID: target.ID, Err: err}
+		}
+		deleted[target.ID] = true
+	}
+	return nil
+}
+
+// assignDocuments assegna ogni documento alla prima regola che soddisfa
+func assignDocuments(docs []paperless.Document, rules []SplitRule) ([][]paperless.Document, []paperless.Document) {
+	groups := make([][]paperless.Document, len(rules))
+	var rest []paperless.Document
+
+	for _, doc := range docs {
+		assigned := false
+		for i, rule := range rules {
+			if rule.Matches(doc) {
+				groups[i] = append(groups[i], doc)
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			rest = append(rest, doc)
+		}
+	}
+	return groups, rest
+}
+
+// parseDateRange interpreta un intervallo "AAAA-MM-GG..AAAA-MM-GG"; uno dei due estremi
+// può mancare, una data singola indica un solo giorno
+func parseDateRange(value string) (time.Time, time.Time, error) {
+	start, end, isRange := strings.Cut(value, "..")
+	if !isRange {
+		end = start
+	}
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if start == "" && end == "" {
+		return time.Time{}, time.Time{}, ErrInvalidDateRange
+	}
+
+	var from, to time.Time
+	var err error
+	if start != "" {
+		if from, err = time.Parse(dateLayout, start); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDateRange
+		}
+	}
+	if end != "" {
+		if to, err = time.Parse(dateLayout, end); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDateRange
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return time.Time{}, time.Time{}, ErrInvalidDateRange
+	}
+	return from, to, nil
+}
+
+// documentDate restituisce la data del documento, ignorando l'eventuale ora
+func documentDate(doc paperless.Document) (time.Time, bool) {
+	if len(doc.Created) < len(dateLayout) {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(dateLayout, doc.Created[:len(dateLayout)])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// itemByName cerca un elemento per nome senza distinguere maiuscole e minuscole
+func itemByName(items []Item, name string) *Item {
+	name = strings.TrimSpace(name)
+	for i := range items {
+		if strings.EqualFold(strings.TrimSpace(items[i].Name), name) {
+			return &items[i]
+		}
+	}
+	return nil
+}
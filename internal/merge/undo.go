@@ -0,0 +1,156 @@
+package merge
+
+import (
+	"context"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// Undo annulla un merge registrato nel journal: ripristina nome, regola di matching e
+// attributi originali del sopravvissuto, ricrea gli elementi eliminati con nome, colore e regole di matching
+// originali e riassegna loro esattamente i documenti spostati dal merge.
+// In caso di errore l'undo può essere ripetuto: gli elementi già ricreati non vengono duplicati.
+// Una pulizia viene annullata ricreando gli elementi eliminati.
+func (e *Executor) Undo(ctx context.Context, j *Journal) error {
+	if !j.Undoable() {
+		return ErrNothingToUndo
+	}
+	ctx = safeContext(ctx)
+	if j.Cleanup {
+		return e.undoCleanup(ctx, j)
+	}
+
+	kind := j.Plan.Kind
+	current := 0
+	total := 1 + len(j.Absorbed)
+	if len(j.References) > 0 {
+		total++
+	}
+	if j.Plan.Matching != nil {
+		total++
+	}
+	if len(j.Plan.Sources) > 0 {
+		total++
+	}
+	for _, absorbed := range j.Absorbed {
+		if absorbed.Deleted && absorbed.RestoredID == 0 {
+			total++
+		}
+	}
+
+	// Il nome originale del sopravvissuto va ripristinato per primo: il nome finale
+	// potrebbe coincidere con quello di un elemento da ricreare
+	current++
+	e.report(Progress{Step: StepRestoreSurvivor, Current: current, Total: total})
+
+	if err := renameItem(ctx, e.client, kind, j.Survivor.ID, j.Survivor.Name); err != nil {
+		return &StepError{Step: StepRestoreSurvivor, ItemID: j.Survivor.ID, Err: err}
+	}
+
+	if j.Plan.Matching != nil {
+		current++
+		e.report(Progress{Step: StepRestoreMatching, Current: current, Total: total})
+
+		if err := updateMatching(ctx, e.client, kind, j.Survivor.ID, j.Survivor.MatchRule()); err != nil {
+			return &StepError{Step: StepRestoreMatching, ItemID: j.Survivor.ID, Err: err}
+		}
+	}
+
+	if len(j.Plan.Sources) > 0 {
+		current++
+		e.report(Progress{Step: StepRestoreAttributes, Current: current, Total: total})
+
+		if err := updateAttributes(ctx, e.client, kind, j.Survivor.ID, j.Survivor, j.Plan.sourceAttributes()); err != nil {
+			return &StepError{Step: StepRestoreAttributes, ItemID: j.Survivor.ID, Err: err}
+		}
+	}
+
+	// I documenti che avevano il sopravvissuto prima del merge lo mantengono anche dopo
+	// l'undo; agli altri viene tolto da ogni elemento ripristinato
+	hadSurvivor := j.survivorDocuments()
+	for idx := range j.Absorbed {
+		// Un annullamento si ferma tra un elemento e l'altro: l'undo resta da ripetere
+		if err := stopped(ctx); err != nil {
+			return err
+		}
+		absorbed := &j.Absorbed[idx]
+
+		// Se l'elemento non è stato eliminato i documenti tornano all'ID originale
+		restoredID := absorbed.Item.ID
+		if absorbed.Deleted {
+			if absorbed.RestoredID == 0 {
+				current++
+				e.report(Progress{Step: StepRecreate, Current: current, Total: total, Item: idx + 1, Items: len(j.Absorbed)})
+
+				id, err := createItem(ctx, e.client, kind, absorbed.Item)
+				if err != nil {
+					return &StepError{Step: StepRecreate, ItemID: absorbed.Item.ID, Err: err}
+				}
+				absorbed.RestoredID = id
+				if err := e.saveJournal(j); err != nil {
+					return err
+				}
+			}
+			restoredID = absorbed.RestoredID
+		}
+
+		current++
+		progress := Progress{Step: StepRestoreDocuments, Current: current, Total: total, Item: idx + 1, Items: len(j.Absorbed), Documents: len(absorbed.Documents)}
+		e.report(progress)
+		done := e.documentProgress(progress, nil)
+
+		if kind == KindCustomFields {
+			if docID, err := e.restoreFieldValues(ctx, absorbed, hadSurvivor, j.Survivor.ID, restoredID, done); err != nil {
+				return &StepError{Step: StepRestoreDocuments, ItemID: absorbed.Item.ID, DocumentID: docID, Err: err}
+			}
+			continue
+		}
+
+		// I documenti che avevano già il sopravvissuto lo mantengono
+		var moved, kept []int
+		for _, docID := range absorbed.Documents {
+			if hadSurvivor[docID] {
+				kept = append(kept, docID)
+			} else {
+				moved = append(moved, docID)
+			}
+		}
+
+		if docID, err := e.restoreDocuments(ctx, kind, moved, j.Survivor.ID, restoredID, false, done); err != nil {
+			return &StepError{Step: StepRestoreDocuments, ItemID: absorbed.Item.ID, DocumentID: docID, Err: err}
+		}
+		if docID, err := e.restoreDocuments(ctx, kind, kept, j.Survivor.ID, restoredID, true, done); err != nil {
+			return &StepError{Step: StepRestoreDocuments, ItemID: absorbed.Item.ID, DocumentID: docID, Err: err}
+		}
+	}
+
+	// Viste salvate, workflow e regole mail tornano alla versione precedente al merge,
+	// con gli elementi ricreati al posto di quelli eliminati
+	if len(j.References) > 0 {
+		current++
+		e.report(Progress{Step: StepRestoreReferences, Current: current, Total: total})
+
+		mapping := make(map[int]int, len(j.Absorbed))
+		for _, absorbed := range j.Absorbed {
+			mapping[absorbed.Item.ID] = absorbed.Item.ID
+			if absorbed.Deleted {
+				mapping[absorbed.Item.ID] = absorbed.RestoredID
+			}
+		}
+		for _, ref := range j.References {
+			if err := applyReference(ctx, e.client, kind, ref, mapping); err != nil {
+				return &StepError{Step: StepRestoreReferences, ItemID: j.Survivor.ID, Err: err}
+			}
+		}
+	}
+
+	// Le opzioni aggiunte al select sopravvissuto non servono più
+	if kind == KindCustomFields && j.Survivor.DataType == paperless.CustomFieldSelect {
+		if err := e.client.UpdateCustomFieldExtraData(ctx, j.Survivor.ID, j.Survivor.ExtraData); err != nil {
+			return &StepError{Step: StepRestoreSurvivor, ItemID: j.Survivor.ID, Err: err}
+		}
+	}
+
+	j.Status = StatusUndone
+	return e.saveJournal(j)
+}
@@ -0,0 +1,174 @@
+package merge
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/paperless/fake"
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// newTestClient avvia un server finto con la fixture e restituisce un client collegato
+func newTestClient(t *testing.T, fixture *fake.Fixture) *paperless.Client {
+	t.Helper()
+
+	srv, err := fake.NewServer(fixture)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	return paperless.NewClient(ts.URL, fake.DefaultToken)
+}
+
+// documentTags restituisce i tag del documento in ordine crescente
+func documentTags(t *testing.T, client *paperless.Client, docID int) []int {
+	t.Helper()
+
+	doc, err := client.GetDocument(context.Background(), docID)
+	if err != nil {
+		t.Fatalf("GetDocument(%d): %v", docID, err)
+	}
+	tags := append([]int{}, doc.Tags...)
+	sort.Ints(tags)
+	return tags
+}
+
+// tagFixture ha il sopravvissuto S=1, gli assorbiti A=2 e B=3 e un tag estraneo X=4
+func tagFixture(disableBulkEdit bool) *fake.Fixture {
+	return &fake.Fixture{
+		DisableBulkEdit: disableBulkEdit,
+		Tags: []paperless.Tag{
+			{ID: 1, Name: "S"},
+			{ID: 2, Name: "A"},
+			{ID: 3, Name: "B"},
+			{ID: 4, Name: "X"},
+		},
+		Documents: []paperless.Document{
+			{ID: 10, Title: "A, B e X", Tags: []int{2, 3, 4}},
+			{ID: 11, Title: "Solo A", Tags: []int{2}},
+			{ID: 12, Title: "S e B", Tags: []int{1, 3}},
+			{ID: 13, Title: "S, A e B", Tags: []int{1, 2, 3}},
+			{ID: 14, Title: "Solo X", Tags: []int{4}},
+		},
+	}
+}
+
+func TestUndoTagsRestoresEveryAbsorbedTag(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		disableBulkEdit bool
+	}{
+		{"bulk_edit", false},
+		{"per documento", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := newTestClient(t, tagFixture(tc.disableBulkEdit))
+			executor := NewExecutor(client, NewJournalStore(t.TempDir()), nil)
+
+			plan, err := NewPlan(KindTags, []similarity.SimilarItem{{ID: 1, Name: "S"}, {ID: 2, Name: "A"}, {ID: 3, Name: "B"}}, 1, "S")
+			if err != nil {
+				t.Fatalf("NewPlan: %v", err)
+			}
+			result, err := executor.Execute(ctx, plan)
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if len(result.Discrepancies) > 0 {
+				t.Fatalf("discrepanze dopo il merge: %v", result.Discrepancies)
+			}
+
+			merged := map[int][]int{10: {1, 4}, 11: {1}, 12: {1}, 13: {1}, 14: {4}}
+			for docID, want := range merged {
+				if got := documentTags(t, client, docID); !reflect.DeepEqual(got, want) {
+					t.Errorf("dopo il merge documento %d: tag %v, attesi %v", docID, got, want)
+				}
+			}
+
+			journal, err := executor.journal.LastUndoable()
+			if err != nil {
+				t.Fatalf("LastUndoable: %v", err)
+			}
+			if err := executor.Undo(ctx, journal); err != nil {
+				t.Fatalf("Undo: %v", err)
+			}
+
+			// A e B vengono ricreati come 5 e 6
+			restored := map[int][]int{10: {4, 5, 6}, 11: {5}, 12: {1, 6}, 13: {1, 5, 6}, 14: {4}}
+			for docID, want := range restored {
+				if got := documentTags(t, client, docID); !reflect.DeepEqual(got, want) {
+					t.Errorf("dopo l'undo documento %d: tag %v, attesi %v", docID, got, want)
+				}
+			}
+		})
+	}
+}
+
+// fieldValues restituisce i valori dei campi personalizzati del documento per campo
+func fieldValues(t *testing.T, client *paperless.Client, docID int) map[int]string {
+	t.Helper()
+
+	doc, err := client.GetDocument(context.Background(), docID)
+	if err != nil {
+		t.Fatalf("GetDocument(%d): %v", docID, err)
+	}
+	values := make(map[int]string, len(doc.CustomFields))
+	for _, f := range doc.CustomFields {
+		values[f.Field] = string(f.Value)
+	}
+	return values
+}
+
+func TestUndoCustomFieldsRestoresEveryAbsorbedValue(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, &fake.Fixture{
+		CustomFields: []paperless.CustomField{
+			{ID: 1, Name: "S", DataType: "string"},
+			{ID: 2, Name: "A", DataType: "string"},
+			{ID: 3, Name: "B", DataType: "string"},
+		},
+		Documents: []paperless.Document{
+			{ID: 10, Title: "A e B", CustomFields: []paperless.CustomFieldInstance{{Field: 2, Value: []byte(`"a"`)}, {Field: 3, Value: []byte(`"b"`)}}},
+			{ID: 11, Title: "S e B", CustomFields: []paperless.CustomFieldInstance{{Field: 1, Value: []byte(`"s"`)}, {Field: 3, Value: []byte(`"b"`)}}},
+		},
+	})
+	executor := NewExecutor(client, NewJournalStore(t.TempDir()), nil)
+
+	plan, err := NewPlan(KindCustomFields, []similarity.SimilarItem{{ID: 1, Name: "S"}, {ID: 2, Name: "A"}, {ID: 3, Name: "B"}}, 1, "S")
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if _, err := executor.Execute(ctx, plan); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	// Il primo valore spostato resta sul sopravvissuto, come quello già presente
+	merged := map[int]map[int]string{10: {1: `"a"`}, 11: {1: `"s"`}}
+	for docID, want := range merged {
+		if got := fieldValues(t, client, docID); !reflect.DeepEqual(got, want) {
+			t.Errorf("dopo il merge documento %d: valori %v, attesi %v", docID, got, want)
+		}
+	}
+
+	journal, err := executor.journal.LastUndoable()
+	if err != nil {
+		t.Fatalf("LastUndoable: %v", err)
+	}
+	if err := executor.Undo(ctx, journal); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	// A e B vengono ricreati come 4 e 5
+	restored := map[int]map[int]string{10: {4: `"a"`, 5: `"b"`}, 11: {1: `"s"`, 5: `"b"`}}
+	for docID, want := range restored {
+		if got := fieldValues(t, client, docID); !reflect.DeepEqual(got, want) {
+			t.Errorf("dopo l'undo documento %d: valori %v, attesi %v", docID, got, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package merge
+
+import (
+	"context"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// DiscrepancyKind identifica il tipo di differenza trovata dalla verifica di un merge
+type DiscrepancyKind int
+
+const (
+	DiscrepancyRemaining  DiscrepancyKind = iota // Un elemento assorbito è ancora usato da documenti
+	DiscrepancyNotDeleted                        // Un elemento assorbito esiste ancora
+	DiscrepancyDocuments                         // Il sopravvissuto non ha i documenti attesi
+	DiscrepancyName                              // Il sopravvissuto non ha il nome finale
+)
+
+// Discrepancy è una differenza tra lo stato atteso a fine merge e quello letto dal server
+type Discrepancy struct {
+	Kind      DiscrepancyKind `json:"kind"`
+	ItemID    int             `json:"item_id"`
+	Expected  int             `json:"expected,omitempty"`   // Documenti attesi sul sopravvissuto (DiscrepancyDocuments)
+	Actual    int             `json:"actual,omitempty"`     // Documenti trovati
+	Missing   []int           `json:"missing,omitempty"`    // Documenti attesi che non usano il sopravvissuto
+	Name      string          `json:"name,omitempty"`       // Nome attuale del sopravvissuto (DiscrepancyName)
+	FinalName string          `json:"final_name,omitempty"` // Nome finale atteso (DiscrepancyName)
+}
+
+// verify rilegge dal server gli elementi del piano a merge concluso: gli assorbiti devono
+// essere eliminati e senza documenti, il sopravvissuto deve avere il nome finale ed
+// esattamente i documenti attesi (i suoi più quelli spostati). Le differenze vengono
+// restituite, non trattate come errori: il merge è comunque avvenuto.
+func (e *Executor) verify(ctx context.Context, plan Plan, expected []int) ([]Discrepancy, error) {
+	var discrepancies []Discrepancy
+
+	for _, id := range plan.AbsorbIDs {
+		docs, err := itemDocuments(ctx, e.client, plan.Kind, id)
+		if err != nil {
+			return nil, &StepError{Step: StepVerify, ItemID: id, Err: err}
+		}
+		if len(docs) > 0 {
+			discrepancies = append(discrepancies, Discrepancy{Kind: DiscrepancyRemaining, ItemID: id, Actual: len(docs)})
+		}
+
+		_, err = fetchItem(ctx, e.client, plan.Kind, id)
+		if err == nil {
+			discrepancies = append(discrepancies, Discrepancy{Kind: DiscrepancyNotDeleted, ItemID: id})
+		} else if !paperless.IsNotFound(err) {
+			return nil, &StepError{Step: StepVerify, ItemID: id, Err: err}
+		}
+	}
+
+	survivor, err := fetchItem(ctx, e.client, plan.Kind, plan.SurvivorID)
+	if err != nil {
+		return nil, &StepError{Step: StepVerify, ItemID: plan.SurvivorID, Err: err}
+	}
+	if survivor.Name != plan.FinalName {
+		discrepancies = append(discrepancies, Discrepancy{Kind: DiscrepancyName, ItemID: plan.SurvivorID, Name: survivor.Name, FinalName: plan.FinalName})
+	}
+
+	docs, err := itemDocuments(ctx, e.client, plan.Kind, plan.SurvivorID)
+	if err != nil {
+		return nil, &StepError{Step: StepVerify, ItemID: plan.SurvivorID, Err: err}
+	}
+	actual := make(map[int]bool, len(docs))
+	for _, doc := range docs {
+		actual[doc.ID] = true
+	}
+	var missing []int
+	for _, id := range expected {
+		if !actual[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(actual) != len(expected) || len(missing) > 0 {
+		discrepancies = append(discrepancies, Discrepancy{
+			Kind:     DiscrepancyDocuments,
+			ItemID:   plan.SurvivorID,
+			Expected: len(expected),
+			Actual:   len(actual),
+			Missing:  missing,
+		})
+	}
+
+	return discrepancies, nil
+}
+
+// expectedDocuments restituisce i documenti che il sopravvissuto deve avere a fine merge:
+// quelli che aveva già più quelli degli elementi assorbiti, senza ripetizioni
+func expectedDocuments(survivorDocs []int, absorbedDocs ...[]int) []int {
+	var expected []int
+	seen := make(map[int]bool)
+	for _, ids := range append([][]int{survivorDocs}, absorbedDocs...) {
+		for _, id := range ids {
+			if !seen[id] {
+				expected = append(expected, id)
+				seen[id] = true
+			}
+		}
+	}
+	return expected
+}
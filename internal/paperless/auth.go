@@ -0,0 +1,73 @@
+package paperless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrMFARequired indica che l'account usa l'autenticazione a due fattori:
+// il token va creato dal profilo utente di Paperless-ngx
+var ErrMFARequired = errors.New("l'account richiede l'autenticazione a due fattori")
+
+// tokenPayload è il corpo JSON di /api/token/
+type tokenPayload struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// tokenResponse è la risposta di /api/token/
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+// ObtainToken chiede al server il token API dell'utente (POST /api/token/).
+// La password viene solo inviata al server, mai conservata dal client.
+// Restituisce ErrMFARequired se l'account ha l'autenticazione a due fattori.
+func (c *Client) ObtainToken(ctx context.Context, username, password string) (string, error) {
+	data, err := json.Marshal(tokenPayload{Username: username, Password: password})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/api/token/", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError("errore nell'accesso con nome utente e password", resp)
+		if mfaRequired(apiErr) {
+			return "", fmt.Errorf("%w: %v", ErrMFARequired, apiErr)
+		}
+		return "", apiErr
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		return "", errors.New("il server non ha restituito un token")
+	}
+	return token.Token, nil
+}
+
+// mfaRequired indica se il server ha rifiutato l'accesso perché manca il codice
+// dell'autenticazione a due fattori ("MFA code is required", Paperless 2.14+)
+func mfaRequired(err *APIError) bool {
+	if err.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	for _, message := range err.Messages() {
+		if strings.Contains(strings.ToLower(message), "mfa") {
+			return true
+		}
+	}
+	return false
+}
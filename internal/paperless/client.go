@@ -1,49 +1,325 @@
 package paperless
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Client rappresenta il client per l'API di Paperless-ngx
 type Client struct {
 	BaseURL string
 	APIKey  string
-	client  *http.Client
+	// DryRun impedisce l'invio di richieste che modificano i dati (tutto tranne GET):
+	// vengono registrate e simulate con una risposta di successo
+	DryRun bool
+	client *http.Client
+
+	retries       int           // Tentativi aggiuntivi per gli errori temporanei
+	maxRetryDelay time.Duration // Attesa massima tra due tentativi, anche se Retry-After chiede di più
+	limiter       *limiter      // Limite di richieste al secondo e contemporanee
+	proxy         ProxyAuth     // Credenziali del reverse proxy davanti al server
+
+	mu          sync.Mutex
+	skipped     []string // Richieste non inviate in modalità dry-run
+	simulatedID int      // Ultimo ID provvisorio dato a un oggetto creato in dry-run
 }
 
+// Algoritmi di matching di Paperless
+const (
+	MatchNone    = 0 // Nessuna assegnazione automatica
+	MatchAny     = 1 // Una qualsiasi delle parole
+	MatchAll     = 2 // Tutte le parole
+	MatchLiteral = 3 // Corrispondenza esatta
+	MatchRegex   = 4 // Espressione regolare
+	MatchFuzzy   = 5 // Corrispondenza approssimata
+	MatchAuto    = 6 // Apprendimento automatico
+)
+
 // Tag rappresenta un tag di Paperless
 type Tag struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Color string `json:"colour"`
-	Match string `json:"match"`
+	ID                int         `json:"id"`
+	Name              string      `json:"name"`
+	Color             string      `json:"colour"`
+	TextColor         string      `json:"text_color"` // Colore del testo scelto dal server per lo sfondo
+	Match             string      `json:"match"`
+	MatchingAlgorithm int         `json:"matching_algorithm"`
+	IsInsensitive     bool        `json:"is_insensitive"`
+	IsInboxTag        bool        `json:"is_inbox_tag"`
+	Owner             *int        `json:"owner"`
+	Parent            *int        `json:"parent"`   // Tag padre (tag gerarchici, Paperless 2.x)
+	Children          TagChildren `json:"children"` // Tag figli (tag gerarchici, Paperless 2.x)
+	DocumentCount     int         `json:"document_count"`
+
+	Permissions   *Permissions `json:"permissions"`
+	UserCanChange *bool        `json:"user_can_change"`
 }
 
 // Correspondent rappresenta un corrispondente di Paperless
 type Correspondent struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Match string `json:"match"`
+	ID                 int     `json:"id"`
+	Name               string  `json:"name"`
+	Match              string  `json:"match"`
+	MatchingAlgorithm  int     `json:"matching_algorithm"`
+	IsInsensitive      bool    `json:"is_insensitive"`
+	Owner              *int    `json:"owner"`
+	DocumentCount      int     `json:"document_count"`
+	LastCorrespondence *string `json:"last_correspondence"` // Data dell'ultimo documento (nil se nessuno)
+
+	Permissions   *Permissions `json:"permissions"`
+	UserCanChange *bool        `json:"user_can_change"`
 }
 
 // DocumentType rappresenta un tipo di documento di Paperless
 type DocumentType struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Match string `json:"match"`
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	Match             string `json:"match"`
+	MatchingAlgorithm int    `json:"matching_algorithm"`
+	IsInsensitive     bool   `json:"is_insensitive"`
+	Owner             *int   `json:"owner"`
+	DocumentCount     int    `json:"document_count"`
+
+	Permissions   *Permissions `json:"permissions"`
+	UserCanChange *bool        `json:"user_can_change"`
+}
+
+// Permissions sono gli utenti e i gruppi a cui è concesso un oggetto oltre al proprietario.
+// Il server li restituisce solo se richiesti con full_perms=true, che però toglie
+// user_can_change: gli elenchi non li richiedono e lasciano Permissions a nil.
+type Permissions struct {
+	View   PermissionSet `json:"view"`
+	Change PermissionSet `json:"change"`
+}
+
+// PermissionSet elenca gli utenti e i gruppi con un permesso
+type PermissionSet struct {
+	Users  []int `json:"users"`
+	Groups []int `json:"groups"`
+}
+
+// TagChildren sono gli ID dei tag figli. A seconda della versione il server li
+// restituisce come ID o come tag annidati, di cui viene tenuto solo l'ID.
+type TagChildren []int
+
+func (c *TagChildren) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	children := make(TagChildren, 0, len(raw))
+	for _, child := range raw {
+		var id int
+		if err := json.Unmarshal(child, &id); err == nil {
+			children = append(children, id)
+			continue
+		}
+		var tag struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(child, &tag); err != nil {
+			return err
+		}
+		children = append(children, tag.ID)
+	}
+	*c = children
+	return nil
+}
+
+// tagPayload è il corpo JSON per la creazione di un tag
+type tagPayload struct {
+	Name              string `json:"name"`
+	Color             string `json:"colour,omitempty"`
+	Match             string `json:"match"`
+	MatchingAlgorithm int    `json:"matching_algorithm"`
+	IsInsensitive     bool   `json:"is_insensitive"`
+	IsInboxTag        bool   `json:"is_inbox_tag"`
+	Owner             *int   `json:"owner,omitempty"`
+	Parent            *int   `json:"parent,omitempty"`
+}
+
+// itemPayload è il corpo JSON per la creazione di corrispondenti e tipi documento
+type itemPayload struct {
+	Name              string `json:"name"`
+	Match             string `json:"match"`
+	MatchingAlgorithm int    `json:"matching_algorithm"`
+	IsInsensitive     bool   `json:"is_insensitive"`
+	Owner             *int   `json:"owner,omitempty"`
+}
+
+// StoragePath rappresenta un percorso di archiviazione di Paperless
+type StoragePath struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	Path              string `json:"path"` // Template del percorso, es. "{correspondent}/{title}"
+	Match             string `json:"match"`
+	MatchingAlgorithm int    `json:"matching_algorithm"`
+	IsInsensitive     bool   `json:"is_insensitive"`
+	Owner             *int   `json:"owner"`
+	DocumentCount     int    `json:"document_count"`
+
+	Permissions   *Permissions `json:"permissions"`
+	UserCanChange *bool        `json:"user_can_change"`
+}
+
+// Tipi di dato dei campi personalizzati
+const (
+	CustomFieldSelect = "select"
+)
+
+// CustomField rappresenta un campo personalizzato di Paperless (2.x)
+type CustomField struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	DataType  string          `json:"data_type"`            // string, integer, select, monetary, ...
+	ExtraData json.RawMessage `json:"extra_data,omitempty"` // Per i select contiene "select_options"
+}
+
+// CustomFieldInstance è il valore di un campo personalizzato su un documento
+type CustomFieldInstance struct {
+	Field int             `json:"field"`
+	Value json.RawMessage `json:"value"`
 }
 
 // Document rappresenta un documento di Paperless
 type Document struct {
 	ID            int    `json:"id"`
 	Title         string `json:"title"`
+	Created       string `json:"created"` // Data del documento (YYYY-MM-DD o data e ora ISO 8601)
 	Correspondent *int   `json:"correspondent"`
 	DocumentType  *int   `json:"document_type"`
+	StoragePath   *int   `json:"storage_path"`
 	Tags          []int  `json:"tags"`
+
+	CustomFields []CustomFieldInstance `json:"custom_fields"`
+}
+
+// ErrBulkEditUnsupported indica che il server non espone /api/documents/bulk_edit/
+var ErrBulkEditUnsupported = errors.New("bulk_edit non supportato dal server")
+
+// storagePathPayload è il corpo JSON per la creazione di un percorso di archiviazione
+type storagePathPayload struct {
+	Name              string `json:"name"`
+	Path              string `json:"path"`
+	Match             string `json:"match"`
+	MatchingAlgorithm int    `json:"matching_algorithm"`
+	IsInsensitive     bool   `json:"is_insensitive"`
+	Owner             *int   `json:"owner,omitempty"`
+}
+
+// matchingPayload è il corpo JSON per aggiornare le regole di matching di un elemento
+type matchingPayload struct {
+	Match             string `json:"match"`
+	MatchingAlgorithm int    `json:"matching_algorithm"`
+	IsInsensitive     bool   `json:"is_insensitive"`
+}
+
+// customFieldPayload è il corpo JSON per la creazione di un campo personalizzato
+type customFieldPayload struct {
+	Name      string          `json:"name"`
+	DataType  string          `json:"data_type"`
+	ExtraData json.RawMessage `json:"extra_data,omitempty"`
+}
+
+// namePayload è il corpo JSON per rinominare un elemento
+type namePayload struct {
+	Name string `json:"name"`
+}
+
+// extraDataPayload è il corpo JSON per sostituire i dati aggiuntivi di un campo personalizzato
+type extraDataPayload struct {
+	ExtraData json.RawMessage `json:"extra_data"`
+}
+
+// tagColorPayload è il corpo JSON per aggiornare il colore di un tag
+type tagColorPayload struct {
+	Color string `json:"colour"`
+}
+
+// tagInboxPayload è il corpo JSON per aggiornare il flag inbox di un tag
+type tagInboxPayload struct {
+	IsInboxTag bool `json:"is_inbox_tag"`
+}
+
+// tagParentPayload è il corpo JSON per spostare un tag (null per la radice)
+type tagParentPayload struct {
+	Parent *int `json:"parent"`
+}
+
+// ownerPayload è il corpo JSON per assegnare il proprietario di un elemento (null per nessuno)
+type ownerPayload struct {
+	Owner *int `json:"owner"`
+}
+
+// documentTagsPayload è il corpo JSON per sostituire i tag di un documento
+type documentTagsPayload struct {
+	Tags []int `json:"tags"`
+}
+
+// documentCorrespondentPayload è il corpo JSON per aggiornare il corrispondente di un documento
+type documentCorrespondentPayload struct {
+	Correspondent *int `json:"correspondent"`
+}
+
+// documentTypePayload è il corpo JSON per aggiornare il tipo di un documento
+type documentTypePayload struct {
+	DocumentType *int `json:"document_type"`
+}
+
+// documentStoragePathPayload è il corpo JSON per aggiornare il percorso di archiviazione di un documento
+type documentStoragePathPayload struct {
+	StoragePath *int `json:"storage_path"`
+}
+
+// documentCustomFieldsPayload è il corpo JSON per sostituire i campi personalizzati di un documento
+type documentCustomFieldsPayload struct {
+	CustomFields []CustomFieldInstance `json:"custom_fields"`
+}
+
+// bulkEditRequest è il corpo JSON di /api/documents/bulk_edit/
+type bulkEditRequest struct {
+	Documents  []int       `json:"documents"`
+	Method     string      `json:"method"`
+	Parameters interface{} `json:"parameters"`
+}
+
+// Parametri delle operazioni di bulk_edit
+type modifyTagsParameters struct {
+	AddTags    []int `json:"add_tags"`
+	RemoveTags []int `json:"remove_tags"`
+}
+
+type setCorrespondentParameters struct {
+	Correspondent *int `json:"correspondent"`
+}
+
+type setDocumentTypeParameters struct {
+	DocumentType *int `json:"document_type"`
+}
+
+type setStoragePathParameters struct {
+	StoragePath *int `json:"storage_path"`
+}
+
+// optionalID restituisce l'ID da inviare per un riferimento, nil (null) per 0
+func optionalID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// createdObject è la parte della risposta di una creazione con l'ID assegnato
+type createdObject struct {
+	ID int `json:"id"`
 }
 
 // ListResponse rappresenta la risposta paginata dell'API
@@ -54,44 +330,140 @@ type ListResponse struct {
 	Results  json.RawMessage `json:"results"`
 }
 
+// DefaultTimeout è il tempo massimo di attesa predefinito per una richiesta
+const DefaultTimeout = 30 * time.Second
+
 // NewClient crea un nuovo client per Paperless-ngx
 func NewClient(baseURL, apiKey string) *Client {
 	return &Client{
-		BaseURL: strings.TrimRight(baseURL, "/"),
-		APIKey:  apiKey,
-		client:  &http.Client{},
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		APIKey:        apiKey,
+		client:        &http.Client{Timeout: DefaultTimeout, CheckRedirect: sameHostRedirect},
+		retries:       DefaultRetries,
+		maxRetryDelay: MaxRetryDelay,
+		limiter:       &limiter{},
 	}
 }
 
-// makeRequest esegue una richiesta HTTP all'API
-func (c *Client) makeRequest(method, endpoint string, body io.Reader) (*http.Response, error) {
+// SetTimeout imposta il tempo massimo di attesa di ogni richiesta (0 per nessun limite)
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.client.Timeout = timeout
+}
+
+// makeRequest esegue una richiesta HTTP all'API, ripetendola in caso di errori temporanei
+func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body io.Reader) (*http.Response, error) {
+	// Un'operazione annullata non invia altre richieste, neanche simulate
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.DryRun && method != http.MethodGet {
+		return c.skipRequest(method, endpoint, body), nil
+	}
+
+	// Il corpo viene letto una volta sola per poterlo reinviare nei tentativi successivi
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+
 	url := fmt.Sprintf("%s%s", c.BaseURL, endpoint)
-	req, err := http.NewRequest(method, url, body)
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, url, data)
+		wait, retry := c.retryDelay(ctx, method, resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send invia un singolo tentativo della richiesta rispettando i limiti del client
+func (c *Client) send(ctx context.Context, method, url string, data []byte) (*http.Response, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Token %s", c.APIKey))
+	c.authorize(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	return c.client.Do(req)
+	release, err := c.limiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// skipRequest registra una richiesta non inviata in modalità dry-run
+// e restituisce la risposta di successo che il server avrebbe dato
+func (c *Client) skipRequest(method, endpoint string, body io.Reader) *http.Response {
+	entry := fmt.Sprintf("%s %s", method, endpoint)
+	if body != nil {
+		if data, err := io.ReadAll(body); err == nil && len(data) > 0 {
+			entry += " " + string(data)
+		}
+	}
+
+	c.mu.Lock()
+	c.skipped = append(c.skipped, entry)
+	c.mu.Unlock()
+
+	status := http.StatusOK
+	if method == http.MethodDelete {
+		status = http.StatusNoContent
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader([]byte("{}"))),
+	}
+}
+
+// SkippedRequests restituisce le richieste simulate in modalità dry-run
+func (c *Client) SkippedRequests() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	skipped := make([]string, len(c.skipped))
+	copy(skipped, c.skipped)
+	return skipped
 }
 
 // GetTags recupera tutti i tags con paginazione automatica
-func (c *Client) GetTags() ([]Tag, error) {
+func (c *Client) GetTags(ctx context.Context) ([]Tag, error) {
 	var allTags []Tag
 	endpoint := "/api/tags/?page_size=1000"
 
 	for endpoint != "" {
-		resp, err := c.makeRequest("GET", endpoint, nil)
+		resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
 		if err != nil {
 			return nil, err
 		}
 		
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
+			apiErr := newAPIError("errore API", resp)
 			resp.Body.Close()
-			return nil, fmt.Errorf("errore API: %d - %s", resp.StatusCode, string(body))
+			return nil, apiErr
 		}
 
 		var listResp ListResponse
@@ -121,20 +493,20 @@ func (c *Client) GetTags() ([]Tag, error) {
 }
 
 // GetCorrespondents recupera tutti i corrispondenti con paginazione automatica
-func (c *Client) GetCorrespondents() ([]Correspondent, error) {
+func (c *Client) GetCorrespondents(ctx context.Context) ([]Correspondent, error) {
 	var allCorrespondents []Correspondent
 	endpoint := "/api/correspondents/?page_size=1000"
 
 	for endpoint != "" {
-		resp, err := c.makeRequest("GET", endpoint, nil)
+		resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
 		if err != nil {
 			return nil, err
 		}
 		
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
+			apiErr := newAPIError("errore API", resp)
 			resp.Body.Close()
-			return nil, fmt.Errorf("errore API: %d - %s", resp.StatusCode, string(body))
+			return nil, apiErr
 		}
 
 		var listResp ListResponse
@@ -163,20 +535,20 @@ func (c *Client) GetCorrespondents() ([]Correspondent, error) {
 }
 
 // GetDocumentTypes recupera tutti i tipi di documento con paginazione automatica
-func (c *Client) GetDocumentTypes() ([]DocumentType, error) {
+func (c *Client) GetDocumentTypes(ctx context.Context) ([]DocumentType, error) {
 	var allDocTypes []DocumentType
 	endpoint := "/api/document_types/?page_size=1000"
 
 	for endpoint != "" {
-		resp, err := c.makeRequest("GET", endpoint, nil)
+		resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
 		if err != nil {
 			return nil, err
 		}
 		
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
+			apiErr := newAPIError("errore API", resp)
 			resp.Body.Close()
-			return nil, fmt.Errorf("errore API: %d - %s", resp.StatusCode, string(body))
+			return nil, apiErr
 		}
 
 		var listResp ListResponse
@@ -204,138 +576,476 @@ func (c *Client) GetDocumentTypes() ([]DocumentType, error) {
 	return allDocTypes, nil
 }
 
-// UpdateTag aggiorna un tag
-func (c *Client) UpdateTag(id int, name string) error {
-	body := strings.NewReader(fmt.Sprintf(`{"name": "%s"}`, name))
-	resp, err := c.makeRequest("PATCH", fmt.Sprintf("/api/tags/%d/", id), body)
-	if err != nil {
-		return err
+// GetStoragePaths recupera tutti i percorsi di archiviazione con paginazione automatica
+func (c *Client) GetStoragePaths(ctx context.Context) ([]StoragePath, error) {
+	var allStoragePaths []StoragePath
+	endpoint := "/api/storage_paths/?page_size=1000"
+
+	for endpoint != "" {
+		resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			apiErr := newAPIError("errore API", resp)
+			resp.Body.Close()
+			return nil, apiErr
+		}
+
+		var listResp ListResponse
+		if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+
+		var storagePaths []StoragePath
+		if err := json.Unmarshal(listResp.Results, &storagePaths); err != nil {
+			return nil, err
+		}
+
+		allStoragePaths = append(allStoragePaths, storagePaths...)
+
+		// Se c'è una pagina successiva, prepara l'endpoint per la prossima iterazione
+		if listResp.Next != nil && *listResp.Next != "" {
+			endpoint = strings.TrimPrefix(*listResp.Next, c.BaseURL)
+		} else {
+			endpoint = ""
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'aggiornamento del tag: %d - %s", resp.StatusCode, string(respBody))
+	return allStoragePaths, nil
+}
+
+// GetCustomFields recupera tutti i campi personalizzati con paginazione automatica
+func (c *Client) GetCustomFields(ctx context.Context) ([]CustomField, error) {
+	var allFields []CustomField
+	endpoint := "/api/custom_fields/?page_size=1000"
+
+	for endpoint != "" {
+		resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			apiErr := newAPIError("errore API", resp)
+			resp.Body.Close()
+			return nil, apiErr
+		}
+
+		var listResp ListResponse
+		if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+
+		var fields []CustomField
+		if err := json.Unmarshal(listResp.Results, &fields); err != nil {
+			return nil, err
+		}
+
+		allFields = append(allFields, fields...)
+
+		// Se c'è una pagina successiva, prepara l'endpoint per la prossima iterazione
+		if listResp.Next != nil && *listResp.Next != "" {
+			endpoint = strings.TrimPrefix(*listResp.Next, c.BaseURL)
+		} else {
+			endpoint = ""
+		}
 	}
 
+	return allFields, nil
+}
+
+// UpdateTag aggiorna un tag
+func (c *Client) UpdateTag(ctx context.Context, id int, name string) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/tags/%d/", id), namePayload{Name: name}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del tag: %w", err)
+	}
 	return nil
 }
 
 // UpdateCorrespondent aggiorna un corrispondente
-func (c *Client) UpdateCorrespondent(id int, name string) error {
-	body := strings.NewReader(fmt.Sprintf(`{"name": "%s"}`, name))
-	resp, err := c.makeRequest("PATCH", fmt.Sprintf("/api/correspondents/%d/", id), body)
+func (c *Client) UpdateCorrespondent(ctx context.Context, id int, name string) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/correspondents/%d/", id), namePayload{Name: name}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del corrispondente: %w", err)
+	}
+	return nil
+}
+
+// UpdateDocumentType aggiorna un tipo di documento
+func (c *Client) UpdateDocumentType(ctx context.Context, id int, name string) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/document_types/%d/", id), namePayload{Name: name}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del tipo documento: %w", err)
+	}
+	return nil
+}
+
+// UpdateStoragePath aggiorna un percorso di archiviazione
+func (c *Client) UpdateStoragePath(ctx context.Context, id int, name string) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/storage_paths/%d/", id), namePayload{Name: name}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del percorso di archiviazione: %w", err)
+	}
+	return nil
+}
+
+// UpdateCustomField aggiorna un campo personalizzato
+func (c *Client) UpdateCustomField(ctx context.Context, id int, name string) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/custom_fields/%d/", id), namePayload{Name: name}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del campo personalizzato: %w", err)
+	}
+	return nil
+}
+
+// UpdateCustomFieldExtraData sostituisce i dati aggiuntivi di un campo personalizzato
+// (per i select, l'elenco delle opzioni)
+func (c *Client) UpdateCustomFieldExtraData(ctx context.Context, id int, extraData json.RawMessage) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/custom_fields/%d/", id), extraDataPayload{ExtraData: extraData}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del campo personalizzato: %w", err)
+	}
+	return nil
+}
+
+// UpdateTagMatching aggiorna le regole di matching di un tag
+func (c *Client) UpdateTagMatching(ctx context.Context, id int, match string, algorithm int, insensitive bool) error {
+	return c.updateMatching(ctx, fmt.Sprintf("/api/tags/%d/", id), match, algorithm, insensitive)
+}
+
+// UpdateCorrespondentMatching aggiorna le regole di matching di un corrispondente
+func (c *Client) UpdateCorrespondentMatching(ctx context.Context, id int, match string, algorithm int, insensitive bool) error {
+	return c.updateMatching(ctx, fmt.Sprintf("/api/correspondents/%d/", id), match, algorithm, insensitive)
+}
+
+// UpdateDocumentTypeMatching aggiorna le regole di matching di un tipo documento
+func (c *Client) UpdateDocumentTypeMatching(ctx context.Context, id int, match string, algorithm int, insensitive bool) error {
+	return c.updateMatching(ctx, fmt.Sprintf("/api/document_types/%d/", id), match, algorithm, insensitive)
+}
+
+// UpdateStoragePathMatching aggiorna le regole di matching di un percorso di archiviazione
+func (c *Client) UpdateStoragePathMatching(ctx context.Context, id int, match string, algorithm int, insensitive bool) error {
+	return c.updateMatching(ctx, fmt.Sprintf("/api/storage_paths/%d/", id), match, algorithm, insensitive)
+}
+
+// UpdateTagColor aggiorna il colore di un tag
+func (c *Client) UpdateTagColor(ctx context.Context, id int, color string) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/tags/%d/", id), tagColorPayload{Color: color}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del tag: %w", err)
+	}
+	return nil
+}
+
+// UpdateTagInbox imposta o toglie il flag "tag della posta in arrivo" di un tag
+func (c *Client) UpdateTagInbox(ctx context.Context, id int, inbox bool) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/tags/%d/", id), tagInboxPayload{IsInboxTag: inbox}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del tag: %w", err)
+	}
+	return nil
+}
+
+// UpdateTagParent sposta un tag sotto il tag padre indicato (nil per la radice)
+func (c *Client) UpdateTagParent(ctx context.Context, id int, parent *int) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/tags/%d/", id), tagParentPayload{Parent: parent}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del tag: %w", err)
+	}
+	return nil
+}
+
+// UpdateTagOwner assegna il proprietario di un tag (nil per nessun proprietario)
+func (c *Client) UpdateTagOwner(ctx context.Context, id int, owner *int) error {
+	return c.updateOwner(ctx, fmt.Sprintf("/api/tags/%d/", id), owner)
+}
+
+// UpdateCorrespondentOwner assegna il proprietario di un corrispondente
+func (c *Client) UpdateCorrespondentOwner(ctx context.Context, id int, owner *int) error {
+	return c.updateOwner(ctx, fmt.Sprintf("/api/correspondents/%d/", id), owner)
+}
+
+// UpdateDocumentTypeOwner assegna il proprietario di un tipo documento
+func (c *Client) UpdateDocumentTypeOwner(ctx context.Context, id int, owner *int) error {
+	return c.updateOwner(ctx, fmt.Sprintf("/api/document_types/%d/", id), owner)
+}
+
+// UpdateStoragePathOwner assegna il proprietario di un percorso di archiviazione
+func (c *Client) UpdateStoragePathOwner(ctx context.Context, id int, owner *int) error {
+	return c.updateOwner(ctx, fmt.Sprintf("/api/storage_paths/%d/", id), owner)
+}
+
+// updateOwner invia il proprietario all'endpoint dell'elemento
+func (c *Client) updateOwner(ctx context.Context, endpoint string, owner *int) error {
+	if err := c.patchObject(ctx, endpoint, ownerPayload{Owner: owner}); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del proprietario: %w", err)
+	}
+	return nil
+}
+
+// updateMatching invia le regole di matching all'endpoint dell'elemento
+func (c *Client) updateMatching(ctx context.Context, endpoint, match string, algorithm int, insensitive bool) error {
+	err := c.patchObject(ctx, endpoint, matchingPayload{
+		Match:             match,
+		MatchingAlgorithm: algorithm,
+		IsInsensitive:     insensitive,
+	})
+	if err != nil {
+		return fmt.Errorf("errore nell'aggiornamento delle regole di matching: %w", err)
+	}
+	return nil
+}
+
+// DeleteTag elimina un tag
+func (c *Client) DeleteTag(ctx context.Context, id int) error {
+	resp, err := c.makeRequest(ctx, "DELETE", fmt.Sprintf("/api/tags/%d/", id), nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'aggiornamento del corrispondente: %d - %s", resp.StatusCode, string(respBody))
+	if resp.StatusCode != http.StatusNoContent {
+		return newAPIError("errore nell'eliminazione del tag", resp)
 	}
 
 	return nil
 }
 
-// UpdateDocumentType aggiorna un tipo di documento
-func (c *Client) UpdateDocumentType(id int, name string) error {
-	body := strings.NewReader(fmt.Sprintf(`{"name": "%s"}`, name))
-	resp, err := c.makeRequest("PATCH", fmt.Sprintf("/api/document_types/%d/", id), body)
+// DeleteCorrespondent elimina un corrispondente
+func (c *Client) DeleteCorrespondent(ctx context.Context, id int) error {
+	resp, err := c.makeRequest(ctx, "DELETE", fmt.Sprintf("/api/correspondents/%d/", id), nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'aggiornamento del tipo documento: %d - %s", resp.StatusCode, string(respBody))
+	if resp.StatusCode != http.StatusNoContent {
+		return newAPIError("errore nell'eliminazione del corrispondente", resp)
 	}
 
 	return nil
 }
 
-// DeleteTag elimina un tag
-func (c *Client) DeleteTag(id int) error {
-	resp, err := c.makeRequest("DELETE", fmt.Sprintf("/api/tags/%d/", id), nil)
+// DeleteDocumentType elimina un tipo di documento
+func (c *Client) DeleteDocumentType(ctx context.Context, id int) error {
+	resp, err := c.makeRequest(ctx, "DELETE", fmt.Sprintf("/api/document_types/%d/", id), nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'eliminazione del tag: %d - %s", resp.StatusCode, string(respBody))
+		return newAPIError("errore nell'eliminazione del tipo documento", resp)
 	}
 
 	return nil
 }
 
-// DeleteCorrespondent elimina un corrispondente
-func (c *Client) DeleteCorrespondent(id int) error {
-	resp, err := c.makeRequest("DELETE", fmt.Sprintf("/api/correspondents/%d/", id), nil)
+// DeleteStoragePath elimina un percorso di archiviazione
+func (c *Client) DeleteStoragePath(ctx context.Context, id int) error {
+	resp, err := c.makeRequest(ctx, "DELETE", fmt.Sprintf("/api/storage_paths/%d/", id), nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'eliminazione del corrispondente: %d - %s", resp.StatusCode, string(respBody))
+		return newAPIError("errore nell'eliminazione del percorso di archiviazione", resp)
 	}
 
 	return nil
 }
 
-// DeleteDocumentType elimina un tipo di documento
-func (c *Client) DeleteDocumentType(id int) error {
-	resp, err := c.makeRequest("DELETE", fmt.Sprintf("/api/document_types/%d/", id), nil)
+// DeleteCustomField elimina un campo personalizzato
+func (c *Client) DeleteCustomField(ctx context.Context, id int) error {
+	resp, err := c.makeRequest(ctx, "DELETE", fmt.Sprintf("/api/custom_fields/%d/", id), nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'eliminazione del tipo documento: %d - %s", resp.StatusCode, string(respBody))
+		return newAPIError("errore nell'eliminazione del campo personalizzato", resp)
 	}
 
 	return nil
 }
 
+// GetTag recupera un singolo tag
+func (c *Client) GetTag(ctx context.Context, id int) (*Tag, error) {
+	var tag Tag
+	if err := c.getObject(ctx, fmt.Sprintf("/api/tags/%d/", id), &tag); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// GetCorrespondent recupera un singolo corrispondente
+func (c *Client) GetCorrespondent(ctx context.Context, id int) (*Correspondent, error) {
+	var corr Correspondent
+	if err := c.getObject(ctx, fmt.Sprintf("/api/correspondents/%d/", id), &corr); err != nil {
+		return nil, err
+	}
+	return &corr, nil
+}
+
+// GetDocumentType recupera un singolo tipo di documento
+func (c *Client) GetDocumentType(ctx context.Context, id int) (*DocumentType, error) {
+	var docType DocumentType
+	if err := c.getObject(ctx, fmt.Sprintf("/api/document_types/%d/", id), &docType); err != nil {
+		return nil, err
+	}
+	return &docType, nil
+}
+
+// GetStoragePath recupera un singolo percorso di archiviazione
+func (c *Client) GetStoragePath(ctx context.Context, id int) (*StoragePath, error) {
+	var storagePath StoragePath
+	if err := c.getObject(ctx, fmt.Sprintf("/api/storage_paths/%d/", id), &storagePath); err != nil {
+		return nil, err
+	}
+	return &storagePath, nil
+}
+
+// GetCustomField recupera un singolo campo personalizzato
+func (c *Client) GetCustomField(ctx context.Context, id int) (*CustomField, error) {
+	var field CustomField
+	if err := c.getObject(ctx, fmt.Sprintf("/api/custom_fields/%d/", id), &field); err != nil {
+		return nil, err
+	}
+	return &field, nil
+}
+
+// CreateTag crea un tag con nome, colore, regole di matching, proprietario e padre indicati
+func (c *Client) CreateTag(ctx context.Context, tag Tag) (*Tag, error) {
+	payload := tagPayload{
+		Name:              tag.Name,
+		Color:             tag.Color,
+		Match:             tag.Match,
+		MatchingAlgorithm: tag.MatchingAlgorithm,
+		IsInsensitive:     tag.IsInsensitive,
+		IsInboxTag:        tag.IsInboxTag,
+		Owner:             tag.Owner,
+		Parent:            tag.Parent,
+	}
+
+	var created Tag
+	if err := c.createObject(ctx, "/api/tags/", payload, &created); err != nil {
+		return nil, fmt.Errorf("errore nella creazione del tag: %w", err)
+	}
+	return &created, nil
+}
+
+// CreateCorrespondent crea un corrispondente con nome e regole di matching indicati
+func (c *Client) CreateCorrespondent(ctx context.Context, corr Correspondent) (*Correspondent, error) {
+	payload := itemPayload{
+		Name:              corr.Name,
+		Match:             corr.Match,
+		MatchingAlgorithm: corr.MatchingAlgorithm,
+		IsInsensitive:     corr.IsInsensitive,
+		Owner:             corr.Owner,
+	}
+
+	var created Correspondent
+	if err := c.createObject(ctx, "/api/correspondents/", payload, &created); err != nil {
+		return nil, fmt.Errorf("errore nella creazione del corrispondente: %w", err)
+	}
+	return &created, nil
+}
+
+// CreateDocumentType crea un tipo di documento con nome e regole di matching indicati
+func (c *Client) CreateDocumentType(ctx context.Context, docType DocumentType) (*DocumentType, error) {
+	payload := itemPayload{
+		Name:              docType.Name,
+		Match:             docType.Match,
+		MatchingAlgorithm: docType.MatchingAlgorithm,
+		IsInsensitive:     docType.IsInsensitive,
+		Owner:             docType.Owner,
+	}
+
+	var created DocumentType
+	if err := c.createObject(ctx, "/api/document_types/", payload, &created); err != nil {
+		return nil, fmt.Errorf("errore nella creazione del tipo documento: %w", err)
+	}
+	return &created, nil
+}
+
+// CreateStoragePath crea un percorso di archiviazione con template e regole di matching indicati
+func (c *Client) CreateStoragePath(ctx context.Context, storagePath StoragePath) (*StoragePath, error) {
+	payload := storagePathPayload{
+		Name:              storagePath.Name,
+		Path:              storagePath.Path,
+		Match:             storagePath.Match,
+		MatchingAlgorithm: storagePath.MatchingAlgorithm,
+		IsInsensitive:     storagePath.IsInsensitive,
+		Owner:             storagePath.Owner,
+	}
+
+	var created StoragePath
+	if err := c.createObject(ctx, "/api/storage_paths/", payload, &created); err != nil {
+		return nil, fmt.Errorf("errore nella creazione del percorso di archiviazione: %w", err)
+	}
+	return &created, nil
+}
+
+// CreateCustomField crea un campo personalizzato con tipo di dato e opzioni indicati
+func (c *Client) CreateCustomField(ctx context.Context, field CustomField) (*CustomField, error) {
+	payload := customFieldPayload{
+		Name:      field.Name,
+		DataType:  field.DataType,
+		ExtraData: field.ExtraData,
+	}
+
+	var created CustomField
+	if err := c.createObject(ctx, "/api/custom_fields/", payload, &created); err != nil {
+		return nil, fmt.Errorf("errore nella creazione del campo personalizzato: %w", err)
+	}
+	return &created, nil
+}
+
 // GetDocumentsByTag recupera tutti i documenti che hanno un certo tag
-func (c *Client) GetDocumentsByTag(tagID int) ([]Document, error) {
+func (c *Client) GetDocumentsByTag(ctx context.Context, tagID int) ([]Document, error) {
 	endpoint := fmt.Sprintf("/api/documents/?tags__id__in=%d&page_size=1000", tagID)
-	return c.getDocuments(endpoint)
+	return c.getDocuments(ctx, endpoint)
 }
 
 // GetDocumentsByCorrespondent recupera tutti i documenti di un corrispondente
-func (c *Client) GetDocumentsByCorrespondent(correspondentID int) ([]Document, error) {
+func (c *Client) GetDocumentsByCorrespondent(ctx context.Context, correspondentID int) ([]Document, error) {
 	endpoint := fmt.Sprintf("/api/documents/?correspondent__id=%d&page_size=1000", correspondentID)
-	return c.getDocuments(endpoint)
+	return c.getDocuments(ctx, endpoint)
 }
 
 // GetDocumentsByType recupera tutti i documenti di un tipo
-func (c *Client) GetDocumentsByType(typeID int) ([]Document, error) {
+func (c *Client) GetDocumentsByType(ctx context.Context, typeID int) ([]Document, error) {
 	endpoint := fmt.Sprintf("/api/documents/?document_type__id=%d&page_size=1000", typeID)
-	return c.getDocuments(endpoint)
+	return c.getDocuments(ctx, endpoint)
+}
+
+// GetDocumentsByStoragePath recupera tutti i documenti di un percorso di archiviazione
+func (c *Client) GetDocumentsByStoragePath(ctx context.Context, storagePathID int) ([]Document, error) {
+	endpoint := fmt.Sprintf("/api/documents/?storage_path__id=%d&page_size=1000", storagePathID)
+	return c.getDocuments(ctx, endpoint)
+}
+
+// GetDocumentsByCustomField recupera tutti i documenti che hanno un campo personalizzato
+func (c *Client) GetDocumentsByCustomField(ctx context.Context, fieldID int) ([]Document, error) {
+	endpoint := fmt.Sprintf("/api/documents/?custom_fields__id__all=%d&page_size=1000", fieldID)
+	return c.getDocuments(ctx, endpoint)
 }
 
 // getDocuments è un helper per recuperare documenti con paginazione automatica
-func (c *Client) getDocuments(initialEndpoint string) ([]Document, error) {
+func (c *Client) getDocuments(ctx context.Context, initialEndpoint string) ([]Document, error) {
 	var allDocuments []Document
 	endpoint := initialEndpoint
 
 	for endpoint != "" {
-		resp, err := c.makeRequest("GET", endpoint, nil)
+		resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
 		if err != nil {
 			return nil, err
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
+			apiErr := newAPIError("errore API", resp)
 			resp.Body.Close()
-			return nil, fmt.Errorf("errore API: %d - %s", resp.StatusCode, string(body))
+			return nil, apiErr
 		}
 
 		var listResp ListResponse
@@ -363,86 +1073,196 @@ func (c *Client) getDocuments(initialEndpoint string) ([]Document, error) {
 	return allDocuments, nil
 }
 
-// UpdateDocumentTags aggiorna i tags di un documento
-func (c *Client) UpdateDocumentTags(docID int, oldTagID, newTagID int) error {
-	// Prima recuperiamo il documento per avere tutti i suoi tag
-	doc, err := c.getDocument(docID)
+// AddDocumentTag aggiunge un tag a un documento mantenendo quelli esistenti
+func (c *Client) AddDocumentTag(ctx context.Context, docID, tagID int) error {
+	doc, err := c.GetDocument(ctx, docID)
 	if err != nil {
 		return err
 	}
 
-	// Sostituiamo il vecchio tag con il nuovo
-	newTags := make([]int, 0)
-	for _, tagID := range doc.Tags {
-		if tagID == oldTagID {
-			newTags = append(newTags, newTagID)
-		} else {
-			newTags = append(newTags, tagID)
+	newTags := make([]int, 0, len(doc.Tags)+1)
+	for _, id := range doc.Tags {
+		if id == tagID {
+			// Il documento ha già il tag
+			return nil
 		}
+		newTags = append(newTags, id)
 	}
+	newTags = append(newTags, tagID)
 
-	// Aggiorniamo il documento
-	tagsJSON, _ := json.Marshal(newTags)
-	body := strings.NewReader(fmt.Sprintf(`{"tags": %s}`, string(tagsJSON)))
-	resp, err := c.makeRequest("PATCH", fmt.Sprintf("/api/documents/%d/", docID), body)
+	return c.patchDocument(ctx, docID, documentTagsPayload{Tags: newTags})
+}
+
+// RemoveDocumentTag toglie un tag da un documento mantenendo gli altri
+func (c *Client) RemoveDocumentTag(ctx context.Context, docID, tagID int) error {
+	doc, err := c.GetDocument(ctx, docID)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'aggiornamento del documento: %d - %s", resp.StatusCode, string(respBody))
+	newTags := make([]int, 0, len(doc.Tags))
+	for _, id := range doc.Tags {
+		if id != tagID {
+			newTags = append(newTags, id)
+		}
+	}
+	if len(newTags) == len(doc.Tags) {
+		// Il documento non ha il tag
+		return nil
 	}
 
-	return nil
+	return c.patchDocument(ctx, docID, documentTagsPayload{Tags: newTags})
 }
 
-// UpdateDocumentCorrespondent aggiorna il corrispondente di un documento
-func (c *Client) UpdateDocumentCorrespondent(docID, newCorrespondentID int) error {
-	body := strings.NewReader(fmt.Sprintf(`{"correspondent": %d}`, newCorrespondentID))
-	resp, err := c.makeRequest("PATCH", fmt.Sprintf("/api/documents/%d/", docID), body)
+// ModifyDocumentTags aggiunge e rimuove tag da un singolo documento come fa
+// modify_tags di bulk_edit: i tag da aggiungere vengono aggiunti anche se il documento
+// non ha quelli da rimuovere, e nessun tag compare due volte
+func (c *Client) ModifyDocumentTags(ctx context.Context, docID int, addTags, removeTags []int) error {
+	doc, err := c.GetDocument(ctx, docID)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'aggiornamento del documento: %d - %s", resp.StatusCode, string(respBody))
+	newTags := make([]int, 0, len(doc.Tags)+len(addTags))
+	seen := make(map[int]bool, len(doc.Tags)+len(addTags))
+	for _, ids := range [][]int{doc.Tags, addTags} {
+		for _, id := range ids {
+			if seen[id] || (containsTag(removeTags, id) && !containsTag(addTags, id)) {
+				continue
+			}
+			seen[id] = true
+			newTags = append(newTags, id)
+		}
+	}
+	if sameTags(newTags, doc.Tags) {
+		return nil
 	}
 
-	return nil
+	return c.patchDocument(ctx, docID, documentTagsPayload{Tags: newTags})
+}
+
+// containsTag indica se la lista contiene il tag indicato
+func containsTag(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTags indica se le due liste contengono gli stessi tag nello stesso ordine
+func sameTags(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateDocumentCorrespondent aggiorna il corrispondente di un documento (0 per nessuno)
+func (c *Client) UpdateDocumentCorrespondent(ctx context.Context, docID, newCorrespondentID int) error {
+	return c.patchDocument(ctx, docID, documentCorrespondentPayload{Correspondent: optionalID(newCorrespondentID)})
+}
+
+// UpdateDocumentType aggiorna il tipo di un documento (0 per nessuno)
+func (c *Client) UpdateDocumentTypeForDoc(ctx context.Context, docID, newTypeID int) error {
+	return c.patchDocument(ctx, docID, documentTypePayload{DocumentType: optionalID(newTypeID)})
 }
 
-// UpdateDocumentType aggiorna il tipo di un documento
-func (c *Client) UpdateDocumentTypeForDoc(docID, newTypeID int) error {
-	body := strings.NewReader(fmt.Sprintf(`{"document_type": %d}`, newTypeID))
-	resp, err := c.makeRequest("PATCH", fmt.Sprintf("/api/documents/%d/", docID), body)
+// UpdateDocumentStoragePath aggiorna il percorso di archiviazione di un documento (0 per nessuno)
+func (c *Client) UpdateDocumentStoragePath(ctx context.Context, docID, newStoragePathID int) error {
+	return c.patchDocument(ctx, docID, documentStoragePathPayload{StoragePath: optionalID(newStoragePathID)})
+}
+
+// UpdateDocumentCustomFields sostituisce i campi personalizzati di un documento:
+// i campi non presenti nella lista vengono rimossi dal documento
+func (c *Client) UpdateDocumentCustomFields(ctx context.Context, docID int, fields []CustomFieldInstance) error {
+	if fields == nil {
+		fields = []CustomFieldInstance{}
+	}
+	return c.patchDocument(ctx, docID, documentCustomFieldsPayload{CustomFields: fields})
+}
+
+// BulkEdit applica un'operazione a più documenti con una sola richiesta
+// (POST /api/documents/bulk_edit/). Restituisce ErrBulkEditUnsupported
+// se il server non espone l'endpoint.
+func (c *Client) BulkEdit(ctx context.Context, docIDs []int, method string, parameters interface{}) error {
+	data, err := json.Marshal(bulkEditRequest{
+		Documents:  docIDs,
+		Method:     method,
+		Parameters: parameters,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/api/documents/bulk_edit/", bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return fmt.Errorf("%w: %d", ErrBulkEditUnsupported, resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("errore nell'aggiornamento del documento: %d - %s", resp.StatusCode, string(respBody))
+		return newAPIError("errore nella modifica multipla dei documenti", resp)
 	}
 
 	return nil
 }
 
-// getDocument recupera un singolo documento
-func (c *Client) getDocument(docID int) (*Document, error) {
-	resp, err := c.makeRequest("GET", fmt.Sprintf("/api/documents/%d/", docID), nil)
+// BulkModifyTags aggiunge e rimuove tag da più documenti in un'unica operazione
+// lato server, senza leggere e riscrivere i tag di ogni documento
+func (c *Client) BulkModifyTags(ctx context.Context, docIDs []int, addTags, removeTags []int) error {
+	if addTags == nil {
+		addTags = []int{}
+	}
+	if removeTags == nil {
+		removeTags = []int{}
+	}
+	return c.BulkEdit(ctx, docIDs, "modify_tags", modifyTagsParameters{
+		AddTags:    addTags,
+		RemoveTags: removeTags,
+	})
+}
+
+// BulkSetCorrespondent imposta il corrispondente di più documenti (0 per nessuno)
+func (c *Client) BulkSetCorrespondent(ctx context.Context, docIDs []int, correspondentID int) error {
+	return c.BulkEdit(ctx, docIDs, "set_correspondent", setCorrespondentParameters{
+		Correspondent: optionalID(correspondentID),
+	})
+}
+
+// BulkSetDocumentType imposta il tipo di più documenti (0 per nessuno)
+func (c *Client) BulkSetDocumentType(ctx context.Context, docIDs []int, typeID int) error {
+	return c.BulkEdit(ctx, docIDs, "set_document_type", setDocumentTypeParameters{
+		DocumentType: optionalID(typeID),
+	})
+}
+
+// BulkSetStoragePath imposta il percorso di archiviazione di più documenti (0 per nessuno)
+func (c *Client) BulkSetStoragePath(ctx context.Context, docIDs []int, storagePathID int) error {
+	return c.BulkEdit(ctx, docIDs, "set_storage_path", setStoragePathParameters{
+		StoragePath: optionalID(storagePathID),
+	})
+}
+
+// GetDocument recupera un singolo documento
+func (c *Client) GetDocument(ctx context.Context, docID int) (*Document, error) {
+	resp, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/api/documents/%d/", docID), nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("errore API: %d - %s", resp.StatusCode, string(body))
+		return nil, newAPIError("errore API", resp)
 	}
 
 	var doc Document
@@ -453,16 +1273,106 @@ func (c *Client) getDocument(docID int) (*Document, error) {
 	return &doc, nil
 }
 
+// getObject recupera un singolo oggetto e lo decodifica in out
+func (c *Client) getObject(ctx context.Context, endpoint string, out interface{}) error {
+	resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError("errore API", resp)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// createObject crea un oggetto con una POST e decodifica la risposta in out
+func (c *Client) createObject(ctx context.Context, endpoint string, payload, out interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if c.DryRun {
+		return c.simulateCreate(ctx, endpoint, data, out)
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return newAPIError("", resp)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// simulateCreate registra in dry-run la creazione di un oggetto e lo restituisce con i
+// campi inviati e un ID provvisorio negativo (-1, -2, ...), indicato nel registro: le
+// richieste simulate successive si riferiscono così all'oggetto giusto invece che a /0/
+func (c *Client) simulateCreate(ctx context.Context, endpoint string, data []byte, out interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.simulatedID--
+	id := c.simulatedID
+	c.skipped = append(c.skipped, fmt.Sprintf("POST %s %s → id %d", endpoint, data, id))
+	c.mu.Unlock()
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return err
+	}
+	created, err := json.Marshal(createdObject{ID: id})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(created, out)
+}
+
+// patchObject invia una PATCH con il payload indicato
+func (c *Client) patchObject(ctx context.Context, endpoint string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.makeRequest(ctx, "PATCH", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError("", resp)
+	}
+
+	return nil
+}
+
+// patchDocument aggiorna i campi di un documento presenti nel payload
+func (c *Client) patchDocument(ctx context.Context, docID int, payload interface{}) error {
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/documents/%d/", docID), payload); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del documento: %w", err)
+	}
+	return nil
+}
+
 // TestConnection verifica la connessione all'API
-func (c *Client) TestConnection() error {
-	resp, err := c.makeRequest("GET", "/api/", nil)
+func (c *Client) TestConnection(ctx context.Context) error {
+	resp, err := c.makeRequest(ctx, "GET", "/api/", nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("connessione fallita: status code %d", resp.StatusCode)
+		return newAPIError("connessione fallita", resp)
 	}
 
 	return nil
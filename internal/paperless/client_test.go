@@ -0,0 +1,523 @@
+package paperless_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/paperless/fake"
+)
+
+// request è una richiesta ricevuta dal server finto
+type request struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// recorder registra le richieste prima di passarle al server finto
+type recorder struct {
+	handler http.Handler
+
+	mu       sync.Mutex
+	requests []request
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	r.requests = append(r.requests, request{Method: req.Method, Path: req.URL.Path, Body: body})
+	r.mu.Unlock()
+
+	r.handler.ServeHTTP(w, req)
+}
+
+// last restituisce l'ultima richiesta ricevuta
+func (r *recorder) last(t *testing.T) request {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		t.Fatal("nessuna richiesta ricevuta")
+	}
+	return r.requests[len(r.requests)-1]
+}
+
+// payloadFixture contiene un elemento per tipo, due documenti, una vista salvata e una
+// regola mail, con ID distinti per tipo così che un percorso sbagliato non passi inosservato
+func payloadFixture() *fake.Fixture {
+	return &fake.Fixture{
+		Tags:           []paperless.Tag{{ID: 1, Name: "Tag"}, {ID: 2, Name: "Altro tag"}},
+		Correspondents: []paperless.Correspondent{{ID: 3, Name: "Corrispondente"}},
+		DocumentTypes:  []paperless.DocumentType{{ID: 4, Name: "Tipo"}},
+		StoragePaths:   []paperless.StoragePath{{ID: 5, Name: "Percorso", Path: "{title}"}},
+		CustomFields: []paperless.CustomField{
+			{ID: 6, Name: "Nota", DataType: "string"},
+			{ID: 7, Name: "Stato", DataType: paperless.CustomFieldSelect},
+			{ID: 8, Name: "Scadenza", DataType: "date"},
+		},
+		Documents: []paperless.Document{
+			{ID: 10, Title: "Primo", Tags: []int{2}},
+			{ID: 11, Title: "Secondo"},
+		},
+		SavedViews: []paperless.SavedView{{ID: 20, Name: "Vista"}},
+		MailRules:  []paperless.MailRule{{ID: 22, Name: "Fatture"}},
+	}
+}
+
+// newRecordingClient avvia un server finto con payloadFixture e restituisce un client
+// collegato insieme al registro delle richieste
+func newRecordingClient(t *testing.T) (*paperless.Client, *recorder) {
+	t.Helper()
+
+	srv, err := fake.NewServer(payloadFixture())
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	rec := &recorder{handler: srv}
+	ts := httptest.NewServer(rec)
+	t.Cleanup(ts.Close)
+
+	return paperless.NewClient(ts.URL, fake.DefaultToken), rec
+}
+
+// assertJSON verifica che body sia il JSON atteso, a meno di spazi e ordine delle chiavi
+func assertJSON(t *testing.T, body []byte, want string) {
+	t.Helper()
+
+	var got, expected interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("corpo non valido %q: %v", body, err)
+	}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatalf("JSON atteso non valido %q: %v", want, err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("corpo %s, atteso %s", body, want)
+	}
+}
+
+// trickyNames sono nomi che un corpo JSON costruito a mano non trasmetterebbe intatti
+var trickyNames = []string{
+	`Caffè "Da Mario"`,
+	`L'Aquila`,
+	`C:\Archivio\2024\`,
+	`\"già escapato\"`,
+	`Ünïcödé ĀĒ 日本語 العربية`,
+	`Ricevute 📄🧾 👨‍👩‍👧`,
+	`<b>Tag</b> & %s %d {{.Name}}`,
+	"Tab\tnon\ninterpretati",
+	strings.Repeat("ж📄", 64),
+}
+
+// payloadCase è una chiamata del client con la richiesta che deve produrre
+type payloadCase struct {
+	name   string
+	call   func(ctx context.Context, client *paperless.Client) error
+	method string
+	path   string
+	want   string // Corpo atteso, a meno di spazi e ordine delle chiavi
+}
+
+// jsonField restituisce un oggetto JSON con il solo campo indicato
+func jsonField(key string, value interface{}) string {
+	data, _ := json.Marshal(map[string]interface{}{key: value})
+	return string(data)
+}
+
+// renameCases restituisce un caso di rinomina per ognuno dei trickyNames
+func renameCases(kind, path string, rename func(ctx context.Context, client *paperless.Client, name string) error) []payloadCase {
+	cases := make([]payloadCase, len(trickyNames))
+	for i, name := range trickyNames {
+		cases[i] = payloadCase{
+			name:   fmt.Sprintf("rinomina %s %d", kind, i+1),
+			call:   func(ctx context.Context, client *paperless.Client) error { return rename(ctx, client, name) },
+			method: http.MethodPatch,
+			path:   path,
+			want:   jsonField("name", name),
+		}
+	}
+	return cases
+}
+
+func TestPayloads(t *testing.T) {
+	storagePathTemplate := `{{ correspondent }}/{{ created_year }}/"Fatture" C:\{{ title }}`
+	selectOptions := `{"select_options":[{"id":"x1","label":"\"Aperto\""},{"id":"x2","label":"C:\\Chiuso ✅"}]}`
+	none := (*int)(nil)
+	ruleValue := "1"
+
+	cases := []payloadCase{
+		{
+			name: "crea tag",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				_, err := client.CreateTag(ctx, paperless.Tag{Name: `Tag "nuovo" \ 🏷️`, Color: "#ff0000", Match: `^"a"\s`, MatchingAlgorithm: paperless.MatchRegex, IsInboxTag: true})
+				return err
+			},
+			method: http.MethodPost,
+			path:   "/api/tags/",
+			want:   `{"name":"Tag \"nuovo\" \\ 🏷️","colour":"#ff0000","match":"^\"a\"\\s","matching_algorithm":4,"is_insensitive":false,"is_inbox_tag":true}`,
+		},
+		{
+			name: "crea corrispondente",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				_, err := client.CreateCorrespondent(ctx, paperless.Correspondent{Name: `Caffè "Da Mario"`, Match: `C:\Mario`, MatchingAlgorithm: paperless.MatchLiteral, IsInsensitive: true})
+				return err
+			},
+			method: http.MethodPost,
+			path:   "/api/correspondents/",
+			want:   `{"name":"Caffè \"Da Mario\"","match":"C:\\Mario","matching_algorithm":3,"is_insensitive":true}`,
+		},
+		{
+			name: "crea tipo documento",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				_, err := client.CreateDocumentType(ctx, paperless.DocumentType{Name: "Ricevute 🧾"})
+				return err
+			},
+			method: http.MethodPost,
+			path:   "/api/document_types/",
+			want:   `{"name":"Ricevute 🧾","match":"","matching_algorithm":0,"is_insensitive":false}`,
+		},
+		{
+			name: "crea percorso di archiviazione",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				_, err := client.CreateStoragePath(ctx, paperless.StoragePath{Name: `Archivio "2024" 📁`, Path: storagePathTemplate, Match: `^fattura\s+"\d{4}"$`, MatchingAlgorithm: paperless.MatchRegex})
+				return err
+			},
+			method: http.MethodPost,
+			path:   "/api/storage_paths/",
+			want:   `{"name":"Archivio \"2024\" 📁","path":"{{ correspondent }}/{{ created_year }}/\"Fatture\" C:\\{{ title }}","match":"^fattura\\s+\"\\d{4}\"$","matching_algorithm":4,"is_insensitive":false}`,
+		},
+		{
+			name: "crea campo personalizzato",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				_, err := client.CreateCustomField(ctx, paperless.CustomField{Name: `Stato \ "pratica"`, DataType: paperless.CustomFieldSelect, ExtraData: json.RawMessage(selectOptions)})
+				return err
+			},
+			method: http.MethodPost,
+			path:   "/api/custom_fields/",
+			want:   `{"name":"Stato \\ \"pratica\"","data_type":"select","extra_data":` + selectOptions + `}`,
+		},
+		{
+			name: "opzioni del campo personalizzato",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateCustomFieldExtraData(ctx, 7, json.RawMessage(selectOptions))
+			},
+			method: http.MethodPatch,
+			path:   "/api/custom_fields/7/",
+			want:   `{"extra_data":` + selectOptions + `}`,
+		},
+		{
+			name: "matching del percorso di archiviazione",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateStoragePathMatching(ctx, 5, `C:\Scansioni "in arrivo"`, paperless.MatchLiteral, true)
+			},
+			method: http.MethodPatch,
+			path:   "/api/storage_paths/5/",
+			want:   `{"match":"C:\\Scansioni \"in arrivo\"","matching_algorithm":3,"is_insensitive":true}`,
+		},
+		{
+			name: "colore del tag",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateTagColor(ctx, 1, "#00ff00")
+			},
+			method: http.MethodPatch,
+			path:   "/api/tags/1/",
+			want:   `{"colour":"#00ff00"}`,
+		},
+		{
+			name:   "tag della posta in arrivo",
+			call:   func(ctx context.Context, client *paperless.Client) error { return client.UpdateTagInbox(ctx, 1, false) },
+			method: http.MethodPatch,
+			path:   "/api/tags/1/",
+			want:   `{"is_inbox_tag":false}`,
+		},
+		{
+			name:   "tag alla radice",
+			call:   func(ctx context.Context, client *paperless.Client) error { return client.UpdateTagParent(ctx, 1, none) },
+			method: http.MethodPatch,
+			path:   "/api/tags/1/",
+			want:   `{"parent":null}`,
+		},
+		{
+			name: "corrispondente senza proprietario",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateCorrespondentOwner(ctx, 3, none)
+			},
+			method: http.MethodPatch,
+			path:   "/api/correspondents/3/",
+			want:   `{"owner":null}`,
+		},
+		{
+			name: "corrispondente del documento",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateDocumentCorrespondent(ctx, 10, 3)
+			},
+			method: http.MethodPatch,
+			path:   "/api/documents/10/",
+			want:   `{"correspondent":3}`,
+		},
+		{
+			name: "documento senza corrispondente",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateDocumentCorrespondent(ctx, 10, 0)
+			},
+			method: http.MethodPatch,
+			path:   "/api/documents/10/",
+			want:   `{"correspondent":null}`,
+		},
+		{
+			name: "tipo del documento",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateDocumentTypeForDoc(ctx, 10, 4)
+			},
+			method: http.MethodPatch,
+			path:   "/api/documents/10/",
+			want:   `{"document_type":4}`,
+		},
+		{
+			name: "percorso di archiviazione del documento",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateDocumentStoragePath(ctx, 10, 5)
+			},
+			method: http.MethodPatch,
+			path:   "/api/documents/10/",
+			want:   `{"storage_path":5}`,
+		},
+		{
+			// I valori dei documenti restano JSON grezzo: stringhe, null e ID delle opzioni
+			name: "campi personalizzati del documento",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateDocumentCustomFields(ctx, 10, []paperless.CustomFieldInstance{
+					{Field: 6, Value: json.RawMessage(`"Riga con \"virgolette\", \\ e 日本語"`)},
+					{Field: 7, Value: json.RawMessage(`"x2"`)},
+					{Field: 8},
+				})
+			},
+			method: http.MethodPatch,
+			path:   "/api/documents/10/",
+			want:   `{"custom_fields":[{"field":6,"value":"Riga con \"virgolette\", \\ e 日本語"},{"field":7,"value":"x2"},{"field":8,"value":null}]}`,
+		},
+		{
+			name: "documento senza campi personalizzati",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateDocumentCustomFields(ctx, 10, nil)
+			},
+			method: http.MethodPatch,
+			path:   "/api/documents/10/",
+			want:   `{"custom_fields":[]}`,
+		},
+		{
+			name: "modify_tags",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.BulkModifyTags(ctx, []int{10, 11}, []int{1}, []int{2})
+			},
+			method: http.MethodPost,
+			path:   "/api/documents/bulk_edit/",
+			want:   `{"documents":[10,11],"method":"modify_tags","parameters":{"add_tags":[1],"remove_tags":[2]}}`,
+		},
+		{
+			name: "modify_tags senza tag da togliere",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.BulkModifyTags(ctx, []int{10}, []int{2}, nil)
+			},
+			method: http.MethodPost,
+			path:   "/api/documents/bulk_edit/",
+			want:   `{"documents":[10],"method":"modify_tags","parameters":{"add_tags":[2],"remove_tags":[]}}`,
+		},
+		{
+			name: "set_correspondent",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.BulkSetCorrespondent(ctx, []int{10, 11}, 3)
+			},
+			method: http.MethodPost,
+			path:   "/api/documents/bulk_edit/",
+			want:   `{"documents":[10,11],"method":"set_correspondent","parameters":{"correspondent":3}}`,
+		},
+		{
+			name: "set_document_type",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.BulkSetDocumentType(ctx, []int{11}, 4)
+			},
+			method: http.MethodPost,
+			path:   "/api/documents/bulk_edit/",
+			want:   `{"documents":[11],"method":"set_document_type","parameters":{"document_type":4}}`,
+		},
+		{
+			name: "set_document_type senza tipo",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.BulkSetDocumentType(ctx, []int{10, 11}, 0)
+			},
+			method: http.MethodPost,
+			path:   "/api/documents/bulk_edit/",
+			want:   `{"documents":[10,11],"method":"set_document_type","parameters":{"document_type":null}}`,
+		},
+		{
+			name: "set_storage_path",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.BulkSetStoragePath(ctx, []int{10}, 5)
+			},
+			method: http.MethodPost,
+			path:   "/api/documents/bulk_edit/",
+			want:   `{"documents":[10],"method":"set_storage_path","parameters":{"storage_path":5}}`,
+		},
+		{
+			name: "regole della vista salvata",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateSavedViewFilterRules(ctx, 20, []paperless.FilterRule{{RuleType: 6, Value: &ruleValue}, {RuleType: 1}})
+			},
+			method: http.MethodPatch,
+			path:   "/api/saved_views/20/",
+			want:   `{"filter_rules":[{"rule_type":6,"value":"1"},{"rule_type":1,"value":null}]}`,
+		},
+		{
+			name: "regola mail",
+			call: func(ctx context.Context, client *paperless.Client) error {
+				return client.UpdateMailRule(ctx, paperless.MailRule{ID: 22, Name: "Fatture"})
+			},
+			method: http.MethodPatch,
+			path:   "/api/mail_rules/22/",
+			want:   `{"assign_tags":[],"assign_correspondent":null,"assign_document_type":null}`,
+		},
+	}
+	cases = append(cases, renameCases("tag", "/api/tags/1/", func(ctx context.Context, client *paperless.Client, name string) error {
+		return client.UpdateTag(ctx, 1, name)
+	})...)
+	cases = append(cases, renameCases("corrispondente", "/api/correspondents/3/", func(ctx context.Context, client *paperless.Client, name string) error {
+		return client.UpdateCorrespondent(ctx, 3, name)
+	})...)
+	cases = append(cases, renameCases("tipo documento", "/api/document_types/4/", func(ctx context.Context, client *paperless.Client, name string) error {
+		return client.UpdateDocumentType(ctx, 4, name)
+	})...)
+	cases = append(cases, renameCases("percorso di archiviazione", "/api/storage_paths/5/", func(ctx context.Context, client *paperless.Client, name string) error {
+		return client.UpdateStoragePath(ctx, 5, name)
+	})...)
+	cases = append(cases, renameCases("campo personalizzato", "/api/custom_fields/6/", func(ctx context.Context, client *paperless.Client, name string) error {
+		return client.UpdateCustomField(ctx, 6, name)
+	})...)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, rec := newRecordingClient(t)
+			if err := tc.call(context.Background(), client); err != nil {
+				t.Fatalf("richiesta fallita: %v", err)
+			}
+
+			got := rec.last(t)
+			if got.Method != tc.method || got.Path != tc.path {
+				t.Errorf("richiesta %s %s, attesa %s %s", got.Method, got.Path, tc.method, tc.path)
+			}
+			assertJSON(t, got.Body, tc.want)
+		})
+	}
+}
+
+func TestUpdateNamesRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newRecordingClient(t)
+
+	kinds := []struct {
+		name   string
+		update func(name string) error
+		get    func() (string, error)
+	}{
+		{"tag", func(name string) error { return client.UpdateTag(ctx, 1, name) }, func() (string, error) {
+			tag, err := client.GetTag(ctx, 1)
+			if err != nil {
+				return "", err
+			}
+			return tag.Name, nil
+		}},
+		{"corrispondente", func(name string) error { return client.UpdateCorrespondent(ctx, 3, name) }, func() (string, error) {
+			corr, err := client.GetCorrespondent(ctx, 3)
+			if err != nil {
+				return "", err
+			}
+			return corr.Name, nil
+		}},
+		{"tipo documento", func(name string) error { return client.UpdateDocumentType(ctx, 4, name) }, func() (string, error) {
+			docType, err := client.GetDocumentType(ctx, 4)
+			if err != nil {
+				return "", err
+			}
+			return docType.Name, nil
+		}},
+		{"percorso di archiviazione", func(name string) error { return client.UpdateStoragePath(ctx, 5, name) }, func() (string, error) {
+			path, err := client.GetStoragePath(ctx, 5)
+			if err != nil {
+				return "", err
+			}
+			return path.Name, nil
+		}},
+		{"campo personalizzato", func(name string) error { return client.UpdateCustomField(ctx, 6, name) }, func() (string, error) {
+			field, err := client.GetCustomField(ctx, 6)
+			if err != nil {
+				return "", err
+			}
+			return field.Name, nil
+		}},
+	}
+
+	for _, kind := range kinds {
+		t.Run(kind.name, func(t *testing.T) {
+			for _, name := range trickyNames {
+				if err := kind.update(name); err != nil {
+					t.Fatalf("aggiornamento a %q: %v", name, err)
+				}
+				got, err := kind.get()
+				if err != nil {
+					t.Fatalf("lettura dopo %q: %v", name, err)
+				}
+				if got != name {
+					t.Errorf("letto %q, atteso %q", got, name)
+				}
+			}
+		})
+	}
+}
+
+func TestDryRunCreateAssignsNegativeIDs(t *testing.T) {
+	ctx := context.Background()
+	client, rec := newRecordingClient(t)
+	client.DryRun = true
+
+	first, err := client.CreateTag(ctx, paperless.Tag{Name: "Nuovo", Color: "#ff0000"})
+	if err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	second, err := client.CreateCorrespondent(ctx, paperless.Correspondent{Name: "Nuovo corrispondente"})
+	if err != nil {
+		t.Fatalf("CreateCorrespondent: %v", err)
+	}
+	if first.ID != -1 || first.Name != "Nuovo" || first.Color != "#ff0000" || second.ID != -2 {
+		t.Errorf("oggetti simulati %+v e %+v, attesi gli ID -1 e -2 con i campi inviati", first, second)
+	}
+
+	// Le richieste successive si riferiscono all'ID provvisorio
+	if err := client.UpdateTag(ctx, first.ID, "Rinominato"); err != nil {
+		t.Fatalf("UpdateTag: %v", err)
+	}
+
+	want := []string{
+		`POST /api/tags/ {"name":"Nuovo","colour":"#ff0000","match":"","matching_algorithm":0,"is_insensitive":false,"is_inbox_tag":false} → id -1`,
+		`POST /api/correspondents/ {"name":"Nuovo corrispondente","match":"","matching_algorithm":0,"is_insensitive":false} → id -2`,
+		`PATCH /api/tags/-1/ {"name":"Rinominato"}`,
+	}
+	if got := client.SkippedRequests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("richieste simulate:\n%s\nattese:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(rec.requests) != 0 {
+		t.Errorf("%d richieste inviate al server in dry-run", len(rec.requests))
+	}
+}
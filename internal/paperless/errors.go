@@ -0,0 +1,148 @@
+package paperless
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// APIError è l'errore restituito quando il server risponde con uno stato inatteso
+type APIError struct {
+	Context    string // Operazione che ha generato l'errore (es. "errore nell'aggiornamento del tag")
+	Method     string
+	Endpoint   string
+	StatusCode int
+	Body       string
+	// Errori di validazione di Django REST Framework, per campo
+	// (es. "name", "non_field_errors", "detail")
+	FieldErrors map[string][]string
+}
+
+func (e *APIError) Error() string {
+	details := e.Body
+	if len(e.FieldErrors) > 0 {
+		fields := make([]string, 0, len(e.FieldErrors))
+		for field := range e.FieldErrors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		parts := make([]string, len(fields))
+		for i, field := range fields {
+			parts[i] = fmt.Sprintf("%s: %s", field, strings.Join(e.FieldErrors[field], " "))
+		}
+		details = strings.Join(parts, "; ")
+	}
+
+	s := fmt.Sprintf("%d - %s", e.StatusCode, details)
+	if e.Method != "" {
+		s = fmt.Sprintf("%s %s: %s", e.Method, e.Endpoint, s)
+	}
+	if e.Context != "" {
+		s = fmt.Sprintf("%s: %s", e.Context, s)
+	}
+	return s
+}
+
+// Messages restituisce tutti i messaggi di errore restituiti dal server
+func (e *APIError) Messages() []string {
+	var messages []string
+	for _, field := range e.FieldErrors {
+		messages = append(messages, field...)
+	}
+	return messages
+}
+
+// newAPIError costruisce l'errore leggendo il corpo della risposta
+func newAPIError(context string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+
+	e := &APIError{
+		Context:     context,
+		StatusCode:  resp.StatusCode,
+		Body:        strings.TrimSpace(string(body)),
+		FieldErrors: parseFieldErrors(body),
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Endpoint = resp.Request.URL.RequestURI()
+	}
+	return e
+}
+
+// parseFieldErrors interpreta il corpo di un errore di Django REST Framework:
+// un oggetto con un messaggio o un elenco di messaggi per ogni campo.
+// Restituisce nil se il corpo non ha questa forma (es. una pagina HTML).
+func parseFieldErrors(body []byte) map[string][]string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil || len(raw) == 0 {
+		return nil
+	}
+
+	fields := make(map[string][]string, len(raw))
+	for field, value := range raw {
+		var message string
+		var messages []string
+		switch {
+		case json.Unmarshal(value, &message) == nil:
+			fields[field] = []string{message}
+		case json.Unmarshal(value, &messages) == nil:
+			fields[field] = messages
+		default:
+			// Errori annidati (es. per elemento di una lista): tenuti come JSON
+			fields[field] = []string{string(value)}
+		}
+	}
+	return fields
+}
+
+// StatusCode restituisce lo stato HTTP dell'errore, o 0 se non è un errore dell'API
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound indica se l'errore corrisponde a una risposta 404
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized indica se il server ha rifiutato le credenziali (401)
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden indica se il server ha negato l'operazione per mancanza di permessi
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsConflict indica se l'operazione viola l'unicità di un valore, tipicamente il nome:
+// una risposta 409 o un errore di validazione 400 sull'unicità
+// ("... already exists", "... unique constraint")
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode == http.StatusConflict {
+		return true
+	}
+	if apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	for _, message := range apiErr.Messages() {
+		message = strings.ToLower(message)
+		if strings.Contains(message, "unique") || strings.Contains(message, "already exists") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// Fixture è lo stato iniziale del server. Gli oggetti hanno la stessa forma JSON delle
+// risposte dell'API: un file di fixture si può scrivere copiando i risultati degli elenchi
+// di un'istanza reale. I campi calcolati dal server (document_count, children, ...) vengono
+// ignorati.
+type Fixture struct {
+	Token           string `json:"token,omitempty"`             // Token accettato (DefaultToken se vuoto)
+	Username        string `json:"username,omitempty"`          // Utente di /api/token/ (DefaultUsername se vuoto)
+	Password        string `json:"password,omitempty"`          // Password di /api/token/ (DefaultPassword se vuota)
+	RequireMFA      bool   `json:"require_mfa,omitempty"`       // Simula un account con autenticazione a due fattori
+	DisableBulkEdit bool   `json:"disable_bulk_edit,omitempty"` // Simula un server senza bulk_edit
+
+	Tags           []paperless.Tag           `json:"tags"`
+	Correspondents []paperless.Correspondent `json:"correspondents"`
+	DocumentTypes  []paperless.DocumentType  `json:"document_types"`
+	StoragePaths   []paperless.StoragePath   `json:"storage_paths"`
+	CustomFields   []paperless.CustomField   `json:"custom_fields"`
+	Documents      []paperless.Document      `json:"documents"`
+	SavedViews     []paperless.SavedView     `json:"saved_views"`
+	Workflows      []paperless.Workflow      `json:"workflows"`
+	MailRules      []paperless.MailRule      `json:"mail_rules"`
+}
+
+// LoadFixture legge una fixture da un file JSON
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("errore nella lettura della fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("errore nel parsing della fixture: %w", err)
+	}
+	return &fixture, nil
+}
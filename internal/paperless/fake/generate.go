@@ -0,0 +1,383 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// Numero di documenti della fixture generata
+const generatedDocuments = 300
+
+// Nomi degli elementi della fixture generata; ognuno riceve alcune varianti disordinate
+var (
+	tagNames = []string{
+		"Inbox", "Fattura", "Bollette", "Da pagare", "Pagato", "Tasse", "Casa",
+		"Auto", "Assicurazione", "Salute", "Lavoro", "Importante", "Mutuo", "Scuola",
+	}
+	correspondentNames = []string{
+		"Enel Energia", "Telecom Italia", "Amazon", "Agenzia delle Entrate", "Comune di Milano",
+		"Intesa Sanpaolo", "Poste Italiane", "IKEA", "Vodafone", "INPS", "Studio Rossi",
+		"Farmacia Centrale", "Generali Assicurazioni", "A2A",
+	}
+	documentTypeNames = []string{
+		"Fattura", "Ricevuta", "Contratto", "Estratto conto", "Bolletta", "Lettera",
+		"Certificato", "Dichiarazione dei redditi", "Polizza",
+	}
+	storagePaths = []struct{ name, path string }{
+		{"Archivio", "{correspondent}/{title}"},
+		{"Per anno", "{created_year}/{title}"},
+		{"Fatture", "fatture/{created_year}/{correspondent}/{title}"},
+		{"Casa", "casa/{document_type}/{title}"},
+	}
+	customFields = []struct {
+		name, dataType string
+		options        []string // Opzioni dei campi select
+	}{
+		{"Importo", "monetary", nil},
+		{"Scadenza", "date", nil},
+		{"Stato pagamento", paperless.CustomFieldSelect, []string{"Da pagare", "Pagato"}},
+		{"Numero fattura", "string", nil},
+		{"Pagine", "integer", nil},
+	}
+
+	// Suffissi aggiunti a volte ai nomi dei corrispondenti
+	companySuffixes = []string{" S.p.A.", " SpA", " srl", " Italia"}
+
+	// Colori dei tag
+	tagColors = []string{"#a6cee3", "#1f78b4", "#b2df8a", "#33a02c", "#fb9a99", "#e31a1c", "#fdbf6f", "#ff7f00", "#cab2d6"}
+)
+
+// group sono gli ID di un elemento e delle sue varianti (il primo è il nome originale)
+type group []int
+
+// variant restituisce l'ultima variante del gruppo, o l'originale se non ne ha
+func (g group) variant() int {
+	return g[len(g)-1]
+}
+
+// generator costruisce una fixture in modo riproducibile a partire da un seme
+type generator struct {
+	rand *rand.Rand
+}
+
+// Generate crea una fixture con un archivio disordinato: elementi con nomi quasi uguali
+// (maiuscole, refusi, suffissi), documenti distribuiti tra le varianti e viste salvate,
+// workflow e regole mail che usano le varianti. Lo stesso seme produce sempre la stessa fixture.
+func Generate(seed int64) *Fixture {
+	g := &generator{rand: rand.New(rand.NewSource(seed))}
+	f := &Fixture{}
+
+	var tags []group
+	for i, names := range g.variantGroups(tagNames, nil) {
+		var ids group
+		color := tagColors[i%len(tagColors)]
+		for j, name := range names {
+			id := len(f.Tags) + 1
+			f.Tags = append(f.Tags, paperless.Tag{
+				ID:                id,
+				Name:              name,
+				Color:             color,
+				TextColor:         "#000000",
+				MatchingAlgorithm: paperless.MatchAny,
+				IsInsensitive:     true,
+				// Solo l'originale è il tag inbox: le varianti hanno attributi diversi
+				IsInboxTag: i == 0 && j == 0,
+			})
+			if j > 0 && g.rand.Intn(2) == 0 {
+				f.Tags[id-1].Color = tagColors[g.rand.Intn(len(tagColors))]
+			}
+			ids = append(ids, id)
+		}
+		tags = append(tags, ids)
+	}
+	// Bollette e Mutuo sono figli di Casa
+	casa := tags[6][0]
+	for _, index := range []int{2, 12} {
+		f.Tags[tags[index][0]-1].Parent = &casa
+	}
+
+	var correspondents []group
+	for _, names := range g.variantGroups(correspondentNames, companySuffixes) {
+		var ids group
+		for _, name := range names {
+			id := len(f.Correspondents) + 1
+			f.Correspondents = append(f.Correspondents, paperless.Correspondent{
+				ID:                id,
+				Name:              name,
+				Match:             strings.ToLower(names[0]),
+				MatchingAlgorithm: paperless.MatchLiteral,
+				IsInsensitive:     true,
+			})
+			ids = append(ids, id)
+		}
+		correspondents = append(correspondents, ids)
+	}
+
+	var documentTypes []group
+	for _, names := range g.variantGroups(documentTypeNames, nil) {
+		var ids group
+		for _, name := range names {
+			id := len(f.DocumentTypes) + 1
+			f.DocumentTypes = append(f.DocumentTypes, paperless.DocumentType{
+				ID:                id,
+				Name:              name,
+				MatchingAlgorithm: paperless.MatchAuto,
+				IsInsensitive:     true,
+			})
+			ids = append(ids, id)
+		}
+		documentTypes = append(documentTypes, ids)
+	}
+
+	pathNames := make([]string, len(storagePaths))
+	for i, sp := range storagePaths {
+		pathNames[i] = sp.name
+	}
+	var paths []group
+	for i, names := range g.variantGroups(pathNames, nil) {
+		var ids group
+		for _, name := range names {
+			id := len(f.StoragePaths) + 1
+			f.StoragePaths = append(f.StoragePaths, paperless.StoragePath{
+				ID:                id,
+				Name:              name,
+				Path:              storagePaths[i].path,
+				MatchingAlgorithm: paperless.MatchNone,
+				IsInsensitive:     true,
+			})
+			ids = append(ids, id)
+		}
+		paths = append(paths, ids)
+	}
+
+	fieldNames := make([]string, len(customFields))
+	for i, cf := range customFields {
+		fieldNames[i] = cf.name
+	}
+	var fields []group
+	options := make(map[int][]string) // ID delle opzioni dei campi select
+	for i, names := range g.variantGroups(fieldNames, nil) {
+		var ids group
+		for j, name := range names {
+			id := len(f.CustomFields) + 1
+			field := paperless.CustomField{ID: id, Name: name, DataType: customFields[i].dataType}
+			if labels := customFields[i].options; labels != nil {
+				// Le varianti hanno un'opzione in più, da riportare sul sopravvissuto
+				if j > 0 {
+					labels = append(append([]string{}, labels...), "Annullato")
+				}
+				field.ExtraData, options[id] = g.selectOptions(labels)
+			}
+			f.CustomFields = append(f.CustomFields, field)
+			ids = append(ids, id)
+		}
+		fields = append(fields, ids)
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id := 1; id <= generatedDocuments; id++ {
+		created := start.AddDate(0, 0, g.rand.Intn(7*365))
+		doc := paperless.Document{
+			ID:      id,
+			Created: created.Format("2006-01-02"),
+			Tags:    []int{},
+		}
+
+		title := fmt.Sprintf("Documento %d", id)
+		if g.rand.Intn(10) > 0 {
+			index, corr := g.pick(correspondents)
+			doc.Correspondent = &corr
+			title = fmt.Sprintf("%s %s", correspondentNames[index], created.Format("01/2006"))
+		}
+		if g.rand.Intn(7) > 0 {
+			index, docType := g.pick(documentTypes)
+			doc.DocumentType = &docType
+			title = fmt.Sprintf("%s %s", documentTypeNames[index], title)
+		}
+		doc.Title = title
+		if g.rand.Intn(5) < 2 {
+			_, path := g.pick(paths)
+			doc.StoragePath = &path
+		}
+
+		for n := g.rand.Intn(4); n > 0; n-- {
+			if _, tag := g.pick(tags); !contains(doc.Tags, tag) {
+				doc.Tags = append(doc.Tags, tag)
+			}
+		}
+
+		doc.CustomFields = []paperless.CustomFieldInstance{}
+		for n := g.rand.Intn(3); n > 0; n-- {
+			index, field := g.pick(fields)
+			instance := paperless.CustomFieldInstance{Field: field, Value: g.fieldValue(customFields[index].dataType, options[field], created)}
+			duplicate := false
+			for _, other := range doc.CustomFields {
+				// Un documento non ha due valori dello stesso campo né di due varianti
+				if fieldGroup(fields, other.Field) == index {
+					duplicate = true
+				}
+			}
+			if !duplicate {
+				doc.CustomFields = append(doc.CustomFields, instance)
+			}
+		}
+
+		f.Documents = append(f.Documents, doc)
+	}
+
+	// Viste salvate, workflow e regole mail usano le varianti, che il merge deve riscrivere
+	f.SavedViews = []paperless.SavedView{
+		{ID: 1, Name: "Bollette da pagare", FilterRules: []paperless.FilterRule{
+			filterRule(6, tags[3].variant()),
+			filterRule(4, documentTypes[4].variant()),
+		}},
+		{ID: 2, Name: "Enel", FilterRules: []paperless.FilterRule{
+			filterRule(3, correspondents[0].variant()),
+		}},
+	}
+	f.Workflows = []paperless.Workflow{
+		{
+			ID:   1,
+			Name: "Fatture in arrivo",
+			Triggers: []map[string]json.RawMessage{{
+				"id":              rawJSON(1),
+				"type":            rawJSON(1),
+				"filter_has_tags": rawJSON([]int{tags[1].variant()}),
+			}},
+			Actions: []map[string]json.RawMessage{{
+				"id":                   rawJSON(1),
+				"type":                 rawJSON(1),
+				"assign_tags":          rawJSON([]int{tags[3].variant()}),
+				"assign_document_type": rawJSON(documentTypes[0].variant()),
+				"assign_storage_path":  rawJSON(paths[2].variant()),
+				"assign_custom_fields": rawJSON([]int{fields[0].variant()}),
+			}},
+		},
+	}
+	correspondent := correspondents[0].variant()
+	documentType := documentTypes[4].variant()
+	f.MailRules = []paperless.MailRule{
+		{ID: 1, Name: "Bollette via mail", AssignTags: []int{tags[2].variant()}, AssignCorrespondent: &correspondent, AssignDocumentType: &documentType},
+	}
+
+	return f
+}
+
+// variantGroups restituisce per ogni nome l'originale seguito da 0-2 varianti disordinate,
+// senza nomi ripetuti tra tutti i gruppi
+func (g *generator) variantGroups(names, suffixes []string) [][]string {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+
+	groups := make([][]string, len(names))
+	for i, name := range names {
+		groups[i] = []string{name}
+		want := g.rand.Intn(3)
+		for attempt := 0; len(groups[i]) <= want && attempt < 10; attempt++ {
+			variant := g.mess(name, suffixes)
+			if !seen[variant] {
+				seen[variant] = true
+				groups[i] = append(groups[i], variant)
+			}
+		}
+	}
+	return groups
+}
+
+// mess restituisce il nome con un errore tipico: maiuscole, refusi, lettere mancanti,
+// punteggiatura o uno dei suffissi indicati
+func (g *generator) mess(name string, suffixes []string) string {
+	runes := []rune(name)
+	switch g.rand.Intn(6) {
+	case 0:
+		return strings.ToLower(name)
+	case 1:
+		return strings.ToUpper(name)
+	case 2:
+		// Due lettere vicine scambiate
+		i := 1 + g.rand.Intn(len(runes)-1)
+		if i < len(runes)-1 && unicode.IsLetter(runes[i]) && unicode.IsLetter(runes[i+1]) {
+			runes[i], runes[i+1] = runes[i+1], runes[i]
+			return string(runes)
+		}
+	case 3:
+		// Una lettera mancante
+		i := 1 + g.rand.Intn(len(runes)-1)
+		if unicode.IsLetter(runes[i]) {
+			return string(append(runes[:i:i], runes[i+1:]...))
+		}
+	case 4:
+		if len(suffixes) > 0 {
+			return name + suffixes[g.rand.Intn(len(suffixes))]
+		}
+	}
+	return name + "."
+}
+
+// pick sceglie un gruppo e una delle sue varianti, restituendo l'indice del gruppo e l'ID
+func (g *generator) pick(groups []group) (int, int) {
+	index := g.rand.Intn(len(groups))
+	ids := groups[index]
+	return index, ids[g.rand.Intn(len(ids))]
+}
+
+// selectOptions restituisce l'extra_data di un campo select (formato di Paperless 2.15+)
+// e gli ID delle opzioni
+func (g *generator) selectOptions(labels []string) (json.RawMessage, []string) {
+	type option struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	}
+
+	opts := make([]option, len(labels))
+	ids := make([]string, len(labels))
+	for i, label := range labels {
+		ids[i] = fmt.Sprintf("%016x", g.rand.Uint64())
+		opts[i] = option{ID: ids[i], Label: label}
+	}
+	return rawJSON(map[string]interface{}{"select_options": opts}), ids
+}
+
+// fieldValue genera il valore di un campo personalizzato del tipo indicato
+func (g *generator) fieldValue(dataType string, options []string, created time.Time) json.RawMessage {
+	switch dataType {
+	case "monetary":
+		return rawJSON(fmt.Sprintf("EUR%.2f", float64(g.rand.Intn(200000))/100))
+	case "date":
+		return rawJSON(created.AddDate(0, 0, 30).Format("2006-01-02"))
+	case paperless.CustomFieldSelect:
+		return rawJSON(options[g.rand.Intn(len(options))])
+	case "integer":
+		return rawJSON(1 + g.rand.Intn(20))
+	default:
+		return rawJSON(fmt.Sprintf("FT-%d-%04d", created.Year(), g.rand.Intn(10000)))
+	}
+}
+
+// fieldGroup restituisce l'indice del gruppo che contiene il campo
+func fieldGroup(groups []group, id int) int {
+	for i, ids := range groups {
+		if contains(ids, id) {
+			return i
+		}
+	}
+	return -1
+}
+
+func filterRule(ruleType, id int) paperless.FilterRule {
+	value := fmt.Sprint(id)
+	return paperless.FilterRule{RuleType: ruleType, Value: &value}
+}
+
+func rawJSON(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,759 @@
+// Package fake implementa in memoria il sottoinsieme dell'API REST di Paperless-ngx usato
+// dal client, per esercitarsi con i merge senza toccare un'istanza reale (--demo)
+// e per provare i merge da un capo all'altro.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credenziali accettate se la fixture non ne indica altre
+const (
+	DefaultToken    = "demo"
+	DefaultUsername = "demo"
+	DefaultPassword = "demo"
+)
+
+// Dimensioni delle pagine degli elenchi, come in Paperless. Il massimo è volutamente
+// basso perché anche gli elenchi piccoli passino dai link next.
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+)
+
+// object è un oggetto dell'API nella sua forma JSON
+type object = map[string]interface{}
+
+// itemResources sono le risorse degli elementi che possono essere uniti, con il campo
+// dei documenti che li contiene
+var itemResources = map[string]string{
+	"tags":           "tags",
+	"correspondents": "correspondent",
+	"document_types": "document_type",
+	"storage_paths":  "storage_path",
+	"custom_fields":  "custom_fields",
+}
+
+// resources sono tutte le risorse esposte dal server
+var resources = []string{
+	"tags", "correspondents", "document_types", "storage_paths", "custom_fields",
+	"documents", "saved_views", "workflows", "mail_rules",
+}
+
+// Server è un'istanza finta di Paperless-ngx con lo stato in memoria
+type Server struct {
+	// Latency è il ritardo aggiunto a ogni risposta, per vedere avanzare le operazioni
+	Latency time.Duration
+
+	token      string
+	username   string
+	password   string
+	requireMFA bool
+	bulkEdit   bool
+
+	mu      sync.Mutex
+	objects map[string]map[int]object // Oggetti per risorsa e ID
+	nextID  map[string]int
+}
+
+// NewServer crea il server con lo stato iniziale della fixture
+func NewServer(fixture *Fixture) (*Server, error) {
+	s := &Server{
+		token:      fixture.Token,
+		username:   fixture.Username,
+		password:   fixture.Password,
+		requireMFA: fixture.RequireMFA,
+		bulkEdit:   !fixture.DisableBulkEdit,
+		objects:    make(map[string]map[int]object, len(resources)),
+		nextID:     make(map[string]int, len(resources)),
+	}
+	if s.token == "" {
+		s.token = DefaultToken
+	}
+	if s.username == "" {
+		s.username = DefaultUsername
+	}
+	if s.password == "" {
+		s.password = DefaultPassword
+	}
+
+	lists := map[string]interface{}{
+		"tags":           fixture.Tags,
+		"correspondents": fixture.Correspondents,
+		"document_types": fixture.DocumentTypes,
+		"storage_paths":  fixture.StoragePaths,
+		"custom_fields":  fixture.CustomFields,
+		"documents":      fixture.Documents,
+		"saved_views":    fixture.SavedViews,
+		"workflows":      fixture.Workflows,
+		"mail_rules":     fixture.MailRules,
+	}
+	for _, resource := range resources {
+		// Gli oggetti della fixture passano dalla forma JSON, la stessa delle risposte
+		data, err := json.Marshal(lists[resource])
+		if err != nil {
+			return nil, err
+		}
+		var objs []object
+		if err := json.Unmarshal(data, &objs); err != nil {
+			return nil, err
+		}
+
+		s.objects[resource] = make(map[int]object, len(objs))
+		s.nextID[resource] = 1
+		for _, obj := range objs {
+			id, _ := intValue(obj["id"])
+			if id <= 0 {
+				return nil, fmt.Errorf("%s: oggetto senza ID nella fixture", resource)
+			}
+			if _, ok := s.objects[resource][id]; ok {
+				return nil, fmt.Errorf("%s: ID %d duplicato nella fixture", resource, id)
+			}
+			s.objects[resource][id] = obj
+			if id >= s.nextID[resource] {
+				s.nextID[resource] = id + 1
+			}
+		}
+	}
+
+	for _, doc := range s.objects["documents"] {
+		normalizeDocument(doc)
+	}
+	return s, nil
+}
+
+// ServeHTTP risponde alle richieste /api/... come farebbe Paperless-ngx
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Latency > 0 {
+		time.Sleep(s.Latency)
+	}
+
+	// Il token si ottiene con nome utente e password, senza essere autenticati
+	if strings.Trim(r.URL.Path, "/") == "api/token" {
+		s.serveToken(w, r)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Token "+s.token {
+		writeJSON(w, http.StatusUnauthorized, object{"detail": "Invalid token."})
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api"), "/")
+	parts := strings.Split(path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case path == "":
+		s.serveRoot(w, r)
+	case !s.known(parts[0]) || len(parts) > 2:
+		writeNotFound(w)
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			s.serveList(w, r, parts[0])
+		case http.MethodPost:
+			s.serveCreate(w, r, parts[0])
+		default:
+			writeMethodNotAllowed(w, r)
+		}
+	case parts[0] == "documents" && parts[1] == "bulk_edit":
+		if !s.bulkEdit {
+			writeNotFound(w)
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+		s.serveBulkEdit(w, r)
+	default:
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			writeNotFound(w)
+			return
+		}
+		obj, ok := s.objects[parts[0]][id]
+		if !ok {
+			writeNotFound(w)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.present(parts[0], obj))
+		case http.MethodPatch:
+			s.servePatch(w, r, parts[0], obj)
+		case http.MethodDelete:
+			s.delete(parts[0], id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeMethodNotAllowed(w, r)
+		}
+	}
+}
+
+// serveToken restituisce il token a chi invia nome utente e password (POST /api/token/)
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		writeJSON(w, http.StatusBadRequest, object{"detail": "JSON parse error - " + err.Error()})
+		return
+	}
+
+	switch {
+	case credentials.Username != s.username || credentials.Password != s.password:
+		writeJSON(w, http.StatusBadRequest, object{"non_field_errors": []string{"Unable to log in with provided credentials."}})
+	case s.requireMFA && credentials.Code == "":
+		writeJSON(w, http.StatusBadRequest, object{"non_field_errors": []string{"MFA code is required"}})
+	case s.requireMFA:
+		writeJSON(w, http.StatusBadRequest, object{"non_field_errors": []string{"Invalid MFA code"}})
+	default:
+		writeJSON(w, http.StatusOK, object{"token": s.token})
+	}
+}
+
+// known indica se il server espone la risorsa
+func (s *Server) known(resource string) bool {
+	_, ok := s.objects[resource]
+	return ok
+}
+
+// serveRoot elenca le risorse, come la radice dell'API
+func (s *Server) serveRoot(w http.ResponseWriter, r *http.Request) {
+	root := make(object, len(resources))
+	for _, resource := range resources {
+		root[resource] = absoluteURL(r, "/api/"+resource+"/", nil)
+	}
+	writeJSON(w, http.StatusOK, root)
+}
+
+// serveList restituisce una pagina dell'elenco, filtrata e ordinata per ID
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request, resource string) {
+	query := r.URL.Query()
+
+	ids := make([]int, 0, len(s.objects[resource]))
+	for id, obj := range s.objects[resource] {
+		if resource != "documents" || matchDocument(obj, query) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	pageSize := defaultPageSize
+	if value, err := strconv.Atoi(query.Get("page_size")); err == nil && value > 0 {
+		pageSize = value
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	page := 1
+	if value := query.Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			writeJSON(w, http.StatusNotFound, object{"detail": "Invalid page."})
+			return
+		}
+	}
+
+	start := (page - 1) * pageSize
+	if start > 0 && start >= len(ids) {
+		writeJSON(w, http.StatusNotFound, object{"detail": "Invalid page."})
+		return
+	}
+	end := start + pageSize
+	if end > len(ids) {
+		end = len(ids)
+	}
+
+	results := make([]object, 0, end-start)
+	for _, id := range ids[start:end] {
+		results = append(results, s.present(resource, s.objects[resource][id]))
+	}
+
+	list := object{"count": len(ids), "next": nil, "previous": nil, "results": results}
+	if end < len(ids) {
+		list["next"] = absoluteURL(r, r.URL.Path, pageQuery(query, page+1))
+	}
+	if page > 1 {
+		list["previous"] = absoluteURL(r, r.URL.Path, pageQuery(query, page-1))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// serveCreate crea un oggetto con i campi del corpo della richiesta
+func (s *Server) serveCreate(w http.ResponseWriter, r *http.Request, resource string) {
+	if resource == "documents" {
+		// I documenti si caricano da /api/documents/post_document/, che il client non usa
+		writeMethodNotAllowed(w, r)
+		return
+	}
+
+	var fields object
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		writeJSON(w, http.StatusBadRequest, object{"detail": "JSON parse error - " + err.Error()})
+		return
+	}
+	if errs := s.validate(resource, 0, fields); errs != nil {
+		writeJSON(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	id := s.nextID[resource]
+	s.nextID[resource]++
+
+	obj := defaults(resource)
+	for key, value := range fields {
+		obj[key] = value
+	}
+	obj["id"] = float64(id)
+	s.objects[resource][id] = obj
+
+	writeJSON(w, http.StatusCreated, s.present(resource, obj))
+}
+
+// servePatch aggiorna i campi dell'oggetto presenti nel corpo della richiesta
+func (s *Server) servePatch(w http.ResponseWriter, r *http.Request, resource string, obj object) {
+	var fields object
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		writeJSON(w, http.StatusBadRequest, object{"detail": "JSON parse error - " + err.Error()})
+		return
+	}
+	delete(fields, "id")
+
+	id, _ := intValue(obj["id"])
+	if errs := s.validate(resource, id, fields); errs != nil {
+		writeJSON(w, http.StatusBadRequest, errs)
+		return
+	}
+
+	for key, value := range fields {
+		obj[key] = value
+	}
+	if resource == "documents" {
+		normalizeDocument(obj)
+	}
+	writeJSON(w, http.StatusOK, s.present(resource, obj))
+}
+
+// validate controlla i campi di una creazione o di una modifica (id 0 per la creazione) e
+// restituisce gli errori per campo, nella forma di Django REST Framework
+func (s *Server) validate(resource string, id int, fields object) object {
+	errs := make(object)
+
+	if _, ok := itemResources[resource]; ok {
+		if name, present := fields["name"]; present || id == 0 {
+			name, _ := name.(string)
+			if strings.TrimSpace(name) == "" {
+				errs["name"] = []string{"This field may not be blank."}
+			} else if other := s.findByName(resource, name); other != 0 && other != id {
+				errs["name"] = []string{fmt.Sprintf("%s with this name already exists.", resourceLabel(resource))}
+			}
+		}
+		if parent, ok := intValue(fields["parent"]); ok && resource == "tags" {
+			if _, exists := s.objects["tags"][parent]; !exists || parent == id {
+				errs["parent"] = []string{invalidPK(parent)}
+			}
+		}
+	}
+
+	if resource == "documents" {
+		for itemResource, field := range itemResources {
+			value, present := fields[field]
+			if !present {
+				continue
+			}
+			for _, ref := range references(itemResource, value) {
+				if _, exists := s.objects[itemResource][ref]; !exists {
+					errs[field] = []string{invalidPK(ref)}
+					break
+				}
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// findByName restituisce l'ID dell'elemento con il nome indicato, o 0 se non esiste
+func (s *Server) findByName(resource, name string) int {
+	for id, obj := range s.objects[resource] {
+		if obj["name"] == name {
+			return id
+		}
+	}
+	return 0
+}
+
+// delete elimina un oggetto; i documenti perdono i riferimenti all'elemento eliminato
+// e i tag figli diventano tag principali
+func (s *Server) delete(resource string, id int) {
+	delete(s.objects[resource], id)
+
+	field, ok := itemResources[resource]
+	if !ok {
+		return
+	}
+	for _, doc := range s.objects["documents"] {
+		switch resource {
+		case "tags":
+			doc["tags"] = toValues(without(intList(doc["tags"]), id))
+		case "custom_fields":
+			instances := doc["custom_fields"].([]interface{})
+			kept := make([]interface{}, 0, len(instances))
+			for _, instance := range instances {
+				if fieldID, _ := intValue(instance.(object)["field"]); fieldID != id {
+					kept = append(kept, instance)
+				}
+			}
+			doc["custom_fields"] = kept
+		default:
+			if ref, ok := intValue(doc[field]); ok && ref == id {
+				doc[field] = nil
+			}
+		}
+	}
+	if resource == "tags" {
+		for _, tag := range s.objects["tags"] {
+			if parent, ok := intValue(tag["parent"]); ok && parent == id {
+				tag["parent"] = nil
+			}
+		}
+	}
+}
+
+// bulkEditRequest è il corpo di /api/documents/bulk_edit/
+type bulkEditRequest struct {
+	Documents  []int           `json:"documents"`
+	Method     string          `json:"method"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// serveBulkEdit applica un'operazione a più documenti
+func (s *Server) serveBulkEdit(w http.ResponseWriter, r *http.Request) {
+	var req bulkEditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, object{"detail": "JSON parse error - " + err.Error()})
+		return
+	}
+	for _, id := range req.Documents {
+		if _, ok := s.objects["documents"][id]; !ok {
+			writeJSON(w, http.StatusBadRequest, object{"documents": []string{fmt.Sprintf("Some documents in [%d] don't exist or were specified twice.", id)}})
+			return
+		}
+	}
+
+	var fields object
+	switch req.Method {
+	case "modify_tags":
+		var params struct {
+			AddTags    []int `json:"add_tags"`
+			RemoveTags []int `json:"remove_tags"`
+		}
+		if err := json.Unmarshal(req.Parameters, &params); err != nil {
+			writeJSON(w, http.StatusBadRequest, object{"parameters": []string{err.Error()}})
+			return
+		}
+		for _, id := range append(append([]int{}, params.AddTags...), params.RemoveTags...) {
+			if _, ok := s.objects["tags"][id]; !ok {
+				writeJSON(w, http.StatusBadRequest, object{"parameters": []string{invalidPK(id)}})
+				return
+			}
+		}
+		for _, id := range req.Documents {
+			doc := s.objects["documents"][id]
+			tags := intList(doc["tags"])
+			for _, tag := range params.RemoveTags {
+				tags = without(tags, tag)
+			}
+			for _, tag := range params.AddTags {
+				tags = append(without(tags, tag), tag)
+			}
+			doc["tags"] = toValues(tags)
+		}
+		writeJSON(w, http.StatusOK, object{"result": "OK"})
+		return
+
+	case "set_correspondent", "set_document_type", "set_storage_path":
+		if err := json.Unmarshal(req.Parameters, &fields); err != nil {
+			writeJSON(w, http.StatusBadRequest, object{"parameters": []string{err.Error()}})
+			return
+		}
+		field := strings.TrimPrefix(req.Method, "set_")
+		fields = object{field: fields[field]}
+
+	default:
+		writeJSON(w, http.StatusBadRequest, object{"method": []string{fmt.Sprintf("\"%s\" is not a valid choice.", req.Method)}})
+		return
+	}
+
+	if errs := s.validate("documents", 0, fields); errs != nil {
+		writeJSON(w, http.StatusBadRequest, object{"parameters": errs})
+		return
+	}
+	for _, id := range req.Documents {
+		for key, value := range fields {
+			s.objects["documents"][id][key] = value
+		}
+	}
+	writeJSON(w, http.StatusOK, object{"result": "OK"})
+}
+
+// present restituisce l'oggetto come lo mostra l'API, con i campi calcolati dal server
+// (numero di documenti, tag figli, data dell'ultimo documento, permessi)
+func (s *Server) present(resource string, obj object) object {
+	out := make(object, len(obj)+3)
+	for key, value := range obj {
+		out[key] = value
+	}
+	if _, ok := itemResources[resource]; !ok {
+		return out
+	}
+
+	id, _ := intValue(obj["id"])
+	count := 0
+	var last string
+	for _, doc := range s.objects["documents"] {
+		if !contains(references(resource, doc[itemResources[resource]]), id) {
+			continue
+		}
+		count++
+		if created, _ := doc["created"].(string); created > last {
+			last = created
+		}
+	}
+	out["document_count"] = count
+	out["user_can_change"] = true
+
+	switch resource {
+	case "tags":
+		children := []int{}
+		for childID, tag := range s.objects["tags"] {
+			if parent, ok := intValue(tag["parent"]); ok && parent == id {
+				children = append(children, childID)
+			}
+		}
+		sort.Ints(children)
+		out["children"] = children
+	case "correspondents":
+		out["last_correspondence"] = nil
+		if last != "" {
+			out["last_correspondence"] = last
+		}
+	}
+	return out
+}
+
+// defaults restituisce i campi di un nuovo oggetto non indicati nella creazione
+func defaults(resource string) object {
+	obj := object{"owner": nil}
+	if _, ok := itemResources[resource]; ok && resource != "custom_fields" {
+		obj["match"] = ""
+		obj["matching_algorithm"] = float64(1)
+		obj["is_insensitive"] = true
+	}
+	switch resource {
+	case "tags":
+		obj["colour"] = "#a6cee3"
+		obj["text_color"] = "#000000"
+		obj["is_inbox_tag"] = false
+		obj["parent"] = nil
+	case "storage_paths":
+		obj["path"] = ""
+	case "custom_fields":
+		obj["extra_data"] = object{}
+	}
+	return obj
+}
+
+// matchDocument indica se il documento soddisfa i filtri degli elenchi:
+// <campo>__id, <campo>__id__in (uno degli ID) e <campo>__id__all (tutti gli ID)
+func matchDocument(doc object, query url.Values) bool {
+	for resource, field := range itemResources {
+		refs := references(resource, doc[field])
+		for _, filter := range []string{"__id", "__id__in", "__id__all"} {
+			value := query.Get(field + filter)
+			if value == "" {
+				continue
+			}
+			var ids []int
+			for _, part := range strings.Split(value, ",") {
+				id, err := strconv.Atoi(strings.TrimSpace(part))
+				if err != nil {
+					return false
+				}
+				ids = append(ids, id)
+			}
+
+			found := 0
+			for _, id := range ids {
+				if contains(refs, id) {
+					found++
+				}
+			}
+			if found == 0 || (filter == "__id__all" && found < len(ids)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// references restituisce gli ID degli elementi della risorsa contenuti nel campo di un documento
+func references(resource string, value interface{}) []int {
+	switch resource {
+	case "tags":
+		return intList(value)
+	case "custom_fields":
+		instances, _ := value.([]interface{})
+		var ids []int
+		for _, instance := range instances {
+			if fields, ok := instance.(object); ok {
+				if id, ok := intValue(fields["field"]); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+		return ids
+	default:
+		if id, ok := intValue(value); ok {
+			return []int{id}
+		}
+		return nil
+	}
+}
+
+// normalizeDocument sostituisce gli elenchi null con elenchi vuoti, come li restituisce l'API
+func normalizeDocument(doc object) {
+	for _, field := range []string{"tags", "custom_fields"} {
+		if _, ok := doc[field].([]interface{}); !ok {
+			doc[field] = []interface{}{}
+		}
+	}
+}
+
+// intValue legge un ID da un valore JSON decodificato
+func intValue(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
+
+// intList legge un elenco di ID da un valore JSON decodificato
+func intList(value interface{}) []int {
+	values, _ := value.([]interface{})
+	ids := make([]int, 0, len(values))
+	for _, v := range values {
+		if id, ok := intValue(v); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// toValues converte un elenco di ID nella forma JSON decodificata
+func toValues(ids []int) []interface{} {
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = float64(id)
+	}
+	return values
+}
+
+func contains(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func without(ids []int, id int) []int {
+	kept := make([]int, 0, len(ids))
+	for _, v := range ids {
+		if v != id {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// resourceLabel restituisce il nome del modello usato nei messaggi di Paperless
+func resourceLabel(resource string) string {
+	switch resource {
+	case "tags":
+		return "tag"
+	case "correspondents":
+		return "correspondent"
+	case "document_types":
+		return "document type"
+	case "storage_paths":
+		return "storage path"
+	default:
+		return "custom field"
+	}
+}
+
+func invalidPK(id int) string {
+	return fmt.Sprintf("Invalid pk \"%d\" - object does not exist.", id)
+}
+
+// absoluteURL costruisce un link assoluto verso il server, come i link next dell'API
+func absoluteURL(r *http.Request, path string, query url.Values) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// pageQuery restituisce i parametri della richiesta con la pagina indicata
+func pageQuery(query url.Values, page int) url.Values {
+	q := make(url.Values, len(query)+1)
+	for key, values := range query {
+		q[key] = values
+	}
+	q.Set("page", strconv.Itoa(page))
+	return q
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeNotFound(w http.ResponseWriter) {
+	writeJSON(w, http.StatusNotFound, object{"detail": "Not found."})
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusMethodNotAllowed, object{"detail": fmt.Sprintf("Method \"%s\" not allowed.", r.Method)})
+}
@@ -0,0 +1,90 @@
+package paperless
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// limiter distanzia le richieste (richieste al secondo) e ne limita quante sono in corso
+// contemporaneamente, per non sovraccaricare un'istanza piccola
+type limiter struct {
+	interval time.Duration // Distanza minima tra l'inizio di due richieste (0 per nessun limite)
+	slots    chan struct{} // Richieste in corso (nil per nessun limite)
+
+	mu   sync.Mutex
+	next time.Time // Primo istante in cui può partire la prossima richiesta
+}
+
+// SetRateLimit limita le richieste al secondo e quelle contemporanee (0 per nessun limite)
+func (c *Client) SetRateLimit(perSecond float64, maxConcurrent int) {
+	l := &limiter{}
+	if perSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	c.limiter = l
+}
+
+// acquire attende il proprio turno e restituisce la funzione che libera il posto
+// occupato dalla richiesta
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if l.interval > 0 {
+		if err := l.wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+// wait attende il turno della richiesta e lo prenota solo quando arriva: una richiesta
+// annullata durante l'attesa non sposta in avanti il turno delle altre
+func (l *limiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if !l.next.After(now) {
+			l.next = now.Add(l.interval)
+			l.mu.Unlock()
+			return nil
+		}
+		start := l.next
+		l.mu.Unlock()
+
+		// Un'altra richiesta può prendere il turno durante l'attesa: si ricontrolla
+		if err := sleep(ctx, time.Until(start)); err != nil {
+			return err
+		}
+	}
+}
+
+// releaseBody libera il posto della richiesta quando il corpo della risposta viene chiuso
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,37 @@
+package paperless
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterCancelledWaitKeepsTurn(t *testing.T) {
+	l := &limiter{interval: 100 * time.Millisecond}
+
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+	next := l.next
+
+	// Una richiesta annullata mentre attende il turno non lo prenota
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire annullata: errore %v, atteso context.DeadlineExceeded", err)
+	}
+	if !l.next.Equal(next) {
+		t.Errorf("turno spostato da %v a %v dalla richiesta annullata", next, l.next)
+	}
+
+	// La richiesta successiva parte al turno originale, non a quello dopo
+	if _, err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if late := time.Since(next); late > l.interval/2 {
+		t.Errorf("la richiesta successiva è partita %v dopo il proprio turno", late)
+	}
+}
@@ -0,0 +1,88 @@
+package paperless
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrProxyAuthConflict indica credenziali basic del proxy senza un header dedicato al
+// token: entrambi finirebbero in Authorization e il token sostituirebbe le credenziali
+var ErrProxyAuthConflict = errors.New("con la basic auth del proxy il token di Paperless richiede un header diverso da Authorization")
+
+// ProxyAuth sono le credenziali per raggiungere Paperless-ngx dietro un reverse proxy
+// con autenticazione (es. Authelia o Traefik con forward auth)
+type ProxyAuth struct {
+	// Headers sono aggiunti a ogni richiesta, es. il cookie di sessione del proxy
+	// ("Cookie: authelia_session=...") o un suo token
+	Headers map[string]string
+	// BasicUser e BasicPassword sono le credenziali HTTP basic chieste dal proxy,
+	// inviate nell'header Authorization
+	BasicUser     string
+	BasicPassword string
+	// TokenHeader è l'header in cui inviare il token di Paperless quando il proxy usa
+	// Authorization (vuoto per Authorization, non ammesso con BasicUser). Il proxy deve
+	// poi riportarlo in Authorization.
+	TokenHeader string
+}
+
+// Validate verifica che le credenziali del proxy e il token non usino lo stesso header
+func (a ProxyAuth) Validate() error {
+	if a.BasicUser != "" && (a.TokenHeader == "" || strings.EqualFold(a.TokenHeader, "Authorization")) {
+		return ErrProxyAuthConflict
+	}
+	return nil
+}
+
+// SetProxyAuth imposta le credenziali del reverse proxy davanti a Paperless-ngx.
+// Restituisce ErrProxyAuthConflict, lasciando il client invariato, se basic auth e
+// token dovrebbero usare entrambi l'header Authorization.
+func (c *Client) SetProxyAuth(auth ProxyAuth) error {
+	if err := auth.Validate(); err != nil {
+		return err
+	}
+	c.proxy = auth
+	return nil
+}
+
+// authorize aggiunge alla richiesta gli header del proxy e il token di Paperless
+func (c *Client) authorize(req *http.Request) {
+	for name, value := range c.proxy.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.proxy.BasicUser != "" {
+		req.SetBasicAuth(c.proxy.BasicUser, c.proxy.BasicPassword)
+	}
+
+	// Senza token (es. nella richiesta del token stesso) l'header non viene inviato:
+	// un header "Token " vuoto verrebbe rifiutato dal server
+	if c.APIKey == "" {
+		return
+	}
+	header := c.proxy.TokenHeader
+	if header == "" {
+		header = "Authorization"
+	}
+	req.Header.Set(header, fmt.Sprintf("Token %s", c.APIKey))
+}
+
+// sameHostRedirect segue i redirect solo verso lo stesso host (es. da http a https o per
+// la barra finale): un proxy che rimanda alla propria pagina di login restituisce così
+// il redirect come errore invece della pagina HTML
+func sameHostRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("troppi redirect")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
+// IsRedirect indica se il server ha risposto con un redirect verso un altro host,
+// tipicamente la pagina di login di un reverse proxy
+func IsRedirect(err error) bool {
+	status := StatusCode(err)
+	return status >= 300 && status < 400
+}
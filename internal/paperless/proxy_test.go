@@ -0,0 +1,63 @@
+package paperless_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+func TestProxyAuthSendsBasicAuthAndToken(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"name":"Tag"}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	client := paperless.NewClient(ts.URL, "segreto")
+	err := client.SetProxyAuth(paperless.ProxyAuth{
+		Headers:       map[string]string{"Cookie": "authelia_session=abc"},
+		BasicUser:     "proxy",
+		BasicPassword: "password",
+		TokenHeader:   "X-Paperless-Token",
+	})
+	if err != nil {
+		t.Fatalf("SetProxyAuth: %v", err)
+	}
+	if _, err := client.GetTag(context.Background(), 1); err != nil {
+		t.Fatalf("GetTag: %v", err)
+	}
+
+	want := map[string]string{
+		// "proxy:password" in base64
+		"Authorization":     "Basic cHJveHk6cGFzc3dvcmQ=",
+		"X-Paperless-Token": "Token segreto",
+		"Cookie":            "authelia_session=abc",
+	}
+	for name, value := range want {
+		if got.Get(name) != value {
+			t.Errorf("header %s: %q, atteso %q", name, got.Get(name), value)
+		}
+	}
+}
+
+func TestSetProxyAuthRejectsSharedAuthorization(t *testing.T) {
+	for _, tokenHeader := range []string{"", "Authorization", "authorization"} {
+		client := paperless.NewClient("http://paperless.invalid", "segreto")
+		err := client.SetProxyAuth(paperless.ProxyAuth{BasicUser: "proxy", BasicPassword: "password", TokenHeader: tokenHeader})
+		if !errors.Is(err, paperless.ErrProxyAuthConflict) {
+			t.Errorf("TokenHeader %q: errore %v, atteso ErrProxyAuthConflict", tokenHeader, err)
+		}
+	}
+
+	// Senza basic auth il token può restare in Authorization
+	client := paperless.NewClient("http://paperless.invalid", "segreto")
+	if err := client.SetProxyAuth(paperless.ProxyAuth{Headers: map[string]string{"Cookie": "sessione"}}); err != nil {
+		t.Errorf("SetProxyAuth senza basic auth: %v", err)
+	}
+}
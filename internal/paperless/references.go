@@ -0,0 +1,183 @@
+package paperless
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SavedView rappresenta una vista salvata di Paperless
+type SavedView struct {
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	FilterRules []FilterRule `json:"filter_rules"`
+}
+
+// FilterRule è una regola di filtro di una vista salvata.
+// Per le regole su tag, corrispondenti, ecc. Value contiene l'ID dell'elemento.
+type FilterRule struct {
+	RuleType int     `json:"rule_type"`
+	Value    *string `json:"value"`
+}
+
+// Workflow rappresenta un workflow di Paperless. Trigger e azioni restano in forma
+// generica perché i loro campi cambiano tra le versioni di Paperless.
+type Workflow struct {
+	ID       int                          `json:"id"`
+	Name     string                       `json:"name"`
+	Triggers []map[string]json.RawMessage `json:"triggers"`
+	Actions  []map[string]json.RawMessage `json:"actions"`
+}
+
+// MailRule rappresenta una regola mail di Paperless (solo i campi che assegnano elementi)
+type MailRule struct {
+	ID                  int    `json:"id"`
+	Name                string `json:"name"`
+	AssignTags          []int  `json:"assign_tags"`
+	AssignCorrespondent *int   `json:"assign_correspondent"`
+	AssignDocumentType  *int   `json:"assign_document_type"`
+}
+
+// savedViewRulesPayload è il corpo JSON per sostituire le regole di filtro di una vista salvata
+type savedViewRulesPayload struct {
+	FilterRules []FilterRule `json:"filter_rules"`
+}
+
+// workflowPayload è il corpo JSON per sostituire trigger e azioni di un workflow
+type workflowPayload struct {
+	Triggers []map[string]json.RawMessage `json:"triggers"`
+	Actions  []map[string]json.RawMessage `json:"actions"`
+}
+
+// mailRuleAssignPayload è il corpo JSON per aggiornare gli elementi assegnati da una regola mail
+type mailRuleAssignPayload struct {
+	AssignTags          []int `json:"assign_tags"`
+	AssignCorrespondent *int  `json:"assign_correspondent"`
+	AssignDocumentType  *int  `json:"assign_document_type"`
+}
+
+// getAll recupera tutte le pagine di un elenco e passa i risultati di ogni pagina a appendPage
+func (c *Client) getAll(ctx context.Context, endpoint string, appendPage func(results json.RawMessage) error) error {
+	for endpoint != "" {
+		resp, err := c.makeRequest(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			apiErr := newAPIError("errore API", resp)
+			resp.Body.Close()
+			return apiErr
+		}
+
+		var listResp ListResponse
+		if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+			resp.Body.Close()
+			return err
+		}
+		resp.Body.Close()
+
+		if err := appendPage(listResp.Results); err != nil {
+			return err
+		}
+
+		// Se c'è una pagina successiva, prepara l'endpoint per la prossima iterazione
+		if listResp.Next != nil && *listResp.Next != "" {
+			endpoint = strings.TrimPrefix(*listResp.Next, c.BaseURL)
+		} else {
+			endpoint = ""
+		}
+	}
+
+	return nil
+}
+
+// GetSavedViews recupera tutte le viste salvate
+func (c *Client) GetSavedViews(ctx context.Context) ([]SavedView, error) {
+	var allViews []SavedView
+	err := c.getAll(ctx, "/api/saved_views/?page_size=1000", func(results json.RawMessage) error {
+		var views []SavedView
+		if err := json.Unmarshal(results, &views); err != nil {
+			return err
+		}
+		allViews = append(allViews, views...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allViews, nil
+}
+
+// UpdateSavedViewFilterRules sostituisce le regole di filtro di una vista salvata
+func (c *Client) UpdateSavedViewFilterRules(ctx context.Context, id int, rules []FilterRule) error {
+	payload := savedViewRulesPayload{FilterRules: rules}
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/saved_views/%d/", id), payload); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento della vista salvata: %w", err)
+	}
+	return nil
+}
+
+// GetWorkflows recupera tutti i workflow
+func (c *Client) GetWorkflows(ctx context.Context) ([]Workflow, error) {
+	var allWorkflows []Workflow
+	err := c.getAll(ctx, "/api/workflows/?page_size=1000", func(results json.RawMessage) error {
+		var workflows []Workflow
+		if err := json.Unmarshal(results, &workflows); err != nil {
+			return err
+		}
+		allWorkflows = append(allWorkflows, workflows...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allWorkflows, nil
+}
+
+// UpdateWorkflow sostituisce trigger e azioni di un workflow
+func (c *Client) UpdateWorkflow(ctx context.Context, id int, triggers, actions []map[string]json.RawMessage) error {
+	payload := workflowPayload{
+		Triggers: triggers,
+		Actions:  actions,
+	}
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/workflows/%d/", id), payload); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento del workflow: %w", err)
+	}
+	return nil
+}
+
+// GetMailRules recupera tutte le regole mail
+func (c *Client) GetMailRules(ctx context.Context) ([]MailRule, error) {
+	var allRules []MailRule
+	err := c.getAll(ctx, "/api/mail_rules/?page_size=1000", func(results json.RawMessage) error {
+		var rules []MailRule
+		if err := json.Unmarshal(results, &rules); err != nil {
+			return err
+		}
+		allRules = append(allRules, rules...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allRules, nil
+}
+
+// UpdateMailRule aggiorna tag, corrispondente e tipo documento assegnati da una regola mail
+func (c *Client) UpdateMailRule(ctx context.Context, rule MailRule) error {
+	payload := mailRuleAssignPayload{
+		AssignTags:          rule.AssignTags,
+		AssignCorrespondent: rule.AssignCorrespondent,
+		AssignDocumentType:  rule.AssignDocumentType,
+	}
+	if payload.AssignTags == nil {
+		payload.AssignTags = []int{}
+	}
+	if err := c.patchObject(ctx, fmt.Sprintf("/api/mail_rules/%d/", rule.ID), payload); err != nil {
+		return fmt.Errorf("errore nell'aggiornamento della regola mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package paperless
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Valori predefiniti dei tentativi per gli errori temporanei
+const (
+	DefaultRetries    = 3
+	DefaultRetryDelay = 500 * time.Millisecond // Attesa prima del secondo tentativo, poi raddoppia
+	MaxRetryDelay     = 30 * time.Second       // Attesa massima predefinita tra due tentativi
+)
+
+// SetRetries imposta quante volte ripetere una richiesta fallita per un errore temporaneo
+// (0 per non ripeterla mai)
+func (c *Client) SetRetries(retries int) {
+	if retries < 0 {
+		retries = 0
+	}
+	c.retries = retries
+}
+
+// SetMaxRetryDelay imposta l'attesa massima tra due tentativi (0 per il valore predefinito).
+// Vale anche per l'header Retry-After: un server che chiede di attendere di più riceve il
+// nuovo tentativo prima, per non bloccare l'operazione per ore.
+func (c *Client) SetMaxRetryDelay(delay time.Duration) {
+	if delay <= 0 {
+		delay = MaxRetryDelay
+	}
+	c.maxRetryDelay = delay
+}
+
+// idempotent indica se la richiesta può essere ripetuta senza effetti diversi dal primo invio.
+// Le PATCH del client inviano sempre valori assoluti (il nome, l'elenco completo dei tag, ...)
+// e quindi possono essere ripetute; le POST creano oggetti e non vengono ripetute.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryDelay decide se ripetere una richiesta dopo il tentativo attempt (da 0) e quanto attendere.
+// Un 429 indica che il server non ha eseguito la richiesta, che quindi può sempre essere
+// ripetuta; 502, 503, 504 ed errori di rete solo se la richiesta è idempotente.
+func (c *Client) retryDelay(ctx context.Context, method string, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.retries || ctx.Err() != nil {
+		return 0, false
+	}
+
+	if err != nil {
+		return backoff(attempt, c.maxRetryDelay), idempotent(method)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !idempotent(method) {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	// Un Retry-After di ore (o una data lontana) non deve bloccare l'operazione: l'attesa
+	// si ferma a maxRetryDelay, configurabile con SetMaxRetryDelay
+	if wait, ok := retryAfter(resp.Header.Get("Retry-After"), c.maxRetryDelay); ok {
+		return wait, true
+	}
+	return backoff(attempt, c.maxRetryDelay), true
+}
+
+// backoff restituisce l'attesa esponenziale per il tentativo indicato (al massimo limit),
+// con una parte casuale (tra metà e l'intero intervallo) perché più richieste non si
+// ripetano insieme
+func backoff(attempt int, limit time.Duration) time.Duration {
+	delay := DefaultRetryDelay << attempt
+	if delay <= 0 || delay > limit {
+		delay = limit
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter interpreta l'header Retry-After (secondi di attesa o data HTTP), limitando
+// l'attesa a limit
+func retryAfter(value string, limit time.Duration) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			seconds = 0
+		}
+		// Oltre l'attesa massima la conversione in Duration potrebbe andare in overflow
+		if seconds > int(limit/time.Second) {
+			return limit, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		if wait > limit {
+			wait = limit
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleep attende per la durata indicata o finché l'operazione non viene annullata
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package paperless
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryDelayClampsRetryAfter(t *testing.T) {
+	c := NewClient("http://paperless.invalid", "token")
+
+	cases := []struct {
+		retryAfter string
+		want       time.Duration
+	}{
+		{"5", 5 * time.Second},
+		{"3600", MaxRetryDelay},
+		{"99999999999999999", MaxRetryDelay},
+		{time.Now().Add(2 * time.Hour).UTC().Format(http.TimeFormat), MaxRetryDelay},
+	}
+	for _, tc := range cases {
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {tc.retryAfter}}}
+		wait, retry := c.retryDelay(context.Background(), http.MethodPost, resp, nil, 0)
+		if !retry || wait != tc.want {
+			t.Errorf("Retry-After %q: attesa %v (ripeti %v), attesa prevista %v", tc.retryAfter, wait, retry, tc.want)
+		}
+	}
+}
+
+func TestSetMaxRetryDelayLimitsRetryAfter(t *testing.T) {
+	c := NewClient("http://paperless.invalid", "token")
+	c.SetMaxRetryDelay(2 * time.Minute)
+
+	cases := []struct {
+		retryAfter string
+		want       time.Duration
+	}{
+		{"90", 90 * time.Second},
+		{"3600", 2 * time.Minute},
+		{time.Now().Add(2 * time.Hour).UTC().Format(http.TimeFormat), 2 * time.Minute},
+	}
+	for _, tc := range cases {
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {tc.retryAfter}}}
+		wait, retry := c.retryDelay(context.Background(), http.MethodPost, resp, nil, 0)
+		if !retry || wait != tc.want {
+			t.Errorf("Retry-After %q: attesa %v (ripeti %v), attesa prevista %v", tc.retryAfter, wait, retry, tc.want)
+		}
+	}
+
+	// Il valore 0 ripristina l'attesa massima predefinita
+	c.SetMaxRetryDelay(0)
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"3600"}}}
+	if wait, _ := c.retryDelay(context.Background(), http.MethodPost, resp, nil, 0); wait != MaxRetryDelay {
+		t.Errorf("SetMaxRetryDelay(0): attesa %v, prevista %v", wait, MaxRetryDelay)
+	}
+}
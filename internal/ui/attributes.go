@@ -0,0 +1,173 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+)
+
+// attributeSource restituisce l'elemento del piano da cui il sopravvissuto prende l'attributo
+func (m ListModel) attributeSource(attr merge.Attribute) merge.ItemPreview {
+	if id, ok := m.sources[attr]; ok {
+		for _, item := range m.preview.Absorbed {
+			if item.ID == id {
+				return item
+			}
+		}
+	}
+	return m.preview.Survivor
+}
+
+// cycleSource passa all'elemento precedente (delta -1) o successivo (delta 1)
+// come fonte dell'attributo
+func (m ListModel) cycleSource(attr merge.Attribute, delta int) ListModel {
+	items := m.preview.Items()
+	current := 0
+	for i, item := range items {
+		if item.ID == m.attributeSource(attr).ID {
+			current = i
+		}
+	}
+
+	next := (current + delta + len(items)) % len(items)
+	if next == 0 {
+		delete(m.sources, attr)
+	} else {
+		m.sources[attr] = items[next].ID
+	}
+	return m
+}
+
+// planSources restituisce gli attributi che il sopravvissuto prende da altri elementi,
+// tralasciando quelli che non cambierebbero valore
+func (m ListModel) planSources() map[merge.Attribute]int {
+	if m.preview == nil {
+		return nil
+	}
+
+	var sources map[merge.Attribute]int
+	for attr, id := range m.sources {
+		if attr.SameValue(m.attributeSource(attr).Item, m.preview.Survivor.Item) {
+			continue
+		}
+		if sources == nil {
+			sources = make(map[merge.Attribute]int)
+		}
+		sources[attr] = id
+	}
+	return sources
+}
+
+// attributeName restituisce il nome localizzato di un attributo
+func (m ListModel) attributeName(attr merge.Attribute) string {
+	return m.localizer.T("attributes." + string(attr))
+}
+
+// attributeValue descrive il valore di un attributo di un elemento
+func (m ListModel) attributeValue(attr merge.Attribute, item merge.Item) string {
+	switch attr {
+	case merge.AttrColor:
+		return item.Color
+	case merge.AttrInbox:
+		if item.IsInboxTag {
+			return m.localizer.T("attributes.yes")
+		}
+		return m.localizer.T("attributes.no")
+	case merge.AttrOwner:
+		if item.Owner == nil {
+			return m.localizer.T("attributes.no_owner")
+		}
+		return fmt.Sprintf(m.localizer.T("attributes.user"), *item.Owner)
+	case merge.AttrParent:
+		if item.Parent == nil {
+			return m.localizer.T("attributes.no_parent")
+		}
+		for _, other := range m.allItems {
+			if other.ID == *item.Parent {
+				return fmt.Sprintf("\"%s\"", other.Name)
+			}
+		}
+		return fmt.Sprintf("#%d", *item.Parent)
+	}
+	return ""
+}
+
+func (m ListModel) updateAttributesMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	attrs := merge.Attributes(m.entityType)
+
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "enter":
+		m.mode = "plan"
+
+	case "up", "k":
+		if m.optCursor > 0 {
+			m.optCursor--
+		}
+
+	case "down", "j":
+		if m.optCursor < len(attrs)-1 {
+			m.optCursor++
+		}
+
+	case "left", "h":
+		return m.cycleSource(attrs[m.optCursor], -1), nil
+
+	case "right", "l":
+		return m.cycleSource(attrs[m.optCursor], 1), nil
+	}
+
+	return m, nil
+}
+
+func (m ListModel) viewAttributes() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("attributes.title"), m.preview.Survivor.Name)) + "\n\n"
+
+	for i, attr := range merge.Attributes(m.entityType) {
+		source := m.attributeSource(attr)
+		line := fmt.Sprintf(m.localizer.T("attributes.item"), m.attributeName(attr), m.attributeValue(attr, source.Item), source.Name)
+		if i == m.optCursor {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += normalStyle.Render("  "+line) + "\n"
+		}
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("attributes.help")) + "\n"
+	return s
+}
+
+// viewPlanAttributes mostra nel piano gli attributi che il sopravvissuto prende da altri elementi
+func (m ListModel) viewPlanAttributes() string {
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	sources := m.planSources()
+	if len(sources) == 0 {
+		return ""
+	}
+
+	var s string
+	s += normalStyle.Render(m.localizer.T("list.plan_attributes")) + "\n"
+	for _, attr := range merge.Attributes(m.entityType) {
+		if _, ok := sources[attr]; !ok {
+			continue
+		}
+		source := m.attributeSource(attr)
+		s += normalStyle.Render("  "+fmt.Sprintf(m.localizer.T("attributes.item"), m.attributeName(attr), m.attributeValue(attr, source.Item), source.Name)) + "\n"
+	}
+	return s + "\n"
+}
@@ -0,0 +1,334 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+type cleanupMsg struct {
+	items []merge.Item
+	err   error
+}
+
+// openCleanup cerca gli elementi senza documenti da proporre per l'eliminazione
+func (m ListModel) openCleanup() (tea.Model, tea.Cmd) {
+	m.cleanupFrom = m.mode
+	m.mode = "cleanup"
+	m.unused = nil
+	m.unusedLoading = true
+	m.unusedSelected = make(map[int]bool)
+	m.unusedCursor = 0
+	m.cleanupResult = nil
+	m.searchInput.Blur()
+
+	client := m.client
+	kind := m.entityType
+	return m, func() tea.Msg {
+		items, err := merge.FindUnused(context.Background(), client, kind)
+		return cleanupMsg{items: items, err: err}
+	}
+}
+
+// closeCleanup torna alla schermata da cui è stata aperta la pulizia
+func (m ListModel) closeCleanup() ListModel {
+	m.mode = m.cleanupFrom
+	m.unused = nil
+	m.unusedLoading = false
+	m.unusedSelected = nil
+	if m.mode == "manual" {
+		m.searchInput.Focus()
+	}
+	return m
+}
+
+// selectedUnused restituisce gli elementi scelti per l'eliminazione, nell'ordine dell'elenco
+func (m ListModel) selectedUnused() []merge.Item {
+	var items []merge.Item
+	for _, item := range m.unused {
+		if m.unusedSelected[item.ID] {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (m ListModel) updateCleanupMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		return m.closeCleanup(), nil
+	}
+
+	if m.unusedLoading {
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.unusedCursor > 0 {
+			m.unusedCursor--
+		}
+
+	case "down", "j":
+		if m.unusedCursor < len(m.unused)-1 {
+			m.unusedCursor++
+		}
+
+	case " ":
+		if len(m.unused) > 0 {
+			id := m.unused[m.unusedCursor].ID
+			m.unusedSelected[id] = !m.unusedSelected[id]
+		}
+
+	case "a":
+		// Seleziona tutti gli elementi, o nessuno se erano già tutti selezionati
+		all := len(m.selectedUnused()) == len(m.unused)
+		for _, item := range m.unused {
+			m.unusedSelected[item.ID] = !all
+		}
+
+	case "enter":
+		if len(m.selectedUnused()) > 0 {
+			m.mode = "cleanup_confirm"
+		}
+
+	case "d":
+		// Simula la pulizia con un client che non invia modifiche
+		if len(m.selectedUnused()) > 0 {
+			dryClient, err := newClient(m.config)
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			dryClient.DryRun = true
+			return m.startCleanup(dryClient)
+		}
+	}
+
+	return m, nil
+}
+
+func (m ListModel) updateCleanupConfirmMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "n":
+		m.mode = "cleanup"
+
+	case "y":
+		return m.startCleanup(m.client)
+	}
+
+	return m, nil
+}
+
+func (m ListModel) updateCleanupDoneMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "enter":
+		return m.reload()
+	}
+
+	return m, nil
+}
+
+// startCleanup elimina gli elementi scelti in una goroutine
+func (m ListModel) startCleanup(client *paperless.Client) (tea.Model, tea.Cmd) {
+	items := m.selectedUnused()
+	kind := m.entityType
+
+	m.merging = true
+	m.mergeStatus = m.localizer.T("merge.status_start")
+	m.mergeProgress = 0
+	m.mergeCurrent = 0
+	m.mergeTotal = 0
+	m.progressChan = make(chan tea.Msg, 10)
+	ctx := m.operationContext()
+
+	progressChan := m.progressChan
+	go func() {
+		// La pulizia viene registrata nel journal come i merge, per poterla annullare
+		var journal *merge.JournalStore
+		if !client.DryRun {
+			store, err := journalStore(m.config)
+			if err != nil {
+				progressChan <- mergeCompleteMsg{err: err}
+				close(progressChan)
+				return
+			}
+			journal = store
+		}
+
+		executor := newExecutor(m.config, client, journal, merge.ReporterFunc(func(p merge.Progress) {
+			progressChan <- mergeProgressMsg{
+				current: p.Current,
+				total:   p.Total,
+				status:  progressStatus(m.localizer, p),
+			}
+		}))
+
+		var msg tea.Msg
+		result, err := executor.Cleanup(ctx, kind, items)
+		switch {
+		case err != nil:
+			msg = mergeCompleteMsg{err: mergeError(m.localizer, kind, err)}
+		case client.DryRun:
+			msg = mergeCompleteMsg{dryRun: true, simulated: client.SkippedRequests()}
+		default:
+			msg = mergeCompleteMsg{cleanup: &result}
+		}
+		progressChan <- msg
+		close(progressChan)
+	}()
+
+	return m, waitForProgress(m.progressChan)
+}
+
+func (m ListModel) viewCleanup() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.title"), entityPlural(m.localizer, m.entityType))) + "\n\n"
+
+	if m.unusedLoading {
+		s += normalStyle.Render(m.localizer.T("cleanup.loading")) + "\n"
+		return s
+	}
+
+	if len(m.unused) == 0 {
+		s += normalStyle.Render(m.localizer.T("cleanup.none")) + "\n\n"
+		s += normalStyle.Render(m.localizer.T("cleanup.help_back")) + "\n"
+		return s
+	}
+
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.intro"), len(m.unused))) + "\n\n"
+
+	// Sottrai 10 righe per header, introduzione, help, ecc.
+	maxVisible := m.height - 10
+	if maxVisible < 5 {
+		maxVisible = 5
+	}
+	startIdx := 0
+	if len(m.unused) > maxVisible {
+		startIdx = m.unusedCursor - maxVisible/2
+		if startIdx < 0 {
+			startIdx = 0
+		}
+		if startIdx > len(m.unused)-maxVisible {
+			startIdx = len(m.unused) - maxVisible
+		}
+	}
+	endIdx := startIdx + maxVisible
+	if endIdx > len(m.unused) {
+		endIdx = len(m.unused)
+	}
+
+	if startIdx > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.manual_above"), startIdx)) + "\n"
+	}
+	for i := startIdx; i < endIdx; i++ {
+		item := m.unused[i]
+		checkbox := "[ ]"
+		if m.unusedSelected[item.ID] {
+			checkbox = "[✓]"
+		}
+		line := fmt.Sprintf("%s %s (#%d)", checkbox, item.Name, item.ID)
+		if i == m.unusedCursor {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += normalStyle.Render("  "+line) + "\n"
+		}
+	}
+	if endIdx < len(m.unused) {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.manual_below"), len(m.unused)-endIdx)) + "\n"
+	}
+
+	s += "\n" + normalStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.selected"), len(m.selectedUnused()))) + "\n"
+	if m.client.DryRun {
+		s += normalStyle.Render(m.localizer.T("cleanup.help_dry_run")) + "\n"
+	} else {
+		s += normalStyle.Render(m.localizer.T("cleanup.help")) + "\n"
+	}
+	return s
+}
+
+func (m ListModel) viewCleanupConfirm() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	items := m.selectedUnused()
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.title"), entityPlural(m.localizer, m.entityType))) + "\n\n"
+	s += errorStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.confirm"), len(items), entityPlural(m.localizer, m.entityType))) + "\n\n"
+
+	maxVisible := m.height - 10
+	if maxVisible < 5 {
+		maxVisible = 5
+	}
+	for i, item := range items {
+		if i >= maxVisible {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.manual_below"), len(items)-maxVisible)) + "\n"
+			break
+		}
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.item"), item.Name, item.ID)) + "\n"
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("cleanup.confirm_undo")) + "\n"
+	s += normalStyle.Render(m.localizer.T("cleanup.confirm_help")) + "\n"
+	return s
+}
+
+// viewCleanupDone mostra l'esito della pulizia appena completata
+func (m ListModel) viewCleanupDone() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	r := m.cleanupResult
+
+	var s string
+	s += selectedStyle.Render(m.localizer.T("cleanup.done_title")) + "\n\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.done_deleted"), len(r.Deleted), entityPlural(m.localizer, m.entityType))) + "\n"
+	if len(r.Kept) > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.done_kept"), len(r.Kept))) + "\n"
+		for _, item := range r.Kept {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("cleanup.item"), item.Name, item.ID)) + "\n"
+		}
+	}
+	if r.JournalID != "" {
+		s += "\n" + normalStyle.Render(m.localizer.T("cleanup.done_undo")) + "\n"
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("list.summary_help")) + "\n"
+	return s
+}
@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// nameConflict è una rinomina rifiutata dal server perché il nome è già usato da un altro elemento
+type nameConflict struct {
+	item       similarity.SimilarItem // Elemento che si stava rinominando
+	other      similarity.SimilarItem // Elemento che ha già il nome
+	name       string
+	survivorID int // Sopravvissuto del merge proposto
+}
+
+// nameConflictStep indica se la fase assegna un nome scelto dall'utente
+func nameConflictStep(step merge.Step) bool {
+	return step == merge.StepRename || step == merge.StepFinalName
+}
+
+// renameConflict restituisce la fase fallita se l'errore è una rinomina su un nome già usato
+func renameConflict(err error) *merge.StepError {
+	var stepErr *merge.StepError
+	if errors.As(err, &stepErr) && nameConflictStep(stepErr.Step) && paperless.IsConflict(stepErr.Err) {
+		return stepErr
+	}
+	return nil
+}
+
+// findConflict cerca tra gli elementi caricati quello che ha già il nome rifiutato.
+// Restituisce nil se non è visibile (es. appartiene a un altro utente): in quel caso
+// il merge non può essere proposto.
+func (m ListModel) findConflict(stepErr *merge.StepError) *nameConflict {
+	if stepErr == nil {
+		return nil
+	}
+
+	c := nameConflict{name: stepErr.Name}
+	for _, item := range m.allItems {
+		switch {
+		case item.ID == stepErr.ItemID:
+			c.item = item
+		case strings.EqualFold(strings.TrimSpace(item.Name), strings.TrimSpace(stepErr.Name)):
+			c.other = item
+		}
+	}
+	if c.item.ID == 0 || c.other.ID == 0 {
+		return nil
+	}
+
+	// Nella rinomina di massa resta l'elemento che ha già il nome; nel nome finale
+	// di un merge resta il sopravvissuto scelto, che ha già ricevuto i documenti
+	c.survivorID = c.other.ID
+	if stepErr.Step == merge.StepFinalName {
+		c.survivorID = c.item.ID
+	}
+	return &c
+}
+
+// openConflictMerge chiude l'errore e prepara il merge dei due elementi con lo stesso nome
+func (m ListModel) openConflictMerge() (tea.Model, tea.Cmd) {
+	c := m.conflict
+	m.err = nil
+	m.conflict = nil
+
+	// La rinomina interrotta non viene ripresa: le rinomine già fatte restano
+	if m.renamePlan != nil {
+		m = m.closeRename()
+	}
+
+	m.selectedMap = map[int]bool{c.item.ID: true, c.other.ID: true}
+	m.survivorID = c.survivorID
+	if m.mergeMode == ModeSemiAutomatic {
+		// Il merge proposto diventa il gruppo aperto, come quelli trovati per somiglianza
+		m.currentGroup = &similarity.SimilarityGroup{
+			Representative: c.name,
+			Items:          []similarity.SimilarItem{c.item, c.other},
+		}
+	}
+
+	m.mode = "merge"
+	m.mergeInput.SetValue(c.name)
+	return m, m.mergeInput.Focus()
+}
+
+// viewConflict propone il merge con l'elemento che ha già il nome rifiutato
+func (m ListModel) viewConflict() string {
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	c := m.conflict
+	var s string
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("conflict.found"), c.other.Name, c.other.ID)) + "\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("conflict.help"), c.item.Name, c.other.Name)) + "\n"
+	return s
+}
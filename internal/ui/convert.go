@@ -0,0 +1,295 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+type conversionPreviewMsg struct {
+	preview merge.ConversionPreview
+	err     error
+}
+
+// openConversion apre la conversione del tag indicato in corrispondente o tipo documento
+func (m ListModel) openConversion(tag similarity.SimilarItem) (tea.Model, tea.Cmd) {
+	m.mode = "convert"
+	m.conversion = &merge.Conversion{TagID: tag.ID, Target: merge.KindCorrespondents}
+	m.convPreview = nil
+	m.convResult = nil
+	m.searchInput.Blur()
+	m.mergeInput.SetValue(tag.Name)
+	return m, m.mergeInput.Focus()
+}
+
+// closeConversion torna all'elenco dei tag
+func (m ListModel) closeConversion() ListModel {
+	if m.mergeMode == ModeManual {
+		m.mode = "manual"
+	} else {
+		m.mode = "select"
+	}
+	m.conversion = nil
+	m.convPreview = nil
+	m.mergeInput.Blur()
+	return m
+}
+
+func (m ListModel) updateConvertMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		return m.closeConversion(), nil
+
+	case "tab":
+		// Alterna la destinazione tra corrispondente e tipo documento
+		if m.conversion.Target == merge.KindCorrespondents {
+			m.conversion.Target = merge.KindDocumentTypes
+		} else {
+			m.conversion.Target = merge.KindCorrespondents
+		}
+		return m, nil
+
+	case "enter":
+		m.conversion.Name = m.mergeInput.Value()
+		m.mergeInput.Blur()
+		m.mode = "convert_plan"
+		m.convPreview = nil
+
+		client := m.client
+		conv := *m.conversion
+		return m, func() tea.Msg {
+			preview, err := merge.PreviewConversion(context.Background(), client, conv)
+			return conversionPreviewMsg{preview: preview, err: err}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.mergeInput, cmd = m.mergeInput.Update(msg)
+	return m, cmd
+}
+
+func (m ListModel) updateConvertPlanMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		m.mode = "convert"
+		m.convPreview = nil
+		return m, m.mergeInput.Focus()
+
+	case "p":
+		// Passa alla politica dei conflitti successiva
+		for i, policy := range merge.ConflictPolicies {
+			if policy == m.conversion.Policy {
+				m.conversion.Policy = merge.ConflictPolicies[(i+1)%len(merge.ConflictPolicies)]
+				break
+			}
+		}
+
+	case "enter":
+		if m.convPreview != nil {
+			return m.startConversion(m.client)
+		}
+
+	case "d":
+		// Simula la conversione con un client che non invia modifiche
+		if m.convPreview != nil {
+			dryClient, err := newClient(m.config)
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			dryClient.DryRun = true
+			return m.startConversion(dryClient)
+		}
+	}
+
+	return m, nil
+}
+
+func (m ListModel) updateConvertDoneMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "enter":
+		return m.reload()
+	}
+
+	return m, nil
+}
+
+// startConversion avvia la conversione in una goroutine
+func (m ListModel) startConversion(client *paperless.Client) (tea.Model, tea.Cmd) {
+	conv := *m.conversion
+
+	m.merging = true
+	m.mergeStatus = m.localizer.T("merge.status_start")
+	m.mergeProgress = 0
+	m.mergeCurrent = 0
+	m.mergeTotal = 0
+	m.progressChan = make(chan tea.Msg, 10)
+	ctx := m.operationContext()
+
+	progressChan := m.progressChan
+	go func() {
+		executor := newExecutor(m.config, client, nil, merge.ReporterFunc(func(p merge.Progress) {
+			progressChan <- mergeProgressMsg{
+				current: p.Current,
+				total:   p.Total,
+				status:  progressStatus(m.localizer, p),
+			}
+		}))
+
+		var msg tea.Msg
+		result, err := executor.Convert(ctx, conv)
+		switch {
+		case err != nil:
+			msg = mergeCompleteMsg{err: mergeError(m.localizer, m.entityType, err)}
+		case client.DryRun:
+			msg = mergeCompleteMsg{dryRun: true, simulated: client.SkippedRequests()}
+		default:
+			msg = mergeCompleteMsg{conversion: &result}
+		}
+		progressChan <- msg
+		close(progressChan)
+	}()
+
+	return m, waitForProgress(m.progressChan)
+}
+
+// conflictPolicy restituisce la descrizione localizzata della politica dei conflitti
+func (m ListModel) conflictPolicy(policy merge.ConflictPolicy) string {
+	target := entitySingular(m.localizer, m.conversion.Target)
+	switch policy {
+	case merge.ConflictOverwrite:
+		return fmt.Sprintf(m.localizer.T("convert.policy_overwrite"), target)
+	case merge.ConflictKeep:
+		return fmt.Sprintf(m.localizer.T("convert.policy_keep"), target)
+	case merge.ConflictSkip:
+		return m.localizer.T("convert.policy_skip")
+	}
+	return ""
+}
+
+func (m ListModel) viewConvert() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	target := entitySingular(m.localizer, m.conversion.Target)
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("convert.title"), target)) + "\n\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.name_label"), target)) + "\n\n"
+	s += m.mergeInput.View() + "\n\n"
+	s += normalStyle.Render(m.localizer.T("convert.help")) + "\n"
+	return s
+}
+
+func (m ListModel) viewConvertPlan() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	target := entitySingular(m.localizer, m.conversion.Target)
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("convert.title"), target)) + "\n\n"
+
+	if m.convPreview == nil {
+		s += normalStyle.Render(m.localizer.T("list.plan_loading")) + "\n"
+		return s
+	}
+
+	p := m.convPreview
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.plan_tag"), p.Tag.Name, p.Tag.ID, p.Documents)) + "\n"
+	if p.Existing != nil {
+		s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("convert.plan_existing"), target, p.Existing.Name, p.Existing.ID)) + "\n"
+	} else {
+		s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("convert.plan_create"), target, p.Conversion.Name)) + "\n"
+	}
+	if p.Already > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.plan_already"), p.Already)) + "\n"
+	}
+	s += "\n"
+
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.plan_conflicts"), p.Conflicts, target)) + "\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.plan_policy"), m.conflictPolicy(m.conversion.Policy))) + "\n\n"
+
+	if len(p.References) > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.plan_references"), target, len(p.References))) + "\n"
+		for _, ref := range p.References {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.reference_item"), referenceSource(m.localizer, ref.Source), ref.Name, ref.ID)) + "\n"
+		}
+		s += "\n"
+	}
+
+	if m.client.DryRun {
+		s += normalStyle.Render(m.localizer.T("convert.plan_help_dry_run")) + "\n"
+	} else {
+		s += normalStyle.Render(m.localizer.T("convert.plan_help")) + "\n"
+	}
+	return s
+}
+
+// viewConvertDone mostra l'esito della conversione appena completata
+func (m ListModel) viewConvertDone() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	r := m.convResult
+	target := entitySingular(m.localizer, m.conversion.Target)
+
+	var s string
+	s += selectedStyle.Render(m.localizer.T("convert.done_title")) + "\n\n"
+	if r.Created {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.done_created"), target, m.conversion.Name, r.TargetID)) + "\n"
+	}
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.done_converted"), r.Converted)) + "\n"
+	if r.Kept > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.done_kept"), r.Kept)) + "\n"
+	}
+	if r.TagDeleted {
+		s += normalStyle.Render(m.localizer.T("convert.done_tag_deleted")) + "\n"
+	} else {
+		s += normalStyle.Render(m.localizer.T("convert.done_tag_kept")) + "\n"
+	}
+	if len(r.References) > 0 {
+		s += "\n" + normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.done_references"), target, len(r.References))) + "\n"
+		for _, ref := range r.References {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.reference_item"), referenceSource(m.localizer, ref.Source), ref.Name, ref.ID)) + "\n"
+		}
+	}
+	if len(r.Dropped) > 0 {
+		s += "\n" + normalStyle.Render(fmt.Sprintf(m.localizer.T("convert.done_references_dropped"), target, len(r.Dropped))) + "\n"
+		for _, ref := range r.Dropped {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.reference_item"), referenceSource(m.localizer, ref.Source), ref.Name, ref.ID)) + "\n"
+		}
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("list.summary_help")) + "\n"
+	return s
+}
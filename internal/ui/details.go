@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// itemDetails sono le informazioni di un elemento mostrate accanto al nome,
+// per decidere un merge sapendo quanto è usato e a chi appartiene
+type itemDetails struct {
+	documentCount      int
+	owner              *int
+	userCanChange      *bool // nil se il server non lo indica
+	matching           merge.MatchRule
+	isInboxTag         bool    // Solo tag
+	color              string  // Solo tag
+	textColor          string  // Solo tag
+	parent             *int    // Solo tag
+	children           int     // Solo tag
+	lastCorrespondence *string // Solo corrispondenti
+	path               string  // Solo percorsi di archiviazione
+	dataType           string  // Solo campi personalizzati
+}
+
+func tagDetails(tag paperless.Tag) itemDetails {
+	return itemDetails{
+		documentCount: tag.DocumentCount,
+		owner:         tag.Owner,
+		userCanChange: tag.UserCanChange,
+		matching:      merge.MatchRule{Match: tag.Match, MatchingAlgorithm: tag.MatchingAlgorithm, IsInsensitive: tag.IsInsensitive},
+		isInboxTag:    tag.IsInboxTag,
+		color:         tag.Color,
+		textColor:     tag.TextColor,
+		parent:        tag.Parent,
+		children:      len(tag.Children),
+	}
+}
+
+func correspondentDetails(corr paperless.Correspondent) itemDetails {
+	return itemDetails{
+		documentCount:      corr.DocumentCount,
+		owner:              corr.Owner,
+		userCanChange:      corr.UserCanChange,
+		matching:           merge.MatchRule{Match: corr.Match, MatchingAlgorithm: corr.MatchingAlgorithm, IsInsensitive: corr.IsInsensitive},
+		lastCorrespondence: corr.LastCorrespondence,
+	}
+}
+
+func documentTypeDetails(dt paperless.DocumentType) itemDetails {
+	return itemDetails{
+		documentCount: dt.DocumentCount,
+		owner:         dt.Owner,
+		userCanChange: dt.UserCanChange,
+		matching:      merge.MatchRule{Match: dt.Match, MatchingAlgorithm: dt.MatchingAlgorithm, IsInsensitive: dt.IsInsensitive},
+	}
+}
+
+func storagePathDetails(sp paperless.StoragePath) itemDetails {
+	return itemDetails{
+		documentCount: sp.DocumentCount,
+		owner:         sp.Owner,
+		userCanChange: sp.UserCanChange,
+		matching:      merge.MatchRule{Match: sp.Match, MatchingAlgorithm: sp.MatchingAlgorithm, IsInsensitive: sp.IsInsensitive},
+		path:          sp.Path,
+	}
+}
+
+func customFieldDetails(field paperless.CustomField) itemDetails {
+	return itemDetails{dataType: field.DataType}
+}
+
+// itemName restituisce il nome di un elemento caricato
+func (m ListModel) itemName(id int) (string, bool) {
+	for _, item := range m.allItems {
+		if item.ID == id {
+			return item.Name, true
+		}
+	}
+	return "", false
+}
+
+// viewDetails descrive l'elemento in una riga compatta da mostrare dopo il nome
+func (m ListModel) viewDetails(id int) string {
+	d, ok := m.details[id]
+	if !ok {
+		return ""
+	}
+
+	var parts []string
+	// I campi personalizzati non hanno il conteggio dei documenti
+	if m.entityType != EntityCustomFields {
+		parts = append(parts, fmt.Sprintf(m.localizer.T("details.documents"), d.documentCount))
+	}
+	if d.dataType != "" {
+		parts = append(parts, fmt.Sprintf(m.localizer.T("details.type"), d.dataType))
+	}
+	if d.path != "" {
+		parts = append(parts, fmt.Sprintf(m.localizer.T("details.path"), d.path))
+	}
+	if d.lastCorrespondence != nil && *d.lastCorrespondence != "" {
+		// Basta la data, senza ora
+		last := *d.lastCorrespondence
+		if len(last) > 10 {
+			last = last[:10]
+		}
+		parts = append(parts, fmt.Sprintf(m.localizer.T("details.last"), last))
+	}
+	if d.isInboxTag {
+		parts = append(parts, m.localizer.T("details.inbox"))
+	}
+	if d.parent != nil {
+		parent, ok := m.itemName(*d.parent)
+		if !ok {
+			parent = fmt.Sprintf("#%d", *d.parent)
+		}
+		parts = append(parts, fmt.Sprintf(m.localizer.T("details.parent"), parent))
+	}
+	if d.children > 0 {
+		parts = append(parts, fmt.Sprintf(m.localizer.T("details.children"), d.children))
+	}
+	if d.matching.MatchingAlgorithm != paperless.MatchNone && m.entityType != EntityCustomFields {
+		parts = append(parts, fmt.Sprintf(m.localizer.T("details.matching"), matchingRule(m.localizer, d.matching)))
+	}
+	if d.owner != nil {
+		parts = append(parts, fmt.Sprintf(m.localizer.T("details.owner"), *d.owner))
+	}
+	if d.userCanChange != nil && !*d.userCanChange {
+		parts = append(parts, m.localizer.T("details.read_only"))
+	}
+
+	return " · " + strings.Join(parts, " · ")
+}
+
+// viewColor mostra un tag con i suoi colori, come appare in Paperless (vuoto per gli altri elementi)
+func (m ListModel) viewColor(id int) string {
+	d := m.details[id]
+	if d.color == "" {
+		return ""
+	}
+
+	style := lipgloss.NewStyle().
+		Background(lipgloss.Color(d.color))
+	if d.textColor != "" {
+		style = style.Foreground(lipgloss.Color(d.textColor))
+	}
+	return " " + style.Render(" Aa ")
+}
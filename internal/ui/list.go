@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/meska/paperless-merger/internal/config"
 	"github.com/meska/paperless-merger/internal/locale"
+	"github.com/meska/paperless-merger/internal/merge"
 	"github.com/meska/paperless-merger/internal/paperless"
 	"github.com/meska/paperless-merger/internal/similarity"
 )
@@ -27,6 +30,7 @@ type ListModel struct {
 	cursor        int
 	groupCursor   int
 	selectedMap   map[int]bool // ID -> selezionato
+	survivorID    int          // Sopravvissuto scelto esplicitamente (0 per la scelta automatica)
 	loading       bool
 	merging       bool          // Stato durante il merge
 	mergeStatus   string        // Messaggio di stato del merge
@@ -34,11 +38,54 @@ type ListModel struct {
 	mergeTotal    int           // Numero totale operazioni
 	mergeCurrent  int           // Operazione corrente
 	progressChan  chan tea.Msg  // Canale per aggiornamenti progress
+	cancel        context.CancelFunc // Annulla l'operazione in corso (tasto Esc)
+	cancelling    bool               // Annullamento richiesto, in attesa che l'operazione si fermi
+	cancelled     bool               // L'ultima operazione è stata annullata (l'errore propone il ripristino)
+	plan          *merge.Plan    // Piano di merge in revisione (modalità "plan")
+	preview       *merge.Preview // Anteprima del piano con il conteggio documenti
+	matchCursor   int            // Regola di matching scelta (vedi matchingOptions)
+	matchChosen   bool           // true se l'utente ha scelto la regola di matching
+	matchNames    bool           // true per aggiungere i vecchi nomi come termini di matching
+	sources       map[merge.Attribute]int // Attributo -> ID dell'elemento da cui il sopravvissuto lo prende
+	dryRunLog     []string       // Richieste simulate dall'ultimo dry-run
+	summary       []merge.Reference // Riferimenti riscritti dall'ultimo merge (modalità "summary")
+	discrepancies []merge.Discrepancy // Differenze trovate dalla verifica dell'ultimo merge (modalità "summary")
+	selectField   *merge.SelectField // Campo select di cui unire le opzioni (modalità "options")
+	optCursor     int
+	optSelected   map[int]bool       // Indice opzione -> selezionata
+	optionMerge   *merge.OptionMerge // Unione di opzioni in esecuzione
+	conversion    *merge.Conversion        // Conversione di un tag in corso (modalità "convert")
+	convPreview   *merge.ConversionPreview // Anteprima della conversione
+	convResult    *merge.ConversionResult  // Esito dell'ultima conversione (modalità "convert_done")
+	splitSource   *merge.SplitSource       // Elemento da suddividere con i suoi documenti (modalità "split")
+	splitRules    []merge.SplitRule        // Regole della suddivisione, in ordine di priorità
+	splitCriterion merge.SplitCriterion    // Criterio della regola in inserimento (modalità "split_rule")
+	splitResult   *merge.SplitResult       // Esito dell'ultima suddivisione (modalità "split_done")
+	renameScope   []int                    // Elementi da rinominare (tutti se vuoto)
+	renameRules   []merge.RenameRule       // Regole della rinomina di massa, applicate in sequenza
+	renameOp      merge.RenameOp           // Operazione della regola in inserimento (modalità "rename_rule")
+	renamePlan    *merge.RenamePlan        // Piano di rinomina in revisione (modalità "rename_plan")
+	renameMerge   map[int]bool             // Indice collisione -> da risolvere con un merge
+	renameResult  *merge.RenameResult      // Esito dell'ultima rinomina (modalità "rename_done")
+	queue         map[int]merge.Plan       // Indice gruppo -> merge in coda
+	skipped       map[int]bool             // Indice gruppo -> saltato nella revisione
+	queueFrom     string                   // Modalità da cui è stato aperto il nome del merge in coda
+	queueResults  []merge.QueueResult      // Esito dell'ultima coda eseguita (modalità "queue_done")
+	showIgnored   bool                     // true per mostrare anche i gruppi da non proporre più
+	unused        []merge.Item             // Elementi senza documenti proposti per l'eliminazione (modalità "cleanup")
+	unusedLoading bool                     // true durante la ricerca degli elementi senza documenti
+	unusedSelected map[int]bool            // ID -> da eliminare
+	unusedCursor  int
+	cleanupFrom   string                   // Modalità da cui è stata aperta la pulizia
+	cleanupResult *merge.CleanupResult     // Esito dell'ultima pulizia (modalità "cleanup_done")
+	conflict      *nameConflict            // Nome già usato che ha fatto fallire una rinomina (merge proposto)
+	details       map[int]itemDetails      // ID -> informazioni mostrate accanto al nome
 	err           error
 	quitting      bool
-	mode          string // "browse", "select", "merge", "manual" (per modalità manuale)
+	mode          string // "browse", "select", "merge", "plan", "matching", "attributes", "convert", "convert_plan", "convert_done", "split", "split_rule", "split_done", "rename", "rename_rule", "rename_plan", "rename_done", "queue_name", "queue_done", "cleanup", "cleanup_confirm", "cleanup_done", "dryrun", "summary", "options", "option_name", "manual" (per modalità manuale)
 	mergeInput    textinput.Model
 	searchInput   textinput.Model // Per filtrare nella modalità manuale
+	targetInput   textinput.Model // Destinazione della regola di suddivisione
 	progress      progress.Model
 	currentGroup  *similarity.SimilarityGroup
 	width         int // Larghezza del terminale
@@ -48,11 +95,22 @@ type ListModel struct {
 type loadedMsg struct {
 	groups   []similarity.SimilarityGroup
 	allItems []similarity.SimilarItem
+	details  map[int]itemDetails
 	err      error
 }
 
 type mergeCompleteMsg struct {
-	err error
+	err        error
+	dryRun     bool              // true se il merge è stato solo simulato
+	simulated  []string          // Richieste non inviate durante il dry-run
+	references []merge.Reference // Viste salvate, workflow e regole mail riscritti
+	discrepancies []merge.Discrepancy // Differenze trovate dalla verifica del merge
+	conversion *merge.ConversionResult // Esito di una conversione completata
+	split      *merge.SplitResult      // Esito di una suddivisione completata
+	rename     *merge.RenameResult     // Esito di una rinomina di massa completata
+	queue      []merge.QueueResult     // Esito dei merge di una coda eseguita
+	cleanup    *merge.CleanupResult    // Esito di una pulizia completata
+	conflict   *merge.StepError        // Rinomina fallita perché il nome è già usato
 }
 
 type mergeProgressMsg struct {
@@ -68,9 +126,21 @@ func waitForProgress(progressChan chan tea.Msg) tea.Cmd {
 	}
 }
 
+// operationContext crea il contesto di un'operazione lunga, annullabile con Esc
+func (m *ListModel) operationContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.cancelling = false
+	return ctx
+}
+
 // NewListModel crea un nuovo modello lista
 func NewListModel(cfg *config.Config, loc *locale.Localizer, entityType EntityType, mergeMode MergeMode) ListModel {
-	client := paperless.NewClient(cfg.BaseURL, cfg.APIKey)
+	// Un client non valido viene segnalato come errore al posto dell'elenco
+	client, err := newClient(cfg)
+	if err == nil {
+		client.DryRun = cfg.DryRun
+	}
 	
 	input := textinput.New()
 	input.Placeholder = loc.T("list.merge_input_placeholder")
@@ -97,7 +167,10 @@ func NewListModel(cfg *config.Config, loc *locale.Localizer, entityType EntityTy
 		mergeMode:   mergeMode,
 		client:      client,
 		selectedMap: make(map[int]bool),
-		loading:     true,
+		queue:       make(map[int]merge.Plan),
+		skipped:     make(map[int]bool),
+		loading:     err == nil,
+		err:         err,
 		mode:        initialMode,
 		mergeInput:  input,
 		searchInput: searchInput,
@@ -108,6 +181,9 @@ func NewListModel(cfg *config.Config, loc *locale.Localizer, entityType EntityTy
 }
 
 func (m ListModel) Init() tea.Cmd {
+	if m.err != nil {
+		return nil
+	}
 	return tea.Batch(
 		m.loadData,
 	)
@@ -116,36 +192,62 @@ func (m ListModel) Init() tea.Cmd {
 func (m ListModel) loadData() tea.Msg {
 	var items []similarity.SimilarItem
 	var err error
+	details := make(map[int]itemDetails)
 
 	switch m.entityType {
 	case EntityTags:
-		tags, err := m.client.GetTags()
+		tags, err := m.client.GetTags(context.Background())
 		if err != nil {
 			return loadedMsg{err: err}
 		}
 		items = make([]similarity.SimilarItem, len(tags))
 		for i, tag := range tags {
 			items[i] = similarity.SimilarItem{ID: tag.ID, Name: tag.Name}
+			details[tag.ID] = tagDetails(tag)
 		}
 
 	case EntityCorrespondents:
-		correspondents, err := m.client.GetCorrespondents()
+		correspondents, err := m.client.GetCorrespondents(context.Background())
 		if err != nil {
 			return loadedMsg{err: err}
 		}
 		items = make([]similarity.SimilarItem, len(correspondents))
 		for i, corr := range correspondents {
 			items[i] = similarity.SimilarItem{ID: corr.ID, Name: corr.Name}
+			details[corr.ID] = correspondentDetails(corr)
 		}
 
 	case EntityDocumentTypes:
-		docTypes, err := m.client.GetDocumentTypes()
+		docTypes, err := m.client.GetDocumentTypes(context.Background())
 		if err != nil {
 			return loadedMsg{err: err}
 		}
 		items = make([]similarity.SimilarItem, len(docTypes))
 		for i, dt := range docTypes {
 			items[i] = similarity.SimilarItem{ID: dt.ID, Name: dt.Name}
+			details[dt.ID] = documentTypeDetails(dt)
+		}
+
+	case EntityStoragePaths:
+		storagePaths, err := m.client.GetStoragePaths(context.Background())
+		if err != nil {
+			return loadedMsg{err: err}
+		}
+		items = make([]similarity.SimilarItem, len(storagePaths))
+		for i, sp := range storagePaths {
+			items[i] = similarity.SimilarItem{ID: sp.ID, Name: sp.Name}
+			details[sp.ID] = storagePathDetails(sp)
+		}
+
+	case EntityCustomFields:
+		fields, err := m.client.GetCustomFields(context.Background())
+		if err != nil {
+			return loadedMsg{err: err}
+		}
+		items = make([]similarity.SimilarItem, len(fields))
+		for i, field := range fields {
+			items[i] = similarity.SimilarItem{ID: field.ID, Name: field.Name}
+			details[field.ID] = customFieldDetails(field)
 		}
 	}
 
@@ -162,7 +264,18 @@ func (m ListModel) loadData() tea.Msg {
 		groups = similarity.FindSimilarGroups(items, 0.7)
 	}
 
-	return loadedMsg{groups: groups, allItems: allItems}
+	// Esclude i gruppi da non proporre più
+	if !m.showIgnored {
+		var visible []similarity.SimilarityGroup
+		for _, group := range groups {
+			if !m.ignoredGroup(group) {
+				visible = append(visible, group)
+			}
+		}
+		groups = visible
+	}
+
+	return loadedMsg{groups: groups, allItems: allItems, details: details}
 }
 
 func (m ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -180,7 +293,12 @@ func (m ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.groups = msg.groups
 		m.allItems = msg.allItems
+		m.details = msg.details
 		m.filteredItems = msg.allItems // Inizialmente tutti visibili
+		if m.mergeMode == ModeSemiAutomatic && m.cursor >= len(m.groups) {
+			// Dopo un merge (o nascondendo gruppi) il cursore può uscire dalla lista
+			m.cursor = max(len(m.groups)-1, 0)
+		}
 		if m.mergeMode == ModeManual {
 			m.searchInput.Focus()
 		}
@@ -202,30 +320,212 @@ func (m ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.mergeProgress = 0
 		m.mergeCurrent = 0
 		m.mergeTotal = 0
+		if m.cancel != nil {
+			m.cancel()
+			m.cancel = nil
+		}
+		cancelled := m.cancelling
+		m.cancelling = false
 		if msg.err != nil {
 			m.err = msg.err
-			m.mode = "select" // Torna indietro in caso di errore
+			m.conflict = m.findConflict(msg.conflict)
+			// Conversioni, suddivisioni e unioni di opzioni non hanno un journal da riprendere
+			m.cancelled = cancelled && m.optionMerge == nil && m.conversion == nil && m.splitSource == nil
+			// Torna indietro in caso di errore
+			if m.optionMerge != nil {
+				m.optionMerge = nil
+				m.mode = "options"
+			} else if m.conversion != nil {
+				m.mode = "convert_plan"
+			} else if m.splitSource != nil {
+				m.mode = "split"
+			} else if m.renamePlan != nil {
+				m.mode = "rename_plan"
+			} else if m.mode == "cleanup" || m.mode == "cleanup_confirm" {
+				// La pulizia interrotta resta nel journal e può essere ripresa
+				m = m.closeCleanup()
+			} else if m.mode == "browse" {
+				// Coda dei merge: resta sulla lista dei gruppi
+			} else if m.mergeMode == ModeManual {
+				m.mode = "manual"
+			} else {
+				m.mode = "select"
+			}
+			return m, nil
+		}
+		if msg.dryRun {
+			// Nessuna modifica è stata inviata: mostra le richieste simulate
+			m.dryRunLog = msg.simulated
+			m.mode = "dryrun"
+			return m, nil
+		}
+		if msg.conversion != nil {
+			m.convResult = msg.conversion
+			m.mode = "convert_done"
+			return m, nil
+		}
+		if msg.split != nil {
+			m.splitResult = msg.split
+			m.mode = "split_done"
+			return m, nil
+		}
+		if msg.rename != nil {
+			m.renameResult = msg.rename
+			m.mode = "rename_done"
+			return m, nil
+		}
+		if msg.queue != nil {
+			m.queueResults = msg.queue
+			m.mode = "queue_done"
+			return m, nil
+		}
+		if msg.cleanup != nil {
+			m.cleanupResult = msg.cleanup
+			m.mode = "cleanup_done"
+			return m, nil
+		}
+		if len(msg.references) > 0 || len(msg.discrepancies) > 0 {
+			// Mostra i riferimenti riscritti e le differenze trovate prima di ricaricare
+			m.summary = msg.references
+			m.discrepancies = msg.discrepancies
+			m.mode = "summary"
 			return m, nil
 		}
 		// Merge completato con successo, ricarica i dati
-		if m.mergeMode == ModeManual {
-			m.mode = "manual"
-		} else {
-			m.mode = "browse"
+		return m.reload()
+
+	case optionsMsg:
+		if msg.err != nil {
+			m = m.closeOptions()
+			m.err = mergeError(m.localizer, m.entityType, msg.err)
+			return m, nil
 		}
-		m.selectedMap = make(map[int]bool)
-		m.currentGroup = nil
-		m.loading = true
-		return m, m.loadData
+		m.selectField = &msg.field
+		return m, nil
+
+	case conversionPreviewMsg:
+		if msg.err != nil {
+			m.err = mergeError(m.localizer, m.entityType, msg.err)
+			m.mode = "convert"
+			return m, m.mergeInput.Focus()
+		}
+		m.convPreview = &msg.preview
+		return m, nil
+
+	case cleanupMsg:
+		if msg.err != nil {
+			m = m.closeCleanup()
+			m.err = mergeError(m.localizer, m.entityType, msg.err)
+			return m, nil
+		}
+		m.unused = msg.items
+		m.unusedLoading = false
+		return m, nil
+
+	case splitMsg:
+		if msg.err != nil {
+			m = m.closeSplit()
+			m.err = mergeError(m.localizer, m.entityType, msg.err)
+			return m, nil
+		}
+		m.splitSource = &msg.source
+		return m, nil
+
+	case previewMsg:
+		if msg.err != nil {
+			m.err = mergeError(m.localizer, m.entityType, msg.err)
+			m.mode = "merge"
+			return m, m.mergeInput.Focus()
+		}
+		m.preview = &msg.preview
+		m.matchCursor = 0
+		m.matchChosen = false
+		m.matchNames = false
+		m.sources = make(map[merge.Attribute]int)
+		return m, nil
 
 	case tea.KeyMsg:
-		// Non processare input durante il merge
+		// Durante il merge l'unico tasto attivo è Esc, che lo annulla: l'operazione
+		// si ferma al primo punto sicuro e il journal registra fin dove è arrivata
+		// (conversioni, suddivisioni e unioni di opzioni ripristinano invece le modifiche)
 		if m.merging {
+			if msg.String() == "esc" && m.cancel != nil {
+				m.cancelling = true
+				m.cancel()
+			}
+			return m, nil
+		}
+
+		// Un errore visualizzato si chiude con Esc
+		if m.err != nil {
+			switch msg.String() {
+			case "ctrl+c":
+				m.quitting = true
+				return m, tea.Quit
+			case "esc":
+				m.err = nil
+				m.conflict = nil
+				m.cancelled = false
+			case "r":
+				// Riprendi o annulla subito il merge interrotto
+				if m.cancelled {
+					recovery := NewRecoveryModel(m.config)
+					return recovery, recovery.Init()
+				}
+			case "m":
+				if m.conflict != nil {
+					return m.openConflictMerge()
+				}
+			}
 			return m, nil
 		}
 		
 		if m.mode == "merge" {
 			return m.updateMergeMode(msg)
+		} else if m.mode == "plan" {
+			return m.updatePlanMode(msg)
+		} else if m.mode == "matching" {
+			return m.updateMatchingMode(msg)
+		} else if m.mode == "attributes" {
+			return m.updateAttributesMode(msg)
+		} else if m.mode == "convert" {
+			return m.updateConvertMode(msg)
+		} else if m.mode == "convert_plan" {
+			return m.updateConvertPlanMode(msg)
+		} else if m.mode == "convert_done" {
+			return m.updateConvertDoneMode(msg)
+		} else if m.mode == "split" {
+			return m.updateSplitMode(msg)
+		} else if m.mode == "split_rule" {
+			return m.updateSplitRuleMode(msg)
+		} else if m.mode == "split_done" {
+			return m.updateSplitDoneMode(msg)
+		} else if m.mode == "rename" {
+			return m.updateRenameMode(msg)
+		} else if m.mode == "rename_rule" {
+			return m.updateRenameRuleMode(msg)
+		} else if m.mode == "rename_plan" {
+			return m.updateRenamePlanMode(msg)
+		} else if m.mode == "rename_done" {
+			return m.updateRenameDoneMode(msg)
+		} else if m.mode == "queue_name" {
+			return m.updateQueueNameMode(msg)
+		} else if m.mode == "queue_done" {
+			return m.updateQueueDoneMode(msg)
+		} else if m.mode == "cleanup" {
+			return m.updateCleanupMode(msg)
+		} else if m.mode == "cleanup_confirm" {
+			return m.updateCleanupConfirmMode(msg)
+		} else if m.mode == "cleanup_done" {
+			return m.updateCleanupDoneMode(msg)
+		} else if m.mode == "dryrun" {
+			return m.updateDryRunMode(msg)
+		} else if m.mode == "summary" {
+			return m.updateSummaryMode(msg)
+		} else if m.mode == "options" {
+			return m.updateOptionsMode(msg)
+		} else if m.mode == "option_name" {
+			return m.updateOptionNameMode(msg)
 		} else if m.mode == "select" {
 			return m.updateSelectMode(msg)
 		} else if m.mode == "manual" {
@@ -237,7 +537,46 @@ func (m ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// reload azzera selezione e piano dopo un merge e ricarica gli elementi
+func (m ListModel) reload() (tea.Model, tea.Cmd) {
+	if m.mergeMode == ModeManual {
+		m.mode = "manual"
+	} else {
+		m.mode = "browse"
+	}
+	m.selectedMap = make(map[int]bool)
+	m.survivorID = 0
+	m.currentGroup = nil
+	m.plan = nil
+	m.preview = nil
+	m.summary = nil
+	m.discrepancies = nil
+	m.selectField = nil
+	m.optionMerge = nil
+	m.conversion = nil
+	m.convPreview = nil
+	m.convResult = nil
+	m.splitSource = nil
+	m.splitRules = nil
+	m.splitResult = nil
+	m.renameRules = nil
+	m.renamePlan = nil
+	m.renameResult = nil
+	m.queue = make(map[int]merge.Plan)
+	m.skipped = make(map[int]bool)
+	m.queueResults = nil
+	m.unused = nil
+	m.unusedSelected = nil
+	m.cleanupResult = nil
+	m.loading = true
+	return m, m.loadData
+}
+
 func (m ListModel) updateBrowseMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if model, cmd, ok := m.updateQueueKeys(msg.String()); ok {
+		return model, cmd
+	}
+
 	switch msg.String() {
 	case "ctrl+c":
 		m.quitting = true
@@ -258,6 +597,18 @@ func (m ListModel) updateBrowseMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.cursor++
 		}
 
+	case "r":
+		// Rinomina di massa di tutti gli elementi
+		if len(m.allItems) > 0 {
+			return m.openRename(nil)
+		}
+
+	case "u":
+		// Pulizia degli elementi senza documenti
+		if m.entityType != EntityCustomFields {
+			return m.openCleanup()
+		}
+
 	case "enter", " ":
 		if len(m.groups) > 0 {
 			m.mode = "select"
@@ -283,6 +634,7 @@ func (m ListModel) updateSelectMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Torna alla modalità browse
 		m.mode = "browse"
 		m.selectedMap = make(map[int]bool)
+		m.survivorID = 0
 		m.currentGroup = nil
 		return m, nil
 
@@ -299,7 +651,43 @@ func (m ListModel) updateSelectMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case " ":
 		if m.currentGroup != nil {
 			item := m.currentGroup.Items[m.groupCursor]
-			m.selectedMap[item.ID] = !m.selectedMap[item.ID]
+			m.toggleSelected(item.ID)
+		}
+
+	case "s":
+		// L'elemento sotto il cursore sopravvive al merge
+		if m.currentGroup != nil {
+			m.toggleSurvivor(m.currentGroup.Items[m.groupCursor].ID)
+		}
+
+	case "o":
+		// Unione delle opzioni del campo select sotto il cursore
+		if m.entityType == EntityCustomFields && m.currentGroup != nil {
+			return m.openOptions(m.currentGroup.Items[m.groupCursor].ID)
+		}
+
+	case "c":
+		// Conversione del tag sotto il cursore in corrispondente o tipo documento
+		if m.entityType == EntityTags && m.currentGroup != nil {
+			return m.openConversion(m.currentGroup.Items[m.groupCursor])
+		}
+
+	case "x":
+		// Suddivisione dell'elemento sotto il cursore
+		if m.entityType != EntityCustomFields && m.currentGroup != nil {
+			return m.openSplit(m.currentGroup.Items[m.groupCursor])
+		}
+
+	case "q":
+		// Mette in coda il merge degli elementi selezionati
+		if m.currentGroup != nil {
+			return m.openQueueName()
+		}
+
+	case "r":
+		// Rinomina di massa degli elementi selezionati (o di tutto il gruppo)
+		if m.currentGroup != nil {
+			return m.openRename(m.renameScopeIDs())
 		}
 
 	case "enter":
@@ -309,6 +697,9 @@ func (m ListModel) updateSelectMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.currentGroup != nil && m.groupCursor < len(m.currentGroup.Items) {
 			m.mergeInput.SetValue(m.currentGroup.Items[m.groupCursor].Name)
 		}
+		if name, ok := m.survivorName(); ok {
+			m.mergeInput.SetValue(name)
+		}
 		return m, m.mergeInput.Focus()
 	}
 
@@ -332,26 +723,23 @@ func (m ListModel) updateMergeMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case "enter":
-		// Esegui il merge
+		// Costruisci il piano e mostra l'anteprima prima di eseguire
+		if m.mergeMode == ModeSemiAutomatic && m.currentGroup == nil {
+			m.err = errors.New(m.localizer.T("merge.error_no_group"))
+			return m, nil
+		}
+
+		plan, err := merge.NewPlan(m.entityType, m.selectedItems(), m.survivorID, m.mergeInput.Value())
+		if err != nil {
+			m.err = mergeError(m.localizer, m.entityType, err)
+			return m, nil
+		}
+
 		m.mergeInput.Blur()
-		m.merging = true
-		m.mergeStatus = m.localizer.T("merge.status_start")
-		m.mergeProgress = 0
-		m.mergeCurrent = 0
-		m.mergeTotal = 0
-		
-		// Crea canale per progress
-		m.progressChan = make(chan tea.Msg, 10)
-		
-		// Avvia merge in goroutine
-		go func() {
-			result := m.executeMerge(m.progressChan)
-			m.progressChan <- result
-			close(m.progressChan)
-		}()
-		
-		// Inizia ad ascoltare il canale
-		return m, waitForProgress(m.progressChan)
+		m.plan = &plan
+		m.preview = nil
+		m.mode = "plan"
+		return m, m.loadPreview(plan)
 	}
 
 	// Aggiorna il textinput (ma l'Enter viene gestito sopra)
@@ -403,7 +791,43 @@ func (m ListModel) updateManualMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case " ":
 		if !m.searchInput.Focused() && len(m.filteredItems) > 0 {
 			item := m.filteredItems[m.cursor]
-			m.selectedMap[item.ID] = !m.selectedMap[item.ID]
+			m.toggleSelected(item.ID)
+		}
+
+	case "s":
+		// L'elemento sotto il cursore sopravvive al merge
+		if !m.searchInput.Focused() && len(m.filteredItems) > 0 {
+			m.toggleSurvivor(m.filteredItems[m.cursor].ID)
+		}
+
+	case "o":
+		// Unione delle opzioni del campo select sotto il cursore
+		if m.entityType == EntityCustomFields && !m.searchInput.Focused() && len(m.filteredItems) > 0 {
+			return m.openOptions(m.filteredItems[m.cursor].ID)
+		}
+
+	case "c":
+		// Conversione del tag sotto il cursore in corrispondente o tipo documento
+		if m.entityType == EntityTags && !m.searchInput.Focused() && len(m.filteredItems) > 0 {
+			return m.openConversion(m.filteredItems[m.cursor])
+		}
+
+	case "x":
+		// Suddivisione dell'elemento sotto il cursore
+		if m.entityType != EntityCustomFields && !m.searchInput.Focused() && len(m.filteredItems) > 0 {
+			return m.openSplit(m.filteredItems[m.cursor])
+		}
+
+	case "r":
+		// Rinomina di massa degli elementi selezionati (o di quelli filtrati)
+		if !m.searchInput.Focused() && len(m.allItems) > 0 {
+			return m.openRename(m.renameScopeIDs())
+		}
+
+	case "u":
+		// Pulizia degli elementi senza documenti
+		if m.entityType != EntityCustomFields && !m.searchInput.Focused() {
+			return m.openCleanup()
 		}
 
 	case "enter":
@@ -425,6 +849,9 @@ func (m ListModel) updateManualMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if m.cursor < len(m.filteredItems) {
 				m.mergeInput.SetValue(m.filteredItems[m.cursor].Name)
 			}
+			if name, ok := m.survivorName(); ok {
+				m.mergeInput.SetValue(name)
+			}
 			return m, m.mergeInput.Focus()
 		}
 	}
@@ -463,234 +890,86 @@ func (m ListModel) filterItems(query string) []similarity.SimilarItem {
 	return filtered
 }
 
-func (m ListModel) executeMerge(progressChan chan<- tea.Msg) tea.Msg {
-	finalName := m.mergeInput.Value()
-	if finalName == "" {
-		return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_empty_name"))}
-	}
-
-	// Raccogli gli ID selezionati (deduplicati)
-	selectedIDs := make([]int, 0)
-	seenIDs := make(map[int]bool)
-	
-	if m.mergeMode == ModeManual {
-		// In modalità manuale, usa allItems
-		for _, item := range m.allItems {
-			if m.selectedMap[item.ID] && !seenIDs[item.ID] {
-				selectedIDs = append(selectedIDs, item.ID)
-				seenIDs[item.ID] = true
-			}
-		}
-	} else {
-		// In modalità semi-automatica, usa currentGroup
-		if m.currentGroup == nil {
-			return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_no_group"))}
-		}
-		for _, item := range m.currentGroup.Items {
-			if m.selectedMap[item.ID] && !seenIDs[item.ID] {
-				selectedIDs = append(selectedIDs, item.ID)
-				seenIDs[item.ID] = true
-			}
-		}
-	}
-
-	if len(selectedIDs) < 2 {
-		return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_min_items"))}
-	}
-
-	// Trova se esiste già un elemento con il nome finale tra quelli selezionati
-	var mainID int
-	var toDeleteIDs []int
-	nameExists := false
-	
-	// Cerca in allItems per la modalità manuale
+// selectedItems restituisce gli elementi selezionati nel contesto corrente
+// (il gruppo aperto in modalità semi-automatica, tutti gli elementi in modalità manuale)
+func (m ListModel) selectedItems() []similarity.SimilarItem {
 	itemsToCheck := m.allItems
 	if m.mergeMode == ModeSemiAutomatic && m.currentGroup != nil {
 		itemsToCheck = m.currentGroup.Items
 	}
-	
+
+	var selected []similarity.SimilarItem
 	for _, item := range itemsToCheck {
-		if m.selectedMap[item.ID] && item.Name == finalName {
-			mainID = item.ID
-			nameExists = true
-			break
-		}
-	}
-	
-	// Se il nome esiste già, usalo come principale ed elimina tutti gli altri
-	if nameExists {
-		for _, id := range selectedIDs {
-			if id != mainID {
-				toDeleteIDs = append(toDeleteIDs, id)
-			}
+		if m.selectedMap[item.ID] {
+			selected = append(selected, item)
 		}
-	} else {
-		// Altrimenti il primo diventa il principale
-		mainID = selectedIDs[0]
-		toDeleteIDs = selectedIDs[1:]
 	}
+	return selected
+}
 
-	// Calcola il numero totale di operazioni granulari
-	// Per ogni elemento da eliminare: recupero docs + N aggiornamenti docs + eliminazione
-	// Stimiamo basandoci sul numero di elementi (useremo aggiornamenti in tempo reale)
-	var err error
-	currentOp := 0.0
-	totalOps := 0.0
-	
-	// Stima iniziale: 1 op per preparazione (se serve) + 3 ops per elemento (get, update, delete)
-	if !nameExists {
-		totalOps++ // preparazione nome temporaneo
-	}
-	totalOps += float64(len(toDeleteIDs)) * 3 // get + update docs + delete per elemento
-	if !nameExists {
-		totalOps++ // aggiornamento finale nome
+// toggleSelected seleziona o deseleziona un elemento; un sopravvissuto deselezionato
+// torna alla scelta automatica
+func (m *ListModel) toggleSelected(id int) {
+	m.selectedMap[id] = !m.selectedMap[id]
+	if !m.selectedMap[id] && m.survivorID == id {
+		m.survivorID = 0
 	}
+}
 
-	// Se il nome non esiste, aggiorna prima l'elemento principale con un nome temporaneo
-	needsRename := !nameExists
-	if needsRename {
-		currentOp++
-		progressChan <- mergeProgressMsg{
-			current: int(currentOp),
-			total:   int(totalOps),
-			status:  m.localizer.T("merge.status_prepare"),
-		}
+// toggleSurvivor sceglie (o toglie) l'elemento indicato come sopravvissuto, selezionandolo
+func (m *ListModel) toggleSurvivor(id int) {
+	if m.survivorID == id {
+		m.survivorID = 0
+		return
+	}
+	m.survivorID = id
+	m.selectedMap[id] = true
+}
 
-		tempName := fmt.Sprintf("__MERGING_%d_%s", mainID, finalName)
-		
-		switch m.entityType {
-		case EntityTags:
-			err = m.client.UpdateTag(mainID, tempName)
-		case EntityCorrespondents:
-			err = m.client.UpdateCorrespondent(mainID, tempName)
-		case EntityDocumentTypes:
-			err = m.client.UpdateDocumentType(mainID, tempName)
+// survivorName restituisce il nome del sopravvissuto scelto, se c'è
+func (m ListModel) survivorName() (string, bool) {
+	if m.survivorID == 0 {
+		return "", false
+	}
+	for _, item := range m.selectedItems() {
+		if item.ID == m.survivorID {
+			return item.Name, true
 		}
+	}
+	return "", false
+}
 
+func (m ListModel) executeMerge(ctx context.Context, progressChan chan<- tea.Msg, client *paperless.Client, plan merge.Plan) tea.Msg {
+	// I merge reali vengono registrati nel journal per poterli annullare
+	var journal *merge.JournalStore
+	if !client.DryRun {
+		store, err := journalStore(m.config)
 		if err != nil {
-			return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_temp_update"), err)}
+			return mergeCompleteMsg{err: err}
 		}
+		journal = store
 	}
 
-	// Per ogni elemento da eliminare, migra i documenti e poi eliminalo
-	for idx, oldID := range toDeleteIDs {
-		// Step 1: Recupero documenti
-		currentOp++
-		progressChan <- mergeProgressMsg{
-			current: int(currentOp),
-			total:   int(totalOps),
-			status:  fmt.Sprintf(m.localizer.T("merge.status_get_docs"), idx+1, len(toDeleteIDs)),
-		}
-
-		var docs []paperless.Document
-		
-		switch m.entityType {
-		case EntityTags:
-			docs, err = m.client.GetDocumentsByTag(oldID)
-			if err != nil {
-				return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_get_docs"), err)}
-			}
-
-		case EntityCorrespondents:
-			docs, err = m.client.GetDocumentsByCorrespondent(oldID)
-			if err != nil {
-				return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_get_docs"), err)}
-			}
-
-		case EntityDocumentTypes:
-			docs, err = m.client.GetDocumentsByType(oldID)
-			if err != nil {
-				return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_get_docs"), err)}
-			}
-		}
-
-		// Step 2: Aggiorna documenti
-		if len(docs) > 0 {
-			currentOp++
-			progressChan <- mergeProgressMsg{
-				current: int(currentOp),
-				total:   int(totalOps),
-				status:  fmt.Sprintf(m.localizer.T("merge.status_update_docs"), len(docs), idx+1, len(toDeleteIDs)),
-			}
-
-			switch m.entityType {
-			case EntityTags:
-				for _, doc := range docs {
-					if err := m.client.UpdateDocumentTags(doc.ID, oldID, mainID); err != nil {
-						return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_update_doc"), doc.ID, err)}
-					}
-				}
-
-			case EntityCorrespondents:
-				for _, doc := range docs {
-					if err := m.client.UpdateDocumentCorrespondent(doc.ID, mainID); err != nil {
-						return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_update_doc"), doc.ID, err)}
-					}
-				}
-
-			case EntityDocumentTypes:
-				for _, doc := range docs {
-					if err := m.client.UpdateDocumentTypeForDoc(doc.ID, mainID); err != nil {
-						return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_update_doc"), doc.ID, err)}
-					}
-				}
-			}
-		} else {
-			currentOp++ // Skip se non ci sono documenti
-		}
-
-		// Step 3: Elimina elemento vecchio
-		currentOp++
+	// Il motore di merge notifica l'avanzamento, la TUI lo inoltra sul canale
+	executor := newExecutor(m.config, client, journal, merge.ReporterFunc(func(p merge.Progress) {
 		progressChan <- mergeProgressMsg{
-			current: int(currentOp),
-			total:   int(totalOps),
-			status:  fmt.Sprintf(m.localizer.T("merge.status_delete"), idx+1, len(toDeleteIDs)),
+			current: p.Current,
+			total:   p.Total,
+			status:  progressStatus(m.localizer, p),
 		}
+	}))
 
-		switch m.entityType {
-		case EntityTags:
-			if err := m.client.DeleteTag(oldID); err != nil && !strings.Contains(err.Error(), "404") {
-				return mergeCompleteMsg{err: fmt.Errorf("errore nell'eliminazione tag %d: %w", oldID, err)}
-			}
-
-		case EntityCorrespondents:
-			if err := m.client.DeleteCorrespondent(oldID); err != nil && !strings.Contains(err.Error(), "404") {
-				return mergeCompleteMsg{err: fmt.Errorf("errore nell'eliminazione corrispondente %d: %w", oldID, err)}
-			}
-
-		case EntityDocumentTypes:
-			if err := m.client.DeleteDocumentType(oldID); err != nil && !strings.Contains(err.Error(), "404") {
-				return mergeCompleteMsg{err: fmt.Errorf("errore nell'eliminazione tipo documento %d: %w", oldID, err)}
-			}
-		}
+	result, err := executor.Execute(ctx, plan)
+	if err != nil {
+		return mergeCompleteMsg{err: mergeError(m.localizer, m.entityType, err), conflict: renameConflict(err)}
 	}
 
-	// Se necessario, aggiorna il nome dell'elemento principale al nome finale
-	// (ora non ci sono più conflitti perché tutti gli altri elementi sono stati eliminati)
-	if needsRename {
-		currentOp++
-		progressChan <- mergeProgressMsg{
-			current: int(currentOp),
-			total:   int(totalOps),
-			status:  m.localizer.T("merge.status_final_name"),
-		}
-
-		switch m.entityType {
-		case EntityTags:
-			err = m.client.UpdateTag(mainID, finalName)
-		case EntityCorrespondents:
-			err = m.client.UpdateCorrespondent(mainID, finalName)
-		case EntityDocumentTypes:
-			err = m.client.UpdateDocumentType(mainID, finalName)
-		}
-
-		if err != nil {
-			return mergeCompleteMsg{err: fmt.Errorf(m.localizer.T("merge.error_final_update"), err)}
-		}
+	if client.DryRun {
+		return mergeCompleteMsg{dryRun: true, simulated: client.SkippedRequests()}
 	}
 
-	// Merge completato con successo
-	return mergeCompleteMsg{err: nil}
+	// Merge completato: la verifica può aver trovato differenze
+	return mergeCompleteMsg{references: result.References, discrepancies: result.Discrepancies}
 }
 
 func (m ListModel) View() string {
@@ -710,17 +989,14 @@ func (m ListModel) View() string {
 		Foreground(lipgloss.Color("196")).
 		Bold(true)
 
-	var entityName string
-	switch m.entityType {
-	case EntityTags:
-		entityName = m.localizer.T("entity.tags")
-	case EntityCorrespondents:
-		entityName = m.localizer.T("entity.correspondents")
-	case EntityDocumentTypes:
-		entityName = m.localizer.T("entity.doctypes")
+	title := fmt.Sprintf(m.localizer.T("list.title"), entityPlural(m.localizer, m.entityType))
+	if m.client.DryRun {
+		title += " " + m.localizer.T("list.dry_run_badge")
 	}
-
-	s := titleStyle.Render(fmt.Sprintf(m.localizer.T("list.title"), entityName)) + "\n\n"
+	if m.config.Demo {
+		title += " " + m.localizer.T("main.demo_badge")
+	}
+	s := titleStyle.Render(title) + "\n\n"
 
 	if m.loading {
 		s += normalStyle.Render(m.localizer.T("list.loading")) + "\n"
@@ -736,32 +1012,123 @@ func (m ListModel) View() string {
 		if m.mergeTotal > 0 {
 			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("merge.progress_operation"), m.mergeCurrent, m.mergeTotal)) + "\n"
 		}
+		if m.cancelling {
+			s += "\n" + normalStyle.Render(m.localizer.T("list.cancelling")) + "\n"
+		} else if m.cancel != nil {
+			s += "\n" + normalStyle.Render(m.localizer.T("list.cancel_help")) + "\n"
+		}
 		return s
 	}
 
 	if m.err != nil {
 		s += errorStyle.Render(fmt.Sprintf(m.localizer.T("list.error"), m.err)) + "\n\n"
+		if m.conflict != nil {
+			s += m.viewConflict()
+		}
+		if m.cancelled {
+			s += normalStyle.Render(m.localizer.T("list.cancelled_help")) + "\n"
+		}
 		s += normalStyle.Render(m.localizer.T("list.error_back")) + "\n"
 		return s
 	}
 
+	if m.mode == "plan" {
+		return s + m.viewPlan()
+	}
+
+	if m.mode == "matching" {
+		return s + m.viewMatching()
+	}
+
+	if m.mode == "attributes" {
+		return s + m.viewAttributes()
+	}
+
+	if m.mode == "convert" {
+		return s + m.viewConvert()
+	}
+
+	if m.mode == "convert_plan" {
+		return s + m.viewConvertPlan()
+	}
+
+	if m.mode == "convert_done" {
+		return s + m.viewConvertDone()
+	}
+
+	if m.mode == "split" {
+		return s + m.viewSplit()
+	}
+
+	if m.mode == "split_rule" {
+		return s + m.viewSplitRule()
+	}
+
+	if m.mode == "split_done" {
+		return s + m.viewSplitDone()
+	}
+
+	if m.mode == "rename" {
+		return s + m.viewRename()
+	}
+
+	if m.mode == "rename_rule" {
+		return s + m.viewRenameRule()
+	}
+
+	if m.mode == "rename_plan" {
+		return s + m.viewRenamePlan()
+	}
+
+	if m.mode == "rename_done" {
+		return s + m.viewRenameDone()
+	}
+
+	if m.mode == "queue_name" {
+		return s + m.viewQueueName()
+	}
+
+	if m.mode == "queue_done" {
+		return s + m.viewQueueDone()
+	}
+
+	if m.mode == "cleanup" {
+		return s + m.viewCleanup()
+	}
+
+	if m.mode == "cleanup_confirm" {
+		return s + m.viewCleanupConfirm()
+	}
+
+	if m.mode == "cleanup_done" {
+		return s + m.viewCleanupDone()
+	}
+
+	if m.mode == "dryrun" {
+		return s + m.viewDryRun()
+	}
+
+	if m.mode == "summary" {
+		return s + m.viewSummary()
+	}
+
+	if m.mode == "options" || m.mode == "option_name" {
+		return s + m.viewOptions()
+	}
+
 	if m.mode == "merge" {
 		s += normalStyle.Render(m.localizer.T("list.merge_input_label")) + "\n\n"
 		s += m.mergeInput.View() + "\n\n"
 		
 		var selected []string
-		itemsToCheck := m.allItems
-		if m.mergeMode == ModeSemiAutomatic && m.currentGroup != nil {
-			itemsToCheck = m.currentGroup.Items
-		}
-		
-		for _, item := range itemsToCheck {
-			if m.selectedMap[item.ID] {
-				selected = append(selected, item.Name)
-			}
+		for _, item := range m.selectedItems() {
+			selected = append(selected, item.Name)
 		}
 		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.merge_items_to_merge"), len(selected))) + "\n"
 		s += normalStyle.Render(strings.Join(selected, " → ")) + "\n\n"
+		if name, ok := m.survivorName(); ok {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.merge_survivor"), name, m.survivorID)) + "\n\n"
+		}
 		s += normalStyle.Render(m.localizer.T("list.merge_help")) + "\n"
 		return s
 	}
@@ -827,13 +1194,19 @@ func (m ListModel) View() string {
 					checkbox = "[✓]"
 				}
 				
-				line := fmt.Sprintf("%s %s %s", cursor, checkbox, item.Name)
+				name := item.Name
+				if item.ID == m.survivorID {
+					name += m.localizer.T("list.survivor_mark")
+				}
+				name += m.viewDetails(item.ID)
+
+				line := fmt.Sprintf("%s %s %s", cursor, checkbox, name)
 				
 				if i == m.cursor {
 					cursor = ">"
-					s += selectedStyle.Render(cursor + " " + checkbox + " " + item.Name) + "\n"
+					s += selectedStyle.Render(cursor + " " + checkbox + " " + name) + m.viewColor(item.ID) + "\n"
 				} else {
-					s += normalStyle.Render(line) + "\n"
+					s += normalStyle.Render(line) + m.viewColor(item.ID) + "\n"
 				}
 			}
 			
@@ -848,6 +1221,19 @@ func (m ListModel) View() string {
 		} else {
 			s += normalStyle.Render(m.localizer.T("list.manual_help")) + "\n"
 		}
+		if m.entityType == EntityCustomFields {
+			s += normalStyle.Render(m.localizer.T("list.options_help")) + "\n"
+		}
+		if m.entityType == EntityTags {
+			s += normalStyle.Render(m.localizer.T("list.convert_help")) + "\n"
+		}
+		if m.entityType != EntityCustomFields {
+			s += normalStyle.Render(m.localizer.T("list.split_help")) + "\n"
+		}
+		s += normalStyle.Render(m.localizer.T("list.rename_help")) + "\n"
+		if m.entityType != EntityCustomFields {
+			s += normalStyle.Render(m.localizer.T("list.cleanup_help")) + "\n"
+		}
 		return s
 	}
 
@@ -863,17 +1249,33 @@ func (m ListModel) View() string {
 				checkbox = "[✓]"
 			}
 			
-			line := fmt.Sprintf("%s %s %s", cursor, checkbox, item.Name)
+			name := item.Name
+			if item.ID == m.survivorID {
+				name += m.localizer.T("list.survivor_mark")
+			}
+			name += m.viewDetails(item.ID)
+
+			line := fmt.Sprintf("%s %s %s", cursor, checkbox, name)
 			
 			if i == m.groupCursor {
 				cursor = ">"
-				s += selectedStyle.Render(cursor + " " + checkbox + " " + item.Name) + "\n"
+				s += selectedStyle.Render(cursor + " " + checkbox + " " + name) + m.viewColor(item.ID) + "\n"
 			} else {
-				s += normalStyle.Render(line) + "\n"
+				s += normalStyle.Render(line) + m.viewColor(item.ID) + "\n"
 			}
 		}
 
 		s += "\n" + normalStyle.Render(m.localizer.T("list.select_help")) + "\n"
+		if m.entityType == EntityCustomFields {
+			s += normalStyle.Render(m.localizer.T("list.options_help")) + "\n"
+		}
+		if m.entityType == EntityTags {
+			s += normalStyle.Render(m.localizer.T("list.convert_help")) + "\n"
+		}
+		if m.entityType != EntityCustomFields {
+			s += normalStyle.Render(m.localizer.T("list.split_help")) + "\n"
+		}
+		s += normalStyle.Render(m.localizer.T("list.rename_help")) + "\n"
 		return s
 	}
 
@@ -881,14 +1283,19 @@ func (m ListModel) View() string {
 	if len(m.groups) == 0 {
 		s += normalStyle.Render(m.localizer.T("list.browse_no_duplicates")) + "\n\n"
 		s += normalStyle.Render(m.localizer.T("list.browse_back")) + "\n"
+		s += normalStyle.Render(m.localizer.T("queue.help_ignored")) + "\n"
+		if m.entityType != EntityCustomFields {
+			s += normalStyle.Render(m.localizer.T("list.cleanup_help")) + "\n"
+		}
 		return s
 	}
 
-	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.browse_found"), len(m.groups))) + "\n\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.browse_found"), len(m.groups))) + "\n"
+	s += m.viewQueueCounts() + "\n"
 
 	// Calcola dinamicamente il numero di gruppi visibili in base all'altezza del terminale
-	// Sottrai 8 righe per header, help, ecc.
-	maxVisible := m.height - 8
+	// Sottrai 8 righe per header, help, ecc. e quelle degli elementi del gruppo sotto il cursore
+	maxVisible := m.height - 8 - len(m.groups[m.cursor].Items) - 1
 	if maxVisible < 5 {
 		maxVisible = 5 // Minimo 5 gruppi visibili
 	}
@@ -928,6 +1335,9 @@ func (m ListModel) View() string {
 		group := m.groups[i]
 		cursor := " "
 		line := fmt.Sprintf("%s [%d] %s", cursor, len(group.Items), group.Representative)
+		if mark := m.groupMark(i); mark != "" {
+			line += " " + mark
+		}
 		
 		if i == m.cursor {
 			cursor = ">"
@@ -941,7 +1351,18 @@ func (m ListModel) View() string {
 		s += normalStyle.Render(fmt.Sprintf("... (%d gruppi sotto) ...", len(m.groups)-endIdx)) + "\n"
 	}
 
+	// Elementi del gruppo sotto il cursore, con le informazioni per decidere il merge
+	s += "\n"
+	for _, item := range m.groups[m.cursor].Items {
+		s += normalStyle.Render("    "+item.Name+m.viewDetails(item.ID)) + m.viewColor(item.ID) + "\n"
+	}
+
 	s += "\n" + normalStyle.Render(m.localizer.T("list.browse_help")) + "\n"
+	s += normalStyle.Render(m.localizer.T("queue.help")) + "\n"
+	s += normalStyle.Render(m.localizer.T("queue.help_ignored")) + "\n"
+	if m.entityType != EntityCustomFields {
+		s += normalStyle.Render(m.localizer.T("list.cleanup_help")) + "\n"
+	}
 
 	return s
 }
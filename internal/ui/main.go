@@ -7,15 +7,19 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/meska/paperless-merger/internal/config"
 	"github.com/meska/paperless-merger/internal/locale"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
 )
 
 // EntityType rappresenta il tipo di entità da gestire
-type EntityType int
+type EntityType = merge.Kind
 
 const (
-	EntityTags EntityType = iota
-	EntityCorrespondents
-	EntityDocumentTypes
+	EntityTags           = merge.KindTags
+	EntityCorrespondents = merge.KindCorrespondents
+	EntityDocumentTypes  = merge.KindDocumentTypes
+	EntityStoragePaths   = merge.KindStoragePaths
+	EntityCustomFields   = merge.KindCustomFields
 )
 
 // MergeMode rappresenta la modalità di merge
@@ -26,6 +30,44 @@ const (
 	ModeManual
 )
 
+// newClient crea il client Paperless-ngx con le impostazioni della configurazione.
+// Il dry-run va impostato dal chiamante: le simulazioni usano un client a parte.
+func newClient(cfg *config.Config) (*paperless.Client, error) {
+	client := paperless.NewClient(cfg.BaseURL, cfg.APIKey)
+	if timeout := cfg.Timeout(); timeout > 0 {
+		client.SetTimeout(timeout)
+	}
+	if cfg.MaxRetries != nil {
+		client.SetRetries(*cfg.MaxRetries)
+	}
+	if delay := cfg.RetryDelay(); delay > 0 {
+		client.SetMaxRetryDelay(delay)
+	}
+	if cfg.RequestsPerSecond > 0 || cfg.MaxConcurrency > 0 {
+		client.SetRateLimit(cfg.RequestsPerSecond, cfg.MaxConcurrency)
+	}
+	if cfg.HasProxyAuth() {
+		err := client.SetProxyAuth(paperless.ProxyAuth{
+			Headers:       cfg.ExtraHeaders,
+			BasicUser:     cfg.BasicAuthUser,
+			BasicPassword: cfg.BasicAuthPassword,
+			TokenHeader:   cfg.TokenHeader,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// newExecutor crea l'executor dei merge con il numero di aggiornamenti contemporanei
+// della configurazione
+func newExecutor(cfg *config.Config, client *paperless.Client, journal *merge.JournalStore, reporter merge.Reporter) *merge.Executor {
+	executor := merge.NewExecutor(client, journal, reporter)
+	executor.SetWorkers(cfg.DocumentWorkers)
+	return executor
+}
+
 // MainModel rappresenta il modello principale dell'applicazione
 type MainModel struct {
 	config       *config.Config
@@ -57,6 +99,8 @@ func NewMainModel(cfg *config.Config) MainModel {
 			loc.T("main.entity_tags"),
 			loc.T("main.entity_correspondents"),
 			loc.T("main.entity_doctypes"),
+			loc.T("main.entity_storage_paths"),
+			loc.T("main.entity_custom_fields"),
 		},
 		showModeMenu: true,
 	}
@@ -91,6 +135,13 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.quitting = true
 			return m, tea.Quit
 
+		case "u", "U":
+			// Annulla l'ultimo merge registrato nel journal
+			if m.showModeMenu {
+				undoModel := NewUndoModel(m.config, m.localizer)
+				return undoModel, undoModel.Init()
+			}
+
 		case "s", "S":
 			// Apri settings (torna al setup)
 			if m.showModeMenu {
@@ -158,7 +209,11 @@ func (m MainModel) View() string {
 	normalStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("241"))
 
-	s := titleStyle.Render(m.localizer.T("main.title")) + "\n\n"
+	title := m.localizer.T("main.title")
+	if m.config.Demo {
+		title += " " + m.localizer.T("main.demo_badge")
+	}
+	s := titleStyle.Render(title) + "\n\n"
 
 	if m.showModeMenu {
 		// Menu principale
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+)
+
+// matchingConflict indica se gli elementi del piano usano algoritmi di matching diversi,
+// o lo stesso algoritmo con testi che non si possono unire, e l'utente non ha ancora
+// scelto quale regola mantenere
+func (m ListModel) matchingConflict() bool {
+	if m.preview == nil || !merge.HasMatching(m.entityType) || m.matchChosen {
+		return false
+	}
+	return len(m.preview.MatchingChoices()) > 1
+}
+
+// matchingOptions restituisce le regole tra cui scegliere: prima le regole unite,
+// poi la regola di ciascun elemento così com'è (a partire dal sopravvissuto)
+func (m ListModel) matchingOptions() []merge.MatchRule {
+	options := m.preview.MatchingChoices()
+	for _, item := range m.preview.Items() {
+		options = append(options, item.Item.MatchRule())
+	}
+	return options
+}
+
+// baseMatching restituisce la regola scelta, prima di aggiungere i vecchi nomi
+func (m ListModel) baseMatching() merge.MatchRule {
+	return m.matchingOptions()[m.matchCursor]
+}
+
+// chosenMatching restituisce la regola da assegnare al sopravvissuto,
+// o nil se coincide con quella che ha già
+func (m ListModel) chosenMatching() *merge.MatchRule {
+	if m.preview == nil || !merge.HasMatching(m.entityType) {
+		return nil
+	}
+
+	rule := m.baseMatching()
+	if m.matchNames {
+		rule = rule.WithNames(m.preview.OldNames())
+	}
+	if rule == m.preview.Survivor.Item.MatchRule() {
+		return nil
+	}
+	return &rule
+}
+
+// openMatching apre la scelta della regola di matching
+func (m ListModel) openMatching() ListModel {
+	m.mode = "matching"
+	m.optCursor = m.matchCursor
+	return m
+}
+
+func (m ListModel) updateMatchingMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		m.mode = "plan"
+
+	case "up", "k":
+		if m.optCursor > 0 {
+			m.optCursor--
+		}
+
+	case "down", "j":
+		if m.optCursor < len(m.matchingOptions())-1 {
+			m.optCursor++
+		}
+
+	case "enter":
+		m.matchCursor = m.optCursor
+		m.matchChosen = true
+		m.mode = "plan"
+	}
+
+	return m, nil
+}
+
+func (m ListModel) viewMatching() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	s += selectedStyle.Render(m.localizer.T("matching.title")) + "\n\n"
+
+	var lines []string
+	for _, rule := range m.preview.MatchingChoices() {
+		lines = append(lines, matchingRule(m.localizer, rule))
+	}
+	lines = append(lines, fmt.Sprintf(m.localizer.T("matching.keep"), matchingRule(m.localizer, m.preview.Survivor.Item.MatchRule())))
+	for _, item := range m.preview.Absorbed {
+		lines = append(lines, fmt.Sprintf(m.localizer.T("matching.item"), item.Name, matchingRule(m.localizer, item.Item.MatchRule())))
+	}
+
+	for i, line := range lines {
+		if i == m.optCursor {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += normalStyle.Render("  "+line) + "\n"
+		}
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("matching.help")) + "\n"
+	return s
+}
+
+// viewPlanMatching mostra nel piano la regola di matching che avrà il sopravvissuto
+func (m ListModel) viewPlanMatching() string {
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	warningStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214"))
+
+	var s string
+	if m.matchingConflict() {
+		key := "list.plan_matching_conflict"
+		if merge.SameAlgorithm(m.preview.MatchingChoices()) {
+			key = "list.plan_matching_text_conflict"
+		}
+		s += warningStyle.Render(m.localizer.T(key)) + "\n"
+	}
+
+	if rule := m.chosenMatching(); rule != nil {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_matching"), matchingRule(m.localizer, *rule))) + "\n"
+	} else {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_matching_unchanged"), matchingRule(m.localizer, m.preview.Survivor.Item.MatchRule()))) + "\n"
+	}
+
+	switch {
+	case !m.baseMatching().AcceptsNames():
+		s += normalStyle.Render(m.localizer.T("list.plan_matching_names_unsupported")) + "\n"
+	case m.matchNames:
+		s += normalStyle.Render(m.localizer.T("list.plan_matching_names_on")) + "\n"
+	default:
+		s += normalStyle.Render(m.localizer.T("list.plan_matching_names_off")) + "\n"
+	}
+
+	return s + "\n"
+}
@@ -0,0 +1,306 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/meska/paperless-merger/internal/locale"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// entityPlural restituisce il nome localizzato al plurale del tipo di entità
+func entityPlural(loc *locale.Localizer, entityType EntityType) string {
+	switch entityType {
+	case EntityTags:
+		return loc.T("entity.tags")
+	case EntityCorrespondents:
+		return loc.T("entity.correspondents")
+	case EntityDocumentTypes:
+		return loc.T("entity.doctypes")
+	case EntityStoragePaths:
+		return loc.T("entity.storage_paths")
+	case EntityCustomFields:
+		return loc.T("entity.custom_fields")
+	}
+	return ""
+}
+
+// entitySingular restituisce il nome localizzato al singolare del tipo di entità
+func entitySingular(loc *locale.Localizer, entityType EntityType) string {
+	switch entityType {
+	case EntityTags:
+		return loc.T("entity.tag")
+	case EntityCorrespondents:
+		return loc.T("entity.correspondent")
+	case EntityDocumentTypes:
+		return loc.T("entity.doctype")
+	case EntityStoragePaths:
+		return loc.T("entity.storage_path")
+	case EntityCustomFields:
+		return loc.T("entity.custom_field")
+	}
+	return ""
+}
+
+// referenceSource restituisce il nome localizzato del tipo di oggetto che fa riferimento a un elemento
+func referenceSource(loc *locale.Localizer, source merge.RefSource) string {
+	switch source {
+	case merge.RefSavedView:
+		return loc.T("reference.saved_view")
+	case merge.RefWorkflow:
+		return loc.T("reference.workflow")
+	case merge.RefMailRule:
+		return loc.T("reference.mail_rule")
+	}
+	return ""
+}
+
+// matchingRule descrive una regola di matching in forma leggibile
+func matchingRule(loc *locale.Localizer, rule merge.MatchRule) string {
+	var algorithm string
+	switch rule.MatchingAlgorithm {
+	case paperless.MatchNone:
+		return loc.T("matching.none")
+	case paperless.MatchAny:
+		algorithm = loc.T("matching.any")
+	case paperless.MatchAll:
+		algorithm = loc.T("matching.all")
+	case paperless.MatchLiteral:
+		algorithm = loc.T("matching.literal")
+	case paperless.MatchRegex:
+		algorithm = loc.T("matching.regex")
+	case paperless.MatchFuzzy:
+		algorithm = loc.T("matching.fuzzy")
+	case paperless.MatchAuto:
+		return loc.T("matching.auto")
+	}
+
+	s := fmt.Sprintf(loc.T("matching.rule"), algorithm, rule.Match)
+	if rule.IsInsensitive {
+		s += loc.T("matching.insensitive")
+	}
+	return s
+}
+
+// progressStatus traduce l'avanzamento del merge in un messaggio localizzato
+func progressStatus(loc *locale.Localizer, p merge.Progress) string {
+	switch p.Step {
+	case merge.StepPrepare:
+		return loc.T("merge.status_prepare")
+	case merge.StepGetDocuments:
+		return fmt.Sprintf(loc.T("merge.status_get_docs"), p.Item, p.Items)
+	case merge.StepUpdateDocuments:
+		return fmt.Sprintf(loc.T("merge.status_update_docs"), p.Document, p.Documents, p.Item, p.Items)
+	case merge.StepDelete:
+		return fmt.Sprintf(loc.T("merge.status_delete"), p.Item, p.Items)
+	case merge.StepFinalName:
+		return loc.T("merge.status_final_name")
+	case merge.StepRestoreSurvivor:
+		return loc.T("undo.status_restore_survivor")
+	case merge.StepRecreate:
+		return fmt.Sprintf(loc.T("undo.status_recreate"), p.Item, p.Items)
+	case merge.StepRestoreDocuments:
+		return fmt.Sprintf(loc.T("undo.status_restore_docs"), p.Document, p.Documents, p.Item, p.Items)
+	case merge.StepReferences:
+		return loc.T("merge.status_references")
+	case merge.StepRestoreReferences:
+		return loc.T("undo.status_restore_references")
+	case merge.StepMatching:
+		return loc.T("merge.status_matching")
+	case merge.StepRestoreMatching:
+		return loc.T("undo.status_restore_matching")
+	case merge.StepAttributes:
+		return loc.T("merge.status_attributes")
+	case merge.StepRestoreAttributes:
+		return loc.T("undo.status_restore_attributes")
+	case merge.StepCreate:
+		return loc.T("convert.status_create")
+	case merge.StepRemoveTag:
+		return fmt.Sprintf(loc.T("convert.status_remove_tag"), p.Document, p.Documents)
+	case merge.StepRename:
+		return fmt.Sprintf(loc.T("rename.status"), p.Item, p.Items)
+	case merge.StepVerify:
+		return loc.T("merge.status_verify")
+	case merge.StepRollback:
+		return loc.T("merge.status_rollback")
+	}
+	return ""
+}
+
+// maxMissing è il numero massimo di documenti elencati per una differenza o un errore
+const maxMissing = 10
+
+// documentList elenca gli ID dei documenti, al massimo maxMissing
+func documentList(docIDs []int) string {
+	var ids []string
+	for i, id := range docIDs {
+		if i == maxMissing {
+			ids = append(ids, "...")
+			break
+		}
+		ids = append(ids, strconv.Itoa(id))
+	}
+	return strings.Join(ids, ", ")
+}
+
+// discrepancyText descrive una differenza trovata dalla verifica del merge
+func discrepancyText(loc *locale.Localizer, entityType EntityType, d merge.Discrepancy) string {
+	switch d.Kind {
+	case merge.DiscrepancyRemaining:
+		return fmt.Sprintf(loc.T("verify.remaining"), entitySingular(loc, entityType), d.ItemID, d.Actual)
+	case merge.DiscrepancyNotDeleted:
+		return fmt.Sprintf(loc.T("verify.not_deleted"), entitySingular(loc, entityType), d.ItemID)
+	case merge.DiscrepancyName:
+		return fmt.Sprintf(loc.T("verify.name"), d.Name, d.FinalName)
+	case merge.DiscrepancyDocuments:
+		s := fmt.Sprintf(loc.T("verify.documents"), d.Actual, d.Expected)
+		if len(d.Missing) > 0 {
+			s += fmt.Sprintf(loc.T("verify.missing"), documentList(d.Missing))
+		}
+		return s
+	}
+	return ""
+}
+
+// verificationError riassume in un errore le differenze trovate dalla verifica (nil se non ce ne sono)
+func verificationError(loc *locale.Localizer, entityType EntityType, discrepancies []merge.Discrepancy) error {
+	if len(discrepancies) == 0 {
+		return nil
+	}
+	lines := []string{loc.T("verify.title")}
+	for _, d := range discrepancies {
+		lines = append(lines, discrepancyText(loc, entityType, d))
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// mergeError traduce gli errori del motore di merge in messaggi localizzati
+func mergeError(loc *locale.Localizer, entityType EntityType, err error) error {
+	if errors.Is(err, merge.ErrEmptyName) {
+		return errors.New(loc.T("merge.error_empty_name"))
+	}
+	if errors.Is(err, merge.ErrTooFewItems) {
+		return errors.New(loc.T("merge.error_min_items"))
+	}
+	if errors.Is(err, merge.ErrSurvivorNotSelected) {
+		return errors.New(loc.T("merge.error_survivor_not_selected"))
+	}
+	if errors.Is(err, merge.ErrInvalidTarget) {
+		return errors.New(loc.T("convert.error_target"))
+	}
+	if errors.Is(err, merge.ErrSplitUnsupported) {
+		return errors.New(loc.T("split.error_unsupported"))
+	}
+	if errors.Is(err, merge.ErrUnknownCorrespondent) {
+		return errors.New(loc.T("split.error_correspondent"))
+	}
+	if errors.Is(err, merge.ErrInvalidPattern) {
+		return errors.New(loc.T("split.error_pattern"))
+	}
+	if errors.Is(err, merge.ErrInvalidDateRange) {
+		return errors.New(loc.T("split.error_date"))
+	}
+	if errors.Is(err, merge.ErrSplitIntoSource) {
+		return errors.New(loc.T("split.error_source"))
+	}
+	if errors.Is(err, merge.ErrCleanupUnsupported) {
+		return errors.New(loc.T("cleanup.error_unsupported"))
+	}
+	if errors.Is(err, merge.ErrEmptyAffix) {
+		return errors.New(loc.T("rename.error_affix"))
+	}
+	if errors.Is(err, merge.ErrIncompatibleFields) {
+		return errors.New(loc.T("merge.error_incompatible_fields"))
+	}
+	if errors.Is(err, merge.ErrNotSelectField) {
+		return errors.New(loc.T("options.error_not_select"))
+	}
+	if errors.Is(err, merge.ErrInvalidOption) {
+		return errors.New(loc.T("options.error_invalid"))
+	}
+	if errors.Is(err, merge.ErrNothingToUndo) {
+		return errors.New(loc.T("undo.nothing"))
+	}
+	if errors.Is(err, merge.ErrRolledBack) {
+		return errors.New(loc.T("merge.rolled_back"))
+	}
+	if errors.Is(err, context.Canceled) {
+		return errors.New(loc.T("merge.cancelled"))
+	}
+
+	// Il server ha negato l'operazione: vale per qualunque fase
+	var apiErr *paperless.APIError
+	if errors.As(err, &apiErr) && paperless.IsForbidden(apiErr) {
+		return fmt.Errorf(loc.T("merge.error_forbidden"), apiErr.Method, apiErr.Endpoint)
+	}
+
+	var stepErr *merge.StepError
+	if !errors.As(err, &stepErr) {
+		return err
+	}
+
+	// Il nome è già usato da un altro elemento: la schermata di errore propone il merge
+	if nameConflictStep(stepErr.Step) && paperless.IsConflict(stepErr.Err) {
+		return fmt.Errorf(loc.T("merge.error_name_conflict"), stepErr.Name, entitySingular(loc, entityType))
+	}
+
+	// Più documenti non aggiornati: vengono elencati tutti insieme al primo errore
+	var docErrs merge.DocumentErrors
+	if errors.As(stepErr.Err, &docErrs) {
+		docIDs := make([]int, len(docErrs))
+		for i, docErr := range docErrs {
+			docIDs[i] = docErr.DocumentID
+		}
+		return fmt.Errorf(loc.T("merge.error_documents"), len(docErrs), documentList(docIDs), docErrs[0].Err)
+	}
+
+	switch stepErr.Step {
+	case merge.StepPrepare:
+		return fmt.Errorf(loc.T("merge.error_temp_update"), stepErr.Err)
+	case merge.StepGetDocuments:
+		return fmt.Errorf(loc.T("merge.error_get_docs"), stepErr.Err)
+	case merge.StepUpdateDocuments:
+		return fmt.Errorf(loc.T("merge.error_update_doc"), stepErr.DocumentID, stepErr.Err)
+	case merge.StepDelete:
+		return fmt.Errorf(loc.T("merge.error_delete"), entitySingular(loc, entityType), stepErr.ItemID, stepErr.Err)
+	case merge.StepFinalName:
+		return fmt.Errorf(loc.T("merge.error_final_update"), stepErr.Err)
+	case merge.StepSnapshot:
+		return fmt.Errorf(loc.T("merge.error_snapshot"), entitySingular(loc, entityType), stepErr.ItemID, stepErr.Err)
+	case merge.StepJournal:
+		return fmt.Errorf(loc.T("merge.error_journal"), stepErr.Err)
+	case merge.StepRestoreSurvivor:
+		return fmt.Errorf(loc.T("undo.error_restore_survivor"), stepErr.Err)
+	case merge.StepRecreate:
+		return fmt.Errorf(loc.T("undo.error_recreate"), entitySingular(loc, entityType), stepErr.ItemID, stepErr.Err)
+	case merge.StepRestoreDocuments:
+		return fmt.Errorf(loc.T("undo.error_restore_doc"), stepErr.DocumentID, stepErr.Err)
+	case merge.StepReferences:
+		return fmt.Errorf(loc.T("merge.error_references"), stepErr.Err)
+	case merge.StepRestoreReferences:
+		return fmt.Errorf(loc.T("undo.error_restore_references"), stepErr.Err)
+	case merge.StepMatching:
+		return fmt.Errorf(loc.T("merge.error_matching"), stepErr.Err)
+	case merge.StepRestoreMatching:
+		return fmt.Errorf(loc.T("undo.error_restore_matching"), stepErr.Err)
+	case merge.StepAttributes:
+		return fmt.Errorf(loc.T("merge.error_attributes"), stepErr.Err)
+	case merge.StepRestoreAttributes:
+		return fmt.Errorf(loc.T("undo.error_restore_attributes"), stepErr.Err)
+	case merge.StepCreate:
+		return fmt.Errorf(loc.T("convert.error_create"), stepErr.Err)
+	case merge.StepRemoveTag:
+		return fmt.Errorf(loc.T("convert.error_remove_tag"), stepErr.DocumentID, stepErr.Err)
+	case merge.StepRename:
+		return fmt.Errorf(loc.T("rename.error"), entitySingular(loc, entityType), stepErr.ItemID, stepErr.Err)
+	case merge.StepVerify:
+		return fmt.Errorf(loc.T("merge.error_verify"), stepErr.Err)
+	case merge.StepRollback:
+		return fmt.Errorf(loc.T("merge.error_rollback"), stepErr.Err)
+	}
+	return err
+}
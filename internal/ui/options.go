@@ -0,0 +1,206 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+type optionsMsg struct {
+	field merge.SelectField
+	err   error
+}
+
+// openOptions apre l'unione delle opzioni del campo select indicato
+func (m ListModel) openOptions(fieldID int) (tea.Model, tea.Cmd) {
+	m.mode = "options"
+	m.selectField = nil
+	m.optCursor = 0
+	m.optSelected = make(map[int]bool)
+	m.searchInput.Blur()
+
+	client := m.client
+	return m, func() tea.Msg {
+		field, err := merge.LoadSelectField(context.Background(), client, fieldID)
+		return optionsMsg{field: field, err: err}
+	}
+}
+
+// closeOptions torna all'elenco dei campi personalizzati
+func (m ListModel) closeOptions() ListModel {
+	if m.mergeMode == ModeManual {
+		m.mode = "manual"
+	} else {
+		m.mode = "select"
+	}
+	m.selectField = nil
+	m.optionMerge = nil
+	m.mergeInput.Blur()
+	return m
+}
+
+// selectedOptions restituisce gli indici delle opzioni selezionate, in ordine
+func (m ListModel) selectedOptions() []int {
+	var selected []int
+	if m.selectField == nil {
+		return selected
+	}
+	for i := range m.selectField.Options {
+		if m.optSelected[i] {
+			selected = append(selected, i)
+		}
+	}
+	return selected
+}
+
+func (m ListModel) updateOptionsMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		return m.closeOptions(), nil
+
+	case "up", "k":
+		if m.optCursor > 0 {
+			m.optCursor--
+		}
+
+	case "down", "j":
+		if m.selectField != nil && m.optCursor < len(m.selectField.Options)-1 {
+			m.optCursor++
+		}
+
+	case " ":
+		if m.selectField != nil && len(m.selectField.Options) > 0 {
+			m.optSelected[m.optCursor] = !m.optSelected[m.optCursor]
+		}
+
+	case "enter":
+		if len(m.selectedOptions()) >= 2 {
+			m.mode = "option_name"
+			m.mergeInput.SetValue(m.selectField.Options[m.optCursor])
+			return m, m.mergeInput.Focus()
+		}
+	}
+
+	return m, nil
+}
+
+func (m ListModel) updateOptionNameMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		m.mode = "options"
+		m.mergeInput.Blur()
+		return m, nil
+
+	case "enter":
+		optionMerge, err := merge.NewOptionMerge(*m.selectField, m.selectedOptions(), m.mergeInput.Value())
+		if err != nil {
+			m.err = mergeError(m.localizer, m.entityType, err)
+			return m, nil
+		}
+
+		m.mergeInput.Blur()
+		m.optionMerge = &optionMerge
+		return m.startOptionMerge(m.client)
+	}
+
+	var cmd tea.Cmd
+	m.mergeInput, cmd = m.mergeInput.Update(msg)
+	return m, cmd
+}
+
+// startOptionMerge avvia l'unione delle opzioni in una goroutine
+func (m ListModel) startOptionMerge(client *paperless.Client) (tea.Model, tea.Cmd) {
+	optionMerge := *m.optionMerge
+
+	m.merging = true
+	m.mergeStatus = m.localizer.T("merge.status_start")
+	m.mergeProgress = 0
+	m.mergeCurrent = 0
+	m.mergeTotal = 0
+	m.progressChan = make(chan tea.Msg, 10)
+	ctx := m.operationContext()
+
+	progressChan := m.progressChan
+	go func() {
+		executor := newExecutor(m.config, client, nil, merge.ReporterFunc(func(p merge.Progress) {
+			progressChan <- mergeProgressMsg{
+				current: p.Current,
+				total:   p.Total,
+				status:  progressStatus(m.localizer, p),
+			}
+		}))
+
+		var result tea.Msg = mergeCompleteMsg{}
+		if _, err := executor.MergeOptions(ctx, optionMerge); err != nil {
+			result = mergeCompleteMsg{err: mergeError(m.localizer, m.entityType, err)}
+		} else if client.DryRun {
+			result = mergeCompleteMsg{dryRun: true, simulated: client.SkippedRequests()}
+		}
+		progressChan <- result
+		close(progressChan)
+	}()
+
+	return m, waitForProgress(m.progressChan)
+}
+
+func (m ListModel) viewOptions() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	if m.selectField == nil {
+		return normalStyle.Render(m.localizer.T("list.loading")) + "\n"
+	}
+
+	var s string
+	field := m.selectField
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("options.title"), field.Name)) + "\n\n"
+
+	if m.mode == "option_name" {
+		s += normalStyle.Render(m.localizer.T("options.name_label")) + "\n\n"
+		s += m.mergeInput.View() + "\n\n"
+		for _, i := range m.selectedOptions() {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("options.item"), "[✓]", field.Options[i], field.Usage[i])) + "\n"
+		}
+		s += "\n" + normalStyle.Render(m.localizer.T("options.name_help")) + "\n"
+		return s
+	}
+
+	if len(field.Options) == 0 {
+		s += normalStyle.Render(m.localizer.T("options.none")) + "\n\n"
+		s += normalStyle.Render(m.localizer.T("list.error_back")) + "\n"
+		return s
+	}
+
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("options.select_label"), len(m.selectedOptions()), len(field.Options))) + "\n\n"
+	for i, label := range field.Options {
+		checkbox := "[ ]"
+		if m.optSelected[i] {
+			checkbox = "[✓]"
+		}
+		line := fmt.Sprintf(m.localizer.T("options.item"), checkbox, label, field.Usage[i])
+		if i == m.optCursor {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += normalStyle.Render("  "+line) + "\n"
+		}
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("options.help")) + "\n"
+	return s
+}
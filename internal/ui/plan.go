@@ -0,0 +1,285 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+type previewMsg struct {
+	preview merge.Preview
+	err     error
+}
+
+// loadPreview calcola in background l'anteprima del piano (conteggio documenti)
+func (m ListModel) loadPreview(plan merge.Plan) tea.Cmd {
+	items := m.selectedItems()
+	return func() tea.Msg {
+		preview, err := merge.NewPreview(context.Background(), m.client, plan, items)
+		return previewMsg{preview: preview, err: err}
+	}
+}
+
+func (m ListModel) updatePlanMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		// Torna all'inserimento del nome finale
+		m.mode = "merge"
+		m.plan = nil
+		m.preview = nil
+		return m, m.mergeInput.Focus()
+
+	case "enter":
+		// Con algoritmi di matching diversi l'utente deve prima scegliere quale mantenere
+		if m.matchingConflict() {
+			return m.openMatching(), nil
+		}
+		if m.preview != nil {
+			return m.startMerge(m.client)
+		}
+
+	case "d":
+		if m.matchingConflict() {
+			return m.openMatching(), nil
+		}
+		// Simula il merge con un client che non invia modifiche
+		if m.preview != nil {
+			dryClient, err := newClient(m.config)
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			dryClient.DryRun = true
+			return m.startMerge(dryClient)
+		}
+
+	case "m":
+		if m.preview != nil && merge.HasMatching(m.entityType) {
+			return m.openMatching(), nil
+		}
+
+	case "a":
+		// Sceglie da quale elemento il sopravvissuto prende colore, proprietario, ...
+		if m.preview != nil && len(merge.Attributes(m.entityType)) > 0 {
+			m.mode = "attributes"
+			m.optCursor = 0
+		}
+
+	case "n":
+		// Aggiunge (o toglie) i vecchi nomi come termini della regola di matching
+		if m.preview != nil && merge.HasMatching(m.entityType) {
+			m.matchNames = !m.matchNames
+		}
+	}
+
+	return m, nil
+}
+
+func (m ListModel) updateDryRunMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "enter":
+		// Torna al piano: nulla è cambiato sul server
+		m.dryRunLog = nil
+		m.mode = "plan"
+		if m.optionMerge != nil {
+			m.optionMerge = nil
+			m.mode = "options"
+		} else if m.conversion != nil {
+			m.mode = "convert_plan"
+		} else if m.splitSource != nil {
+			m.mode = "split"
+		} else if m.renamePlan != nil {
+			m.mode = "rename_plan"
+		} else if len(m.queue) > 0 && m.currentGroup == nil {
+			m.mode = "browse"
+		}
+	}
+
+	return m, nil
+}
+
+// startMerge avvia l'esecuzione del piano corrente in una goroutine
+func (m ListModel) startMerge(client *paperless.Client) (tea.Model, tea.Cmd) {
+	plan := *m.plan
+	plan.Matching = m.chosenMatching()
+	plan.Sources = m.planSources()
+
+	m.merging = true
+	m.mergeStatus = m.localizer.T("merge.status_start")
+	m.mergeProgress = 0
+	m.mergeCurrent = 0
+	m.mergeTotal = 0
+
+	// Crea canale per progress
+	m.progressChan = make(chan tea.Msg, 10)
+	ctx := m.operationContext()
+
+	// Avvia merge in goroutine
+	go func() {
+		result := m.executeMerge(ctx, m.progressChan, client, plan)
+		m.progressChan <- result
+		close(m.progressChan)
+	}()
+
+	// Inizia ad ascoltare il canale
+	return m, waitForProgress(m.progressChan)
+}
+
+func (m ListModel) viewPlan() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	s += selectedStyle.Render(m.localizer.T("list.plan_title")) + "\n\n"
+
+	if m.preview == nil {
+		s += normalStyle.Render(m.localizer.T("list.plan_loading")) + "\n"
+		return s
+	}
+
+	p := m.preview
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_survivor"), p.Survivor.Name, p.Survivor.ID, p.Survivor.Documents)) + "\n"
+	if p.Plan.Rename {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_temp_rename"), p.Plan.TempName())) + "\n"
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_final_name"), p.Plan.FinalName)) + "\n"
+	} else {
+		s += normalStyle.Render(m.localizer.T("list.plan_keep_name")) + "\n"
+	}
+	s += "\n"
+
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_absorbed"), len(p.Absorbed))) + "\n"
+	for _, item := range p.Absorbed {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_absorbed_item"), item.Name, item.ID, item.Documents)) + "\n"
+	}
+	s += "\n"
+
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_total_docs"), p.DocumentsToMove())) + "\n\n"
+
+	if merge.HasMatching(m.entityType) {
+		s += m.viewPlanMatching()
+	}
+	s += m.viewPlanAttributes()
+
+	if len(p.References) > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.plan_references"), len(p.References))) + "\n"
+		for _, ref := range p.References {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.reference_item"), referenceSource(m.localizer, ref.Source), ref.Name, ref.ID)) + "\n"
+		}
+		s += "\n"
+	}
+
+	if m.client.DryRun {
+		s += normalStyle.Render(m.localizer.T("list.plan_help_dry_run")) + "\n"
+	} else {
+		s += normalStyle.Render(m.localizer.T("list.plan_help")) + "\n"
+	}
+	if len(merge.Attributes(m.entityType)) > 0 {
+		s += normalStyle.Render(m.localizer.T("list.plan_help_attributes")) + "\n"
+	}
+	return s
+}
+
+func (m ListModel) updateSummaryMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "enter":
+		return m.reload()
+	}
+
+	return m, nil
+}
+
+// viewSummary mostra i riferimenti riscritti dal merge appena completato e le differenze
+// trovate dalla verifica
+func (m ListModel) viewSummary() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	var s string
+	if len(m.discrepancies) > 0 {
+		s += errorStyle.Render(m.localizer.T("verify.title")) + "\n\n"
+		s += m.viewDiscrepancies(m.discrepancies) + "\n"
+	} else {
+		s += selectedStyle.Render(m.localizer.T("list.summary_title")) + "\n\n"
+	}
+	if len(m.summary) > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.summary_references"), len(m.summary))) + "\n"
+		for _, ref := range m.summary {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.reference_item"), referenceSource(m.localizer, ref.Source), ref.Name, ref.ID)) + "\n"
+		}
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("list.summary_help")) + "\n"
+	return s
+}
+
+// viewDiscrepancies elenca le differenze trovate dalla verifica di un merge
+func (m ListModel) viewDiscrepancies(discrepancies []merge.Discrepancy) string {
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196"))
+
+	var s string
+	for _, d := range discrepancies {
+		s += errorStyle.Render("  • "+discrepancyText(m.localizer, m.entityType, d)) + "\n"
+	}
+	return s
+}
+
+func (m ListModel) viewDryRun() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("list.dryrun_title"), len(m.dryRunLog))) + "\n\n"
+
+	if len(m.dryRunLog) == 0 {
+		s += normalStyle.Render(m.localizer.T("list.dryrun_none")) + "\n"
+	}
+
+	// Mostra solo le richieste che entrano nel terminale
+	maxVisible := m.height - 8
+	if maxVisible < 5 {
+		maxVisible = 5
+	}
+	for i, entry := range m.dryRunLog {
+		if i >= maxVisible {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.dryrun_more"), len(m.dryRunLog)-maxVisible)) + "\n"
+			break
+		}
+		s += normalStyle.Render("  "+entry) + "\n"
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("list.dryrun_help")) + "\n"
+	return s
+}
@@ -0,0 +1,344 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// groupKey identifica un gruppo di elementi simili per ricordare quelli da non proporre più
+func groupKey(entityType EntityType, group similarity.SimilarityGroup) string {
+	ids := make([]int, len(group.Items))
+	for i, item := range group.Items {
+		ids[i] = item.ID
+	}
+	sort.Ints(ids)
+
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return fmt.Sprintf("%d:%s", entityType, strings.Join(parts, ","))
+}
+
+// ignoredGroup indica se il gruppo è tra quelli da non proporre più
+func (m ListModel) ignoredGroup(group similarity.SimilarityGroup) bool {
+	return m.config.IsIgnored(groupKey(m.entityType, group))
+}
+
+// nextGroup sposta il cursore sul gruppo successivo, per rivedere i gruppi uno dopo l'altro
+func (m ListModel) nextGroup() ListModel {
+	if m.cursor < len(m.groups)-1 {
+		m.cursor++
+	}
+	return m
+}
+
+// openQueueName chiede il nome finale del merge da mettere in coda per il gruppo sotto il cursore.
+// Dalla modalità browse vengono unite tutte le voci del gruppo, dalla selezione quelle selezionate.
+func (m ListModel) openQueueName() (tea.Model, tea.Cmd) {
+	m.queueFrom = m.mode
+	if m.mode == "browse" {
+		m.currentGroup = &m.groups[m.cursor]
+		m.selectedMap = make(map[int]bool)
+		m.survivorID = 0
+		for _, item := range m.currentGroup.Items {
+			m.selectedMap[item.ID] = true
+		}
+	}
+
+	m.mode = "queue_name"
+	name := m.currentGroup.Representative
+	if plan, ok := m.queue[m.cursor]; ok {
+		name = plan.FinalName
+	} else if survivor, ok := m.survivorName(); ok {
+		name = survivor
+	}
+	m.mergeInput.SetValue(name)
+	return m, m.mergeInput.Focus()
+}
+
+// closeQueueName torna alla lista dei gruppi (o alla selezione se annullato da lì)
+func (m ListModel) closeQueueName(cancelled bool) ListModel {
+	m.mergeInput.Blur()
+	if cancelled && m.queueFrom == "select" {
+		m.mode = "select"
+		return m
+	}
+	m.mode = "browse"
+	m.currentGroup = nil
+	m.selectedMap = make(map[int]bool)
+	m.survivorID = 0
+	return m
+}
+
+// queuedPlans restituisce i merge in coda nell'ordine dei gruppi
+func (m ListModel) queuedPlans() []merge.Plan {
+	indexes := make([]int, 0, len(m.queue))
+	for i := range m.queue {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	plans := make([]merge.Plan, len(indexes))
+	for i, index := range indexes {
+		plans[i] = m.queue[index]
+	}
+	return plans
+}
+
+// updateQueueKeys gestisce i tasti della coda nella lista dei gruppi
+func (m ListModel) updateQueueKeys(key string) (tea.Model, tea.Cmd, bool) {
+	if len(m.groups) == 0 && key != "i" {
+		return m, nil, false
+	}
+
+	switch key {
+	case "m":
+		// Merge del gruppo con il nome scelto
+		model, cmd := m.openQueueName()
+		return model, cmd, true
+
+	case "s":
+		// Il gruppo viene saltato (e tolto dalla coda)
+		if m.skipped[m.cursor] {
+			delete(m.skipped, m.cursor)
+			return m, nil, true
+		}
+		delete(m.queue, m.cursor)
+		m.skipped[m.cursor] = true
+		return m.nextGroup(), nil, true
+
+	case "n":
+		// Il gruppo non verrà più proposto
+		group := m.groups[m.cursor]
+		ignored := !m.ignoredGroup(group)
+		if err := m.config.SetIgnored(groupKey(m.entityType, group), ignored); err != nil {
+			m.err = err
+			return m, nil, true
+		}
+		if ignored {
+			delete(m.queue, m.cursor)
+			delete(m.skipped, m.cursor)
+			return m.nextGroup(), nil, true
+		}
+		return m, nil, true
+
+	case "i":
+		// Mostra o nasconde i gruppi da non proporre più
+		m.showIgnored = !m.showIgnored
+		model, cmd := m.reload()
+		return model, cmd, true
+
+	case "e":
+		if len(m.queue) > 0 {
+			model, cmd := m.startQueue(m.client)
+			return model, cmd, true
+		}
+
+	case "d":
+		// Simula la coda con un client che non invia modifiche
+		if len(m.queue) > 0 {
+			dryClient, err := newClient(m.config)
+			if err != nil {
+				m.err = err
+				return m, nil, true
+			}
+			dryClient.DryRun = true
+			model, cmd := m.startQueue(dryClient)
+			return model, cmd, true
+		}
+	}
+
+	return m, nil, false
+}
+
+func (m ListModel) updateQueueNameMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		return m.closeQueueName(true), nil
+
+	case "enter":
+		plan, err := merge.NewPlan(m.entityType, m.selectedItems(), m.survivorID, m.mergeInput.Value())
+		if err != nil {
+			m.err = mergeError(m.localizer, m.entityType, err)
+			return m, nil
+		}
+
+		m.queue[m.cursor] = plan
+		delete(m.skipped, m.cursor)
+		return m.closeQueueName(false).nextGroup(), nil
+	}
+
+	var cmd tea.Cmd
+	m.mergeInput, cmd = m.mergeInput.Update(msg)
+	return m, cmd
+}
+
+func (m ListModel) updateQueueDoneMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "enter":
+		return m.reload()
+	}
+
+	return m, nil
+}
+
+// startQueue esegue tutti i merge in coda in una goroutine
+func (m ListModel) startQueue(client *paperless.Client) (tea.Model, tea.Cmd) {
+	plans := m.queuedPlans()
+
+	m.merging = true
+	m.mergeStatus = m.localizer.T("merge.status_start")
+	m.mergeProgress = 0
+	m.mergeCurrent = 0
+	m.mergeTotal = 0
+	m.progressChan = make(chan tea.Msg, 10)
+	ctx := m.operationContext()
+
+	progressChan := m.progressChan
+	go func() {
+		// Ogni merge della coda viene registrato nel journal come quelli singoli
+		var journal *merge.JournalStore
+		if !client.DryRun {
+			store, err := journalStore(m.config)
+			if err != nil {
+				progressChan <- mergeCompleteMsg{err: err}
+				close(progressChan)
+				return
+			}
+			journal = store
+		}
+
+		executor := newExecutor(m.config, client, journal, merge.ReporterFunc(func(p merge.Progress) {
+			progressChan <- mergeProgressMsg{
+				current: p.Current,
+				total:   p.Total,
+				status:  fmt.Sprintf(m.localizer.T("queue.status"), p.Plan, p.Plans, progressStatus(m.localizer, p)),
+			}
+		}))
+
+		results := executor.ExecuteQueue(ctx, plans)
+		if client.DryRun {
+			progressChan <- mergeCompleteMsg{dryRun: true, simulated: client.SkippedRequests()}
+		} else {
+			progressChan <- mergeCompleteMsg{queue: results}
+		}
+		close(progressChan)
+	}()
+
+	return m, waitForProgress(m.progressChan)
+}
+
+// groupMark descrive lo stato del gruppo nella revisione della coda
+func (m ListModel) groupMark(index int) string {
+	if m.ignoredGroup(m.groups[index]) {
+		return m.localizer.T("queue.mark_never")
+	}
+	if plan, ok := m.queue[index]; ok {
+		return fmt.Sprintf(m.localizer.T("queue.mark_merge"), plan.FinalName)
+	}
+	if m.skipped[index] {
+		return m.localizer.T("queue.mark_skip")
+	}
+	return ""
+}
+
+// viewQueueCounts riassume la revisione dei gruppi
+func (m ListModel) viewQueueCounts() string {
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	never := 0
+	for _, group := range m.groups {
+		if m.ignoredGroup(group) {
+			never++
+		}
+	}
+	return normalStyle.Render(fmt.Sprintf(m.localizer.T("queue.counts"), len(m.queue), len(m.skipped), never)) + "\n"
+}
+
+func (m ListModel) viewQueueName() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("queue.name_title"), m.currentGroup.Representative)) + "\n\n"
+	s += normalStyle.Render(m.localizer.T("list.merge_input_label")) + "\n\n"
+	s += m.mergeInput.View() + "\n\n"
+
+	var selected []string
+	for _, item := range m.selectedItems() {
+		selected = append(selected, item.Name)
+	}
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.merge_items_to_merge"), len(selected))) + "\n"
+	s += normalStyle.Render(strings.Join(selected, " → ")) + "\n\n"
+	if name, ok := m.survivorName(); ok {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.merge_survivor"), name, m.survivorID)) + "\n\n"
+	}
+
+	s += normalStyle.Render(m.localizer.T("queue.name_help")) + "\n"
+	return s
+}
+
+// viewQueueDone mostra l'esito di ogni merge della coda
+func (m ListModel) viewQueueDone() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196"))
+
+	var s string
+	s += selectedStyle.Render(m.localizer.T("queue.done_title")) + "\n\n"
+
+	completed, failed, references := 0, 0, 0
+	for _, r := range m.queueResults {
+		if r.Err != nil {
+			failed++
+			s += errorStyle.Render(fmt.Sprintf(m.localizer.T("queue.done_failed"), r.Plan.FinalName, mergeError(m.localizer, m.entityType, r.Err))) + "\n"
+			continue
+		}
+		completed++
+		references += len(r.Result.References)
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("queue.done_merged"), r.Plan.FinalName, len(r.Plan.AbsorbIDs)+1, r.Result.DocumentsMoved)) + "\n"
+		if len(r.Result.Discrepancies) > 0 {
+			s += errorStyle.Render("  "+m.localizer.T("verify.title")) + "\n"
+			s += m.viewDiscrepancies(r.Result.Discrepancies)
+		}
+	}
+
+	s += "\n" + normalStyle.Render(fmt.Sprintf(m.localizer.T("queue.done_counts"), completed, failed)) + "\n"
+	if references > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("queue.done_references"), references)) + "\n"
+	}
+	if failed > 0 {
+		s += normalStyle.Render(m.localizer.T("queue.done_failed_help")) + "\n"
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("list.summary_help")) + "\n"
+	return s
+}
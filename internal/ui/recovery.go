@@ -0,0 +1,333 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/config"
+	"github.com/meska/paperless-merger/internal/locale"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// RecoveryModel rappresenta la schermata di avvio che segnala i merge interrotti
+// e gli elementi rimasti col nome temporaneo __MERGING_
+type RecoveryModel struct {
+	config       *config.Config
+	localizer    *locale.Localizer
+	client       *paperless.Client
+	journals     []*merge.Journal // Merge interrotti
+	leftovers    []merge.Leftover // Elementi __MERGING_ senza journal
+	cursor       int
+	loading      bool
+	working      bool
+	status       string
+	workProgress float64
+	progressChan chan tea.Msg
+	progress     progress.Model
+	cancel       context.CancelFunc // Annulla l'operazione in corso (tasto Esc)
+	cancelling   bool               // Annullamento richiesto, in attesa che l'operazione si fermi
+	err          error
+}
+
+type recoveryScanMsg struct {
+	journals  []*merge.Journal
+	leftovers []merge.Leftover
+	err       error
+}
+
+type recoveryCompleteMsg struct {
+	err error
+}
+
+// NewRecoveryModel crea la schermata di ripristino dei merge interrotti
+func NewRecoveryModel(cfg *config.Config) RecoveryModel {
+	loc, err := locale.New(cfg.Language)
+	if err != nil {
+		loc, _ = locale.New("en")
+	}
+
+	client, clientErr := newClient(cfg)
+	if clientErr == nil {
+		client.DryRun = cfg.DryRun
+	}
+
+	prog := progress.New(progress.WithDefaultGradient())
+	prog.Width = 50
+
+	return RecoveryModel{
+		config:    cfg,
+		localizer: loc,
+		client:    client,
+		loading:   clientErr == nil,
+		err:       clientErr,
+		progress:  prog,
+	}
+}
+
+// operationContext crea il contesto di un'operazione di recupero, annullabile con Esc
+func (m *RecoveryModel) operationContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.cancelling = false
+	return ctx
+}
+
+func (m RecoveryModel) Init() tea.Cmd {
+	if m.err != nil {
+		return nil
+	}
+	return m.scan
+}
+
+// scan cerca i journal interrotti e gli elementi col nome temporaneo
+func (m RecoveryModel) scan() tea.Msg {
+	store, err := journalStore(m.config)
+	if err != nil {
+		return recoveryScanMsg{err: err}
+	}
+
+	journals, err := store.Unfinished()
+	if err != nil {
+		return recoveryScanMsg{err: err}
+	}
+
+	leftovers, err := merge.FindLeftovers(context.Background(), m.client, journals)
+	if err != nil {
+		return recoveryScanMsg{journals: journals, err: err}
+	}
+
+	return recoveryScanMsg{journals: journals, leftovers: leftovers}
+}
+
+func (m RecoveryModel) entries() int {
+	return len(m.journals) + len(m.leftovers)
+}
+
+func (m RecoveryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case recoveryScanMsg:
+		m.loading = false
+		m.journals = msg.journals
+		m.leftovers = msg.leftovers
+		m.err = msg.err
+		if m.err == nil && m.entries() == 0 {
+			// Nessun merge da recuperare: vai al menu principale
+			return NewMainModel(m.config), nil
+		}
+		if m.cursor >= m.entries() {
+			m.cursor = 0
+		}
+		return m, nil
+
+	case mergeProgressMsg:
+		m.status = msg.status
+		if msg.total > 0 {
+			m.workProgress = float64(msg.current) / float64(msg.total)
+		}
+		return m, waitForProgress(m.progressChan)
+
+	case recoveryCompleteMsg:
+		m.working = false
+		if m.cancel != nil {
+			m.cancel()
+			m.cancel = nil
+		}
+		m.cancelling = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		// Ricontrolla: potrebbero esserci altri elementi da recuperare
+		m.loading = true
+		return m, m.scan
+
+	case tea.KeyMsg:
+		// Durante un'operazione Esc la ferma tra un passo e l'altro: il journal registra
+		// fin dove è arrivata e il merge resta da riprendere o annullare
+		if m.working || m.loading {
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "esc":
+				if m.cancel != nil {
+					m.cancelling = true
+					m.cancel()
+				}
+			}
+			return m, nil
+		}
+
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+
+		case "esc", "q":
+			// Ignora e prosegui al menu principale
+			return NewMainModel(m.config), nil
+
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+
+		case "down", "j":
+			if m.cursor < m.entries()-1 {
+				m.cursor++
+			}
+
+		case "r":
+			if m.cursor < len(m.journals) {
+				journal := m.journals[m.cursor]
+				return m.start(journal.Plan.Kind, func(ctx context.Context, e *merge.Executor) error {
+					result, err := e.Resume(ctx, journal)
+					if err != nil {
+						return err
+					}
+					return verificationError(m.localizer, journal.Plan.Kind, result.Discrepancies)
+				})
+			}
+
+		case "b":
+			if m.cursor < len(m.journals) {
+				journal := m.journals[m.cursor]
+				return m.start(journal.Plan.Kind, func(ctx context.Context, e *merge.Executor) error {
+					return e.Undo(ctx, journal)
+				})
+			}
+
+		case "f":
+			if m.cursor >= len(m.journals) && m.cursor < m.entries() {
+				leftover := m.leftovers[m.cursor-len(m.journals)]
+				return m.start(leftover.Kind, func(ctx context.Context, e *merge.Executor) error {
+					return e.FixLeftover(ctx, leftover)
+				})
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// start esegue un'operazione di recupero in una goroutine, con un contesto annullabile con Esc
+func (m RecoveryModel) start(kind merge.Kind, operation func(context.Context, *merge.Executor) error) (tea.Model, tea.Cmd) {
+	store, err := journalStore(m.config)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	if m.client.DryRun {
+		store = nil
+	}
+
+	m.err = nil
+	m.working = true
+	m.status = m.localizer.T("merge.status_start")
+	m.workProgress = 0
+	m.progressChan = make(chan tea.Msg, 10)
+
+	ctx := m.operationContext()
+	progressChan := m.progressChan
+	executor := newExecutor(m.config, m.client, store, merge.ReporterFunc(func(p merge.Progress) {
+		progressChan <- mergeProgressMsg{
+			current: p.Current,
+			total:   p.Total,
+			status:  progressStatus(m.localizer, p),
+		}
+	}))
+
+	go func() {
+		err := operation(ctx, executor)
+		if err != nil {
+			err = mergeError(m.localizer, kind, err)
+		}
+		progressChan <- recoveryCompleteMsg{err: err}
+		close(progressChan)
+	}()
+
+	return m, waitForProgress(m.progressChan)
+}
+
+func (m RecoveryModel) View() string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170")).
+		MarginBottom(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	s := titleStyle.Render(m.localizer.T("recovery.title")) + "\n\n"
+
+	if m.loading {
+		s += normalStyle.Render(m.localizer.T("recovery.scanning")) + "\n"
+		return s
+	}
+
+	if m.working {
+		s += normalStyle.Render(m.status) + "\n\n"
+		s += m.progress.ViewAs(m.workProgress) + "\n"
+		if m.cancelling {
+			s += "\n" + normalStyle.Render(m.localizer.T("list.cancelling")) + "\n"
+		} else if m.cancel != nil {
+			s += "\n" + normalStyle.Render(m.localizer.T("list.cancel_help")) + "\n"
+		}
+		return s
+	}
+
+	if m.entries() > 0 {
+		s += normalStyle.Render(m.localizer.T("recovery.intro")) + "\n\n"
+	}
+
+	for i, j := range m.journals {
+		done := 0
+		for _, absorbed := range j.Absorbed {
+			if absorbed.Deleted {
+				done++
+			}
+		}
+		line := fmt.Sprintf(m.localizer.T("recovery.journal_item"),
+			entityPlural(m.localizer, j.Plan.Kind), j.CreatedAt.Format("2006-01-02 15:04"), j.Plan.FinalName, done, len(j.Absorbed))
+		if j.Cleanup {
+			line = fmt.Sprintf(m.localizer.T("recovery.cleanup_item"),
+				entityPlural(m.localizer, j.Plan.Kind), j.CreatedAt.Format("2006-01-02 15:04"), done, len(j.Absorbed))
+		}
+		if i == m.cursor {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += normalStyle.Render("  "+line) + "\n"
+		}
+	}
+
+	for i, l := range m.leftovers {
+		line := fmt.Sprintf(m.localizer.T("recovery.leftover_item"), entityPlural(m.localizer, l.Kind), l.Name, l.FinalName)
+		if len(m.journals)+i == m.cursor {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += normalStyle.Render("  "+line) + "\n"
+		}
+	}
+	s += "\n"
+
+	if m.err != nil {
+		s += errorStyle.Render(fmt.Sprintf(m.localizer.T("list.error"), m.err)) + "\n\n"
+	}
+
+	if m.cursor < len(m.journals) {
+		s += normalStyle.Render(m.localizer.T("recovery.help_journal")) + "\n"
+	} else {
+		s += normalStyle.Render(m.localizer.T("recovery.help_leftover")) + "\n"
+	}
+	return s
+}
@@ -0,0 +1,595 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// renameMaxWidth è la larghezza massima della colonna "prima" della tabella di rinomina
+const renameMaxWidth = 40
+
+// openRename apre la rinomina di massa degli elementi indicati (tutti se scope è vuoto)
+func (m ListModel) openRename(scope []int) (tea.Model, tea.Cmd) {
+	m.mode = "rename"
+	m.renameScope = scope
+	m.renameRules = nil
+	m.renamePlan = nil
+	m.renameMerge = make(map[int]bool)
+	m.renameResult = nil
+	m.optCursor = 0
+	m.searchInput.Blur()
+	return m, nil
+}
+
+// closeRename torna all'elenco da cui è partita la rinomina
+func (m ListModel) closeRename() ListModel {
+	switch {
+	case m.mergeMode == ModeManual:
+		m.mode = "manual"
+	case m.currentGroup != nil:
+		m.mode = "select"
+	default:
+		m.mode = "browse"
+	}
+	m.renameRules = nil
+	m.renamePlan = nil
+	m.mergeInput.Blur()
+	m.targetInput.Blur()
+	return m
+}
+
+// renameScopeIDs restituisce gli elementi da rinominare nel contesto corrente: quelli
+// selezionati, altrimenti quelli del gruppo aperto o filtrati (nil per tutti)
+func (m ListModel) renameScopeIDs() []int {
+	var ids []int
+	for _, item := range m.selectedItems() {
+		ids = append(ids, item.ID)
+	}
+	if len(ids) > 0 {
+		return ids
+	}
+
+	switch {
+	case m.mode == "select" && m.currentGroup != nil:
+		for _, item := range m.currentGroup.Items {
+			ids = append(ids, item.ID)
+		}
+	case m.mode == "manual" && len(m.filteredItems) < len(m.allItems):
+		for _, item := range m.filteredItems {
+			ids = append(ids, item.ID)
+		}
+		if len(ids) == 0 {
+			// Nessun elemento filtrato: uno scope vuoto indicherebbe tutti gli elementi
+			ids = []int{0}
+		}
+	}
+	return ids
+}
+
+// renameCount restituisce il numero di elementi coinvolti nella rinomina
+func (m ListModel) renameCount() int {
+	if len(m.renameScope) == 0 {
+		return len(m.allItems)
+	}
+	return len(m.renameScope)
+}
+
+// currentRenamePlan calcola il piano di rinomina con le regole inserite
+func (m ListModel) currentRenamePlan(rules []merge.RenameRule) merge.RenamePlan {
+	return merge.NewRenamePlan(m.entityType, m.allItems, m.renameScope, rules)
+}
+
+// newRenameRule costruisce la regola dai valori inseriti
+func (m ListModel) newRenameRule() (merge.RenameRule, error) {
+	return merge.NewRenameRule(m.renameOp, m.mergeInput.Value(), m.targetInput.Value())
+}
+
+// renameMerges restituisce i piani di merge delle collisioni da unire
+func (m ListModel) renameMerges() ([]merge.Plan, error) {
+	var plans []merge.Plan
+	for i, collision := range m.renamePlan.Collisions {
+		if !m.renameMerge[i] {
+			continue
+		}
+		plan, err := m.renamePlan.MergePlan(collision)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// sharesItems indica se la collisione ha elementi in comune con un'altra collisione da unire
+func (m ListModel) sharesItems(index int) bool {
+	ids := make(map[int]bool)
+	for _, r := range m.renamePlan.Collisions[index].Items {
+		ids[r.Item.ID] = true
+	}
+	for i, collision := range m.renamePlan.Collisions {
+		if i == index || !m.renameMerge[i] {
+			continue
+		}
+		for _, r := range collision.Items {
+			if ids[r.Item.ID] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m ListModel) updateRenameMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		return m.closeRename(), nil
+
+	case "up", "k":
+		if m.optCursor > 0 {
+			m.optCursor--
+		}
+
+	case "down", "j":
+		if m.optCursor < len(m.renameRules)-1 {
+			m.optCursor++
+		}
+
+	case "a":
+		m.mode = "rename_rule"
+		m.renameOp = merge.RenameOps[0]
+		m.mergeInput.SetValue("")
+		m.targetInput.SetValue("")
+		m.targetInput.Blur()
+		return m, m.mergeInput.Focus()
+
+	case "x", "delete":
+		// Rimuove la regola sotto il cursore
+		if m.optCursor < len(m.renameRules) {
+			m.renameRules = append(m.renameRules[:m.optCursor:m.optCursor], m.renameRules[m.optCursor+1:]...)
+			if m.optCursor > 0 && m.optCursor >= len(m.renameRules) {
+				m.optCursor--
+			}
+		}
+
+	case "enter":
+		if len(m.renameRules) > 0 {
+			plan := m.currentRenamePlan(m.renameRules)
+			m.renamePlan = &plan
+			m.renameMerge = make(map[int]bool)
+			m.optCursor = 0
+			m.mode = "rename_plan"
+		}
+	}
+
+	return m, nil
+}
+
+func (m ListModel) updateRenameRuleMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		m.mode = "rename"
+		m.mergeInput.Blur()
+		m.targetInput.Blur()
+		return m, nil
+
+	case "tab":
+		// Passa all'operazione successiva
+		for i, op := range merge.RenameOps {
+			if op == m.renameOp {
+				m.renameOp = merge.RenameOps[(i+1)%len(merge.RenameOps)]
+				break
+			}
+		}
+		m.targetInput.Blur()
+		if m.renameOp.UsesPattern() {
+			return m, m.mergeInput.Focus()
+		}
+		m.mergeInput.Blur()
+		return m, nil
+
+	case "up", "down":
+		// Passa dall'espressione alla sostituzione e viceversa
+		if m.renameOp != merge.RenameReplace {
+			return m, nil
+		}
+		if m.mergeInput.Focused() {
+			m.mergeInput.Blur()
+			return m, m.targetInput.Focus()
+		}
+		m.targetInput.Blur()
+		return m, m.mergeInput.Focus()
+
+	case "enter":
+		if m.renameOp == merge.RenameReplace && m.mergeInput.Focused() {
+			m.mergeInput.Blur()
+			return m, m.targetInput.Focus()
+		}
+
+		rule, err := m.newRenameRule()
+		if err != nil {
+			m.err = mergeError(m.localizer, m.entityType, err)
+			return m, nil
+		}
+		m.renameRules = append(m.renameRules, rule)
+		m.optCursor = len(m.renameRules) - 1
+		m.mode = "rename"
+		m.mergeInput.Blur()
+		m.targetInput.Blur()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	if m.mergeInput.Focused() {
+		m.mergeInput, cmd = m.mergeInput.Update(msg)
+	} else if m.targetInput.Focused() {
+		m.targetInput, cmd = m.targetInput.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m ListModel) updateRenamePlanMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		m.mode = "rename"
+		m.renamePlan = nil
+		m.optCursor = len(m.renameRules) - 1
+
+	case "up", "k":
+		if m.optCursor > 0 {
+			m.optCursor--
+		}
+
+	case "down", "j":
+		if m.optCursor < len(m.renamePlan.Collisions)-1 {
+			m.optCursor++
+		}
+
+	case " ":
+		// Unisce (o lascia invariati) gli elementi della collisione sotto il cursore
+		if m.optCursor < len(m.renamePlan.Collisions) {
+			if m.renameMerge[m.optCursor] {
+				delete(m.renameMerge, m.optCursor)
+				return m, nil
+			}
+			if m.sharesItems(m.optCursor) {
+				m.err = errors.New(m.localizer.T("rename.error_shared"))
+				return m, nil
+			}
+			if _, err := m.renamePlan.MergePlan(m.renamePlan.Collisions[m.optCursor]); err != nil {
+				m.err = mergeError(m.localizer, m.entityType, err)
+				return m, nil
+			}
+			m.renameMerge[m.optCursor] = true
+		}
+
+	case "enter":
+		return m.startRename(m.client)
+
+	case "d":
+		// Simula la rinomina con un client che non invia modifiche
+		dryClient, err := newClient(m.config)
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		dryClient.DryRun = true
+		return m.startRename(dryClient)
+	}
+
+	return m, nil
+}
+
+func (m ListModel) updateRenameDoneMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "enter":
+		return m.reload()
+	}
+
+	return m, nil
+}
+
+// startRename avvia la rinomina e i merge delle collisioni scelte in una goroutine
+func (m ListModel) startRename(client *paperless.Client) (tea.Model, tea.Cmd) {
+	plan := *m.renamePlan
+	merges, err := m.renameMerges()
+	if err != nil {
+		m.err = mergeError(m.localizer, m.entityType, err)
+		return m, nil
+	}
+	if len(plan.Renames) == 0 && len(merges) == 0 {
+		return m, nil
+	}
+
+	m.merging = true
+	m.mergeStatus = m.localizer.T("merge.status_start")
+	m.mergeProgress = 0
+	m.mergeCurrent = 0
+	m.mergeTotal = 0
+	m.progressChan = make(chan tea.Msg, 10)
+	ctx := m.operationContext()
+
+	progressChan := m.progressChan
+	go func() {
+		// I merge delle collisioni vengono registrati nel journal come gli altri
+		var journal *merge.JournalStore
+		if !client.DryRun && len(merges) > 0 {
+			store, err := journalStore(m.config)
+			if err != nil {
+				progressChan <- mergeCompleteMsg{err: err}
+				close(progressChan)
+				return
+			}
+			journal = store
+		}
+
+		executor := newExecutor(m.config, client, journal, merge.ReporterFunc(func(p merge.Progress) {
+			progressChan <- mergeProgressMsg{
+				current: p.Current,
+				total:   p.Total,
+				status:  progressStatus(m.localizer, p),
+			}
+		}))
+
+		var msg tea.Msg
+		result, err := executor.Rename(ctx, plan, merges)
+		switch {
+		case err != nil:
+			msg = mergeCompleteMsg{err: mergeError(m.localizer, m.entityType, err), conflict: renameConflict(err)}
+		case client.DryRun:
+			msg = mergeCompleteMsg{dryRun: true, simulated: client.SkippedRequests()}
+		default:
+			msg = mergeCompleteMsg{rename: &result}
+		}
+		progressChan <- msg
+		close(progressChan)
+	}()
+
+	return m, waitForProgress(m.progressChan)
+}
+
+// renameOpName restituisce il nome localizzato di un'operazione di rinomina
+func (m ListModel) renameOpName(op merge.RenameOp) string {
+	switch op {
+	case merge.RenameReplace:
+		return m.localizer.T("rename.op_replace")
+	case merge.RenamePrefix:
+		return m.localizer.T("rename.op_prefix")
+	case merge.RenameSuffix:
+		return m.localizer.T("rename.op_suffix")
+	case merge.RenameTitleCase:
+		return m.localizer.T("rename.op_title_case")
+	case merge.RenameLowerCase:
+		return m.localizer.T("rename.op_lower_case")
+	case merge.RenameUpperCase:
+		return m.localizer.T("rename.op_upper_case")
+	}
+	return ""
+}
+
+// renameRuleLine descrive una regola di rinomina
+func (m ListModel) renameRuleLine(rule merge.RenameRule) string {
+	switch rule.Op {
+	case merge.RenameReplace:
+		return fmt.Sprintf(m.localizer.T("rename.rule_replace"), rule.Pattern, rule.Replacement)
+	case merge.RenamePrefix, merge.RenameSuffix:
+		return fmt.Sprintf(m.localizer.T("rename.rule_affix"), m.renameOpName(rule.Op), rule.Pattern)
+	}
+	return m.renameOpName(rule.Op)
+}
+
+// renameRow allinea una riga della tabella prima/dopo
+func renameRow(before, after string) string {
+	width := utf8.RuneCountInString(before)
+	if width > renameMaxWidth {
+		before = string([]rune(before)[:renameMaxWidth-1]) + "…"
+		width = renameMaxWidth
+	}
+	return fmt.Sprintf("  %s%*s → %s", before, renameMaxWidth-width, "", after)
+}
+
+// viewRenameTable mostra le prime righe della tabella prima/dopo
+func (m ListModel) viewRenameTable(renames []merge.Rename, maxRows int) string {
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	for i, r := range renames {
+		if i == maxRows {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("rename.more"), len(renames)-i)) + "\n"
+			break
+		}
+		s += normalStyle.Render(renameRow(r.Item.Name, r.NewName)) + "\n"
+	}
+	return s
+}
+
+// viewRenameCounts riassume il piano di rinomina
+func (m ListModel) viewRenameCounts(plan merge.RenamePlan) string {
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	s := normalStyle.Render(fmt.Sprintf(m.localizer.T("rename.counts"), len(plan.Renames), len(plan.Collisions))) + "\n"
+	if len(plan.Empty) > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("rename.empty"), len(plan.Empty))) + "\n"
+	}
+	return s
+}
+
+func (m ListModel) viewRename() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("rename.title"), m.renameCount(), entityPlural(m.localizer, m.entityType))) + "\n\n"
+
+	if len(m.renameRules) == 0 {
+		s += normalStyle.Render(m.localizer.T("rename.no_rules")) + "\n\n"
+		s += normalStyle.Render(m.localizer.T("rename.help")) + "\n"
+		return s
+	}
+
+	for i, rule := range m.renameRules {
+		line := fmt.Sprintf("%d. %s", i+1, m.renameRuleLine(rule))
+		if i == m.optCursor {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += normalStyle.Render("  "+line) + "\n"
+		}
+	}
+	s += "\n"
+
+	plan := m.currentRenamePlan(m.renameRules)
+	s += m.viewRenameCounts(plan) + "\n"
+	s += m.viewRenameTable(plan.Renames, 10) + "\n"
+
+	s += normalStyle.Render(m.localizer.T("rename.help")) + "\n"
+	return s
+}
+
+func (m ListModel) viewRenameRule() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("rename.title"), m.renameCount(), entityPlural(m.localizer, m.entityType))) + "\n\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("rename.op"), m.renameOpName(m.renameOp))) + "\n\n"
+
+	switch m.renameOp {
+	case merge.RenameReplace:
+		s += normalStyle.Render(m.localizer.T("rename.pattern_label")) + "\n"
+		s += m.mergeInput.View() + "\n\n"
+		s += normalStyle.Render(m.localizer.T("rename.replacement_label")) + "\n"
+		s += m.targetInput.View() + "\n\n"
+	case merge.RenamePrefix, merge.RenameSuffix:
+		s += normalStyle.Render(m.localizer.T("rename.affix_label")) + "\n"
+		s += m.mergeInput.View() + "\n\n"
+	}
+
+	// Anteprima con la nuova regola in coda a quelle già inserite
+	if rule, err := m.newRenameRule(); err == nil {
+		plan := m.currentRenamePlan(append(m.renameRules[:len(m.renameRules):len(m.renameRules)], rule))
+		s += m.viewRenameCounts(plan) + "\n"
+		s += m.viewRenameTable(plan.Renames, 5) + "\n"
+	}
+
+	s += normalStyle.Render(m.localizer.T("rename.rule_help")) + "\n"
+	return s
+}
+
+func (m ListModel) viewRenamePlan() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	plan := m.renamePlan
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("rename.title"), m.renameCount(), entityPlural(m.localizer, m.entityType))) + "\n\n"
+	s += m.viewRenameCounts(*plan) + "\n"
+
+	// La tabella occupa lo spazio lasciato libero dalle collisioni
+	maxRows := m.height - 16 - 3*len(plan.Collisions)
+	if maxRows < 5 {
+		maxRows = 5
+	}
+	if len(plan.Renames) > 0 {
+		s += normalStyle.Render(renameRow(m.localizer.T("rename.before"), m.localizer.T("rename.after"))) + "\n"
+		s += m.viewRenameTable(plan.Renames, maxRows) + "\n"
+	}
+
+	if len(plan.Collisions) > 0 {
+		s += normalStyle.Render(m.localizer.T("rename.collisions")) + "\n"
+		for i, collision := range plan.Collisions {
+			checkbox := "[ ]"
+			if m.renameMerge[i] {
+				checkbox = "[✓]"
+			}
+			line := fmt.Sprintf(m.localizer.T("rename.collision"), checkbox, collision.Name, len(collision.Items))
+			if i == m.optCursor {
+				s += selectedStyle.Render("> "+line) + "\n"
+			} else {
+				s += normalStyle.Render("  "+line) + "\n"
+			}
+			for _, r := range collision.Items {
+				s += normalStyle.Render("    "+renameRow(r.Item.Name, r.NewName)) + "\n"
+			}
+		}
+		s += "\n"
+	}
+
+	if m.client.DryRun {
+		s += normalStyle.Render(m.localizer.T("rename.plan_help_dry_run")) + "\n"
+	} else {
+		s += normalStyle.Render(m.localizer.T("rename.plan_help")) + "\n"
+	}
+	return s
+}
+
+// viewRenameDone mostra l'esito della rinomina appena completata
+func (m ListModel) viewRenameDone() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	r := m.renameResult
+
+	var s string
+	s += selectedStyle.Render(m.localizer.T("rename.done_title")) + "\n\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("rename.done_renamed"), r.Renamed)) + "\n"
+	if r.Merged > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("rename.done_merged"), r.Merged)) + "\n"
+	}
+	if len(r.References) > 0 {
+		s += "\n" + normalStyle.Render(fmt.Sprintf(m.localizer.T("list.summary_references"), len(r.References))) + "\n"
+		for _, ref := range r.References {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("list.reference_item"), referenceSource(m.localizer, ref.Source), ref.Name, ref.ID)) + "\n"
+		}
+	}
+	if len(r.Discrepancies) > 0 {
+		s += "\n" + errorStyle.Render(m.localizer.T("verify.title")) + "\n"
+		s += m.viewDiscrepancies(r.Discrepancies)
+	}
+
+	s += "\n" + normalStyle.Render(m.localizer.T("list.summary_help")) + "\n"
+	return s
+}
@@ -1,7 +1,12 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -11,6 +16,21 @@ import (
 	"github.com/meska/paperless-merger/internal/paperless"
 )
 
+// Campi della schermata di setup
+const (
+	fieldURL = iota
+	fieldAPIKey
+	fieldUsername
+	fieldPassword
+	fieldBasicUser
+	fieldBasicPassword
+	fieldTokenHeader
+	fieldHeaders // Primo degli header aggiuntivi, un input per header
+)
+
+// fieldLanguage è la selezione della lingua, che non è un input
+const fieldLanguage = -1
+
 // SetupModel rappresenta il modello per la configurazione iniziale
 type SetupModel struct {
 	config       *config.Config
@@ -18,6 +38,8 @@ type SetupModel struct {
 	inputs       []textinput.Model
 	focused      int
 	langCursor   int  // cursore per selezione lingua
+	login        bool // accesso con nome utente e password al posto dell'API key
+	proxy        bool // mostra le impostazioni del reverse proxy
 	err          error
 	quitting     bool
 	returnToMain bool // indica se tornare al main menu invece di uscire
@@ -37,28 +59,72 @@ func NewSetupModel(cfg *config.Config) SetupModel {
 		loc, _ = locale.New("en")
 	}
 	
-	inputs := make([]textinput.Model, 2)
+	inputs := make([]textinput.Model, fieldHeaders)
 
 	// Input per URL
-	inputs[0] = textinput.New()
-	inputs[0].Placeholder = loc.T("setup.url_placeholder")
-	inputs[0].Focus()
-	inputs[0].CharLimit = 200
-	inputs[0].Width = 50
+	inputs[fieldURL] = textinput.New()
+	inputs[fieldURL].Placeholder = loc.T("setup.url_placeholder")
+	inputs[fieldURL].Focus()
+	inputs[fieldURL].CharLimit = 200
+	inputs[fieldURL].Width = 50
 	if cfg.BaseURL != "" {
-		inputs[0].SetValue(cfg.BaseURL)
+		inputs[fieldURL].SetValue(cfg.BaseURL)
 	}
 
 	// Input per API Key
-	inputs[1] = textinput.New()
-	inputs[1].Placeholder = loc.T("setup.apikey_placeholder")
-	inputs[1].CharLimit = 200
-	inputs[1].Width = 50
-	inputs[1].EchoMode = textinput.EchoPassword
-	inputs[1].EchoCharacter = '•'
+	inputs[fieldAPIKey] = textinput.New()
+	inputs[fieldAPIKey].Placeholder = loc.T("setup.apikey_placeholder")
+	inputs[fieldAPIKey].CharLimit = 200
+	inputs[fieldAPIKey].Width = 50
+	inputs[fieldAPIKey].EchoMode = textinput.EchoPassword
+	inputs[fieldAPIKey].EchoCharacter = '•'
 	if cfg.APIKey != "" {
-		inputs[1].SetValue(cfg.APIKey)
+		inputs[fieldAPIKey].SetValue(cfg.APIKey)
+	}
+
+	// Input per nome utente e password, usati solo per ottenere il token
+	inputs[fieldUsername] = textinput.New()
+	inputs[fieldUsername].Placeholder = loc.T("setup.username_placeholder")
+	inputs[fieldUsername].CharLimit = 150
+	inputs[fieldUsername].Width = 50
+
+	inputs[fieldPassword] = textinput.New()
+	inputs[fieldPassword].CharLimit = 200
+	inputs[fieldPassword].Width = 50
+	inputs[fieldPassword].EchoMode = textinput.EchoPassword
+	inputs[fieldPassword].EchoCharacter = '•'
+
+	// Input per l'autenticazione del reverse proxy
+	inputs[fieldBasicUser] = textinput.New()
+	inputs[fieldBasicUser].CharLimit = 150
+	inputs[fieldBasicUser].Width = 50
+	inputs[fieldBasicUser].SetValue(cfg.BasicAuthUser)
+
+	inputs[fieldBasicPassword] = textinput.New()
+	inputs[fieldBasicPassword].CharLimit = 200
+	inputs[fieldBasicPassword].Width = 50
+	inputs[fieldBasicPassword].EchoMode = textinput.EchoPassword
+	inputs[fieldBasicPassword].EchoCharacter = '•'
+	inputs[fieldBasicPassword].SetValue(cfg.BasicAuthPassword)
+
+	inputs[fieldTokenHeader] = textinput.New()
+	inputs[fieldTokenHeader].Placeholder = "Authorization"
+	inputs[fieldTokenHeader].CharLimit = 100
+	inputs[fieldTokenHeader].Width = 50
+	inputs[fieldTokenHeader].SetValue(cfg.TokenHeader)
+
+	// Un input per ogni header aggiuntivo ("Nome: valore"), più uno vuoto per aggiungerne
+	names := make([]string, 0, len(cfg.ExtraHeaders))
+	for name := range cfg.ExtraHeaders {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	for _, name := range names {
+		input := newHeaderInput()
+		input.SetValue(name + ": " + cfg.ExtraHeaders[name])
+		inputs = append(inputs, input)
+	}
+	inputs = append(inputs, newHeaderInput())
 
 	// Determina langCursor basato sulla lingua configurata
 	langCursor := 0
@@ -80,10 +146,20 @@ func NewSetupModel(cfg *config.Config) SetupModel {
 		inputs:       inputs,
 		focused:      0,
 		langCursor:   langCursor,
+		proxy:        cfg.HasProxyAuth(),
 		returnToMain: returnToMain,
 	}
 }
 
+// newHeaderInput crea l'input di un header aggiuntivo
+func newHeaderInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "Cookie: authelia_session=..."
+	input.CharLimit = 4096
+	input.Width = 50
+	return input
+}
+
 func (m SetupModel) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -96,11 +172,18 @@ func (m SetupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.quitting = true
 			return m, tea.Quit
 
+		case "ctrl+l":
+			return m.toggleLogin()
+
+		case "ctrl+p":
+			return m.toggleProxy()
+
 		case "tab", "shift+tab", "enter", "up", "down":
 			s := msg.String()
+			fields := m.inputFields()
 
-			// Se siamo nel campo lingua (focused == 2)
-			if m.focused == 2 {
+			// Se siamo nel campo lingua
+			if m.focused == fieldLanguage {
 				if s == "up" || s == "k" {
 					if m.langCursor > 0 {
 						m.langCursor--
@@ -116,56 +199,106 @@ func (m SetupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m.saveAndQuit()
 				} else if s == "shift+tab" || s == "up" {
 					// Torna al campo precedente
-					m.focused = 1
-					return m, m.inputs[1].Focus()
+					return m, m.focus(fields[len(fields)-1])
 				}
 				return m, nil
 			}
 
+			pos := 0
+			for i, field := range fields {
+				if field == m.focused {
+					pos = i
+				}
+			}
+
 			// Se premiamo enter sull'ultimo input, passiamo alla selezione lingua
-			if s == "enter" && m.focused == len(m.inputs)-1 {
-				m.focused = 2 // campo lingua
-				// Sfoca l'ultimo input
-				m.inputs[m.focused-2].Blur()
-				return m, nil
+			if s == "enter" && pos == len(fields)-1 {
+				return m, m.focus(fieldLanguage)
 			}
 
 			// Altrimenti navighiamo tra i campi
 			if s == "up" || s == "shift+tab" {
-				m.focused--
+				pos--
 			} else {
-				m.focused++
+				pos++
 			}
 
 			// Limita al range degli input
-			if m.focused > len(m.inputs)-1 {
-				m.focused = 0
-			} else if m.focused < 0 {
-				m.focused = len(m.inputs) - 1
+			if pos > len(fields)-1 {
+				pos = 0
+			} else if pos < 0 {
+				pos = len(fields) - 1
 			}
 
-			cmds := make([]tea.Cmd, len(m.inputs))
-			for i := 0; i <= len(m.inputs)-1; i++ {
-				if i == m.focused {
-					cmds[i] = m.inputs[i].Focus()
-				} else {
-					m.inputs[i].Blur()
-				}
-			}
-
-			return m, tea.Batch(cmds...)
+			return m, m.focus(fields[pos])
 		}
 	}
 
 	// Aggiorna l'input corrente solo se non siamo nel campo lingua
-	if m.focused < 2 {
+	if m.focused != fieldLanguage {
 		cmd := m.updateInputs(msg)
+		// Resta sempre un input vuoto per aggiungere un altro header
+		if m.inputs[len(m.inputs)-1].Value() != "" {
+			m.inputs = append(m.inputs, newHeaderInput())
+		}
 		return m, cmd
 	}
 	
 	return m, nil
 }
 
+// inputFields restituisce gli input mostrati, nell'ordine di navigazione
+func (m SetupModel) inputFields() []int {
+	fields := []int{fieldURL, fieldAPIKey}
+	if m.login {
+		fields = []int{fieldURL, fieldUsername, fieldPassword}
+	}
+	if m.proxy {
+		fields = append(fields, fieldBasicUser, fieldBasicPassword, fieldTokenHeader)
+		for i := fieldHeaders; i < len(m.inputs); i++ {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// focus sposta il cursore sul campo indicato
+func (m *SetupModel) focus(field int) tea.Cmd {
+	m.focused = field
+	var cmd tea.Cmd
+	for i := range m.inputs {
+		if i == field {
+			cmd = m.inputs[i].Focus()
+		} else {
+			m.inputs[i].Blur()
+		}
+	}
+	return cmd
+}
+
+// toggleLogin passa dall'API key all'accesso con nome utente e password e viceversa
+func (m SetupModel) toggleLogin() (tea.Model, tea.Cmd) {
+	m.login = !m.login
+	m.err = nil
+	if m.focused == fieldURL {
+		return m, nil
+	}
+	return m, m.focus(m.inputFields()[1])
+}
+
+// toggleProxy mostra o nasconde le impostazioni del reverse proxy. Nasconderle non le
+// cancella: per non usarle più vanno svuotate.
+func (m SetupModel) toggleProxy() (tea.Model, tea.Cmd) {
+	m.proxy = !m.proxy
+	if m.focused >= fieldBasicUser {
+		return m, m.focus(fieldURL)
+	}
+	if m.proxy && m.focused != fieldLanguage {
+		return m, m.focus(fieldBasicUser)
+	}
+	return m, nil
+}
+
 func (m *SetupModel) updateInputs(msg tea.Msg) tea.Cmd {
 	cmds := make([]tea.Cmd, len(m.inputs))
 
@@ -202,10 +335,36 @@ func (m SetupModel) View() string {
 	s += labelStyle.Render(m.localizer.T("setup.welcome")) + "\n\n"
 
 	s += labelStyle.Render(m.localizer.T("setup.url_label")) + "\n"
-	s += m.inputs[0].View() + "\n\n"
+	s += m.inputs[fieldURL].View() + "\n\n"
+
+	if m.login {
+		s += labelStyle.Render(m.localizer.T("setup.username_label")) + "\n"
+		s += m.inputs[fieldUsername].View() + "\n\n"
+		s += labelStyle.Render(m.localizer.T("setup.password_label")) + "\n"
+		s += m.inputs[fieldPassword].View() + "\n"
+		s += labelStyle.Render(m.localizer.T("setup.login_hint")) + "\n\n"
+	} else {
+		s += labelStyle.Render(m.localizer.T("setup.apikey_label")) + "\n"
+		s += m.inputs[fieldAPIKey].View() + "\n"
+		s += labelStyle.Render(m.localizer.T("setup.apikey_hint")) + "\n\n"
+	}
 
-	s += labelStyle.Render(m.localizer.T("setup.apikey_label")) + "\n"
-	s += m.inputs[1].View() + "\n\n"
+	if m.proxy {
+		s += selectedStyle.Render(m.localizer.T("setup.proxy_title")) + "\n"
+		s += labelStyle.Render(m.localizer.T("setup.basic_user_label")) + "\n"
+		s += m.inputs[fieldBasicUser].View() + "\n"
+		s += labelStyle.Render(m.localizer.T("setup.basic_password_label")) + "\n"
+		s += m.inputs[fieldBasicPassword].View() + "\n"
+		s += labelStyle.Render(m.localizer.T("setup.token_header_label")) + "\n"
+		s += m.inputs[fieldTokenHeader].View() + "\n"
+		s += labelStyle.Render(m.localizer.T("setup.headers_label")) + "\n"
+		for _, input := range m.inputs[fieldHeaders:] {
+			s += input.View() + "\n"
+		}
+		s += labelStyle.Render(m.localizer.T("setup.proxy_hide_hint")) + "\n\n"
+	} else {
+		s += labelStyle.Render(m.localizer.T("setup.proxy_hint")) + "\n\n"
+	}
 
 	// Selezione lingua
 	s += labelStyle.Render(m.localizer.T("setup.language_label")) + "\n"
@@ -218,7 +377,7 @@ func (m SetupModel) View() string {
 	
 	for i, opt := range langOptions {
 		cursor := " "
-		if m.focused == 2 && i == m.langCursor {
+		if m.focused == fieldLanguage && i == m.langCursor {
 			cursor = ">"
 			s += selectedStyle.Render(fmt.Sprintf("%s %s", cursor, opt)) + "\n"
 		} else {
@@ -237,8 +396,7 @@ func (m SetupModel) View() string {
 }
 
 func (m SetupModel) saveAndQuit() (tea.Model, tea.Cmd) {
-	m.config.BaseURL = m.inputs[0].Value()
-	m.config.APIKey = m.inputs[1].Value()
+	m.config.BaseURL = m.inputs[fieldURL].Value()
 	
 	// Salva la lingua selezionata
 	switch m.langCursor {
@@ -250,10 +408,42 @@ func (m SetupModel) saveAndQuit() (tea.Model, tea.Cmd) {
 		m.config.Language = "it"
 	}
 
+	// Le impostazioni del proxy servono già per chiedere il token
+	if err := m.applyProxy(); err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	// L'accesso con nome utente e password serve solo a ottenere il token,
+	// che viene salvato al posto della password
+	if m.login {
+		token, err := m.obtainToken()
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.inputs[fieldAPIKey].SetValue(token)
+		m.inputs[fieldPassword].SetValue("")
+		m.login = false
+	}
+	m.config.APIKey = m.inputs[fieldAPIKey].Value()
+
 	// Testa la connessione
-	client := paperless.NewClient(m.config.BaseURL, m.config.APIKey)
-	if err := client.TestConnection(); err != nil {
-		m.err = fmt.Errorf(m.localizer.T("setup.connection_failed"), err)
+	client, err := newClient(m.config)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	if err := client.TestConnection(context.Background()); err != nil {
+		if paperless.IsRedirect(err) {
+			// Il proxy non ha accettato le credenziali e rimanda alla sua pagina di login
+			m.err = fmt.Errorf(m.localizer.T("setup.proxy_redirect"), err)
+		} else if paperless.IsUnauthorized(err) || paperless.IsForbidden(err) {
+			// Il server risponde ma non accetta il token
+			m.err = fmt.Errorf(m.localizer.T("setup.token_rejected"), err)
+		} else {
+			m.err = fmt.Errorf(m.localizer.T("setup.connection_failed"), err)
+		}
 		return m, nil
 	}
 
@@ -276,6 +466,8 @@ func (m SetupModel) saveAndQuit() (tea.Model, tea.Cmd) {
 			loc.T("main.entity_tags"),
 			loc.T("main.entity_correspondents"),
 			loc.T("main.entity_doctypes"),
+			loc.T("main.entity_storage_paths"),
+			loc.T("main.entity_custom_fields"),
 		}
 		return mainModel, nil
 	}
@@ -283,3 +475,74 @@ func (m SetupModel) saveAndQuit() (tea.Model, tea.Cmd) {
 	m.quitting = true
 	return m, tea.Quit
 }
+
+// obtainToken chiede al server il token dell'utente con nome utente e password
+func (m SetupModel) obtainToken() (string, error) {
+	username := m.inputs[fieldUsername].Value()
+	password := m.inputs[fieldPassword].Value()
+	if username == "" || password == "" {
+		return "", errors.New(m.localizer.T("setup.login_missing"))
+	}
+
+	client, err := newClient(m.config)
+	if err != nil {
+		return "", err
+	}
+	client.APIKey = ""
+	token, err := client.ObtainToken(context.Background(), username, password)
+	switch {
+	case err == nil:
+		return token, nil
+	case errors.Is(err, paperless.ErrMFARequired):
+		return "", errors.New(m.localizer.T("setup.login_mfa"))
+	case paperless.IsRedirect(err):
+		return "", fmt.Errorf(m.localizer.T("setup.proxy_redirect"), err)
+	case paperless.StatusCode(err) == http.StatusBadRequest || paperless.IsUnauthorized(err):
+		// Credenziali sbagliate o account disattivato
+		return "", fmt.Errorf(m.localizer.T("setup.login_rejected"), err)
+	default:
+		return "", fmt.Errorf(m.localizer.T("setup.connection_failed"), err)
+	}
+}
+
+// applyProxy copia nella configurazione le impostazioni del reverse proxy
+func (m SetupModel) applyProxy() error {
+	headers := make(map[string]string)
+	for _, input := range m.inputs[fieldHeaders:] {
+		line := strings.TrimSpace(input.Value())
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return fmt.Errorf(m.localizer.T("setup.header_invalid"), line)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+
+	basicUser := strings.TrimSpace(m.inputs[fieldBasicUser].Value())
+	tokenHeader := strings.TrimSpace(m.inputs[fieldTokenHeader].Value())
+	if strings.EqualFold(tokenHeader, "Authorization") {
+		tokenHeader = ""
+	}
+	if strings.ContainsAny(tokenHeader, " \t:") {
+		return fmt.Errorf(m.localizer.T("setup.token_header_invalid"), tokenHeader)
+	}
+	// Basic auth e token non possono usare entrambi Authorization
+	if basicUser != "" && tokenHeader == "" {
+		return errors.New(m.localizer.T("setup.token_header_required"))
+	}
+
+	m.config.ExtraHeaders = nil
+	if len(headers) > 0 {
+		m.config.ExtraHeaders = headers
+	}
+	m.config.BasicAuthUser = basicUser
+	m.config.BasicAuthPassword = m.inputs[fieldBasicPassword].Value()
+	if basicUser == "" {
+		m.config.BasicAuthPassword = ""
+	}
+	m.config.TokenHeader = tokenHeader
+	return nil
+}
@@ -0,0 +1,388 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+	"github.com/meska/paperless-merger/internal/similarity"
+)
+
+// splitMaxDocuments è il numero massimo di documenti elencati nella schermata di suddivisione
+const splitMaxDocuments = 8
+
+type splitMsg struct {
+	source merge.SplitSource
+	err    error
+}
+
+// openSplit apre la suddivisione dell'elemento indicato e ne carica i documenti
+func (m ListModel) openSplit(item similarity.SimilarItem) (tea.Model, tea.Cmd) {
+	m.mode = "split"
+	m.splitSource = nil
+	m.splitRules = nil
+	m.splitResult = nil
+	m.optCursor = 0
+	m.searchInput.Blur()
+
+	m.targetInput = textinput.New()
+	m.targetInput.Placeholder = m.localizer.T("split.target_placeholder")
+	m.targetInput.CharLimit = 200
+	m.targetInput.Width = 50
+
+	client := m.client
+	kind := m.entityType
+	return m, func() tea.Msg {
+		source, err := merge.LoadSplit(context.Background(), client, kind, item.ID)
+		return splitMsg{source: source, err: err}
+	}
+}
+
+// closeSplit torna all'elenco degli elementi
+func (m ListModel) closeSplit() ListModel {
+	if m.mergeMode == ModeManual {
+		m.mode = "manual"
+	} else {
+		m.mode = "select"
+	}
+	m.splitSource = nil
+	m.splitRules = nil
+	m.mergeInput.Blur()
+	m.targetInput.Blur()
+	return m
+}
+
+// openSplitRule apre l'inserimento di una nuova regola
+func (m ListModel) openSplitRule() (tea.Model, tea.Cmd) {
+	m.mode = "split_rule"
+	m.splitCriterion = merge.SplitCriteria(m.entityType)[0]
+	m.mergeInput.SetValue("")
+	m.targetInput.SetValue("")
+	m.targetInput.Blur()
+	return m, m.mergeInput.Focus()
+}
+
+// newSplitRule costruisce la regola dai valori inseriti
+func (m ListModel) newSplitRule() (merge.SplitRule, error) {
+	return m.splitSource.NewRule(m.splitCriterion, m.mergeInput.Value(), m.targetInput.Value())
+}
+
+func (m ListModel) updateSplitMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		return m.closeSplit(), nil
+
+	case "up", "k":
+		if m.optCursor > 0 {
+			m.optCursor--
+		}
+
+	case "down", "j":
+		if m.optCursor < len(m.splitRules)-1 {
+			m.optCursor++
+		}
+
+	case "a":
+		if m.splitSource != nil {
+			return m.openSplitRule()
+		}
+
+	case "x", "delete":
+		// Rimuove la regola sotto il cursore
+		if m.optCursor < len(m.splitRules) {
+			m.splitRules = append(m.splitRules[:m.optCursor:m.optCursor], m.splitRules[m.optCursor+1:]...)
+			if m.optCursor > 0 && m.optCursor >= len(m.splitRules) {
+				m.optCursor--
+			}
+		}
+
+	case "enter":
+		if len(m.splitRules) > 0 {
+			return m.startSplit(m.client)
+		}
+
+	case "d":
+		// Simula la suddivisione con un client che non invia modifiche
+		if len(m.splitRules) > 0 {
+			dryClient, err := newClient(m.config)
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			dryClient.DryRun = true
+			return m.startSplit(dryClient)
+		}
+	}
+
+	return m, nil
+}
+
+func (m ListModel) updateSplitRuleMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc":
+		m.mode = "split"
+		m.mergeInput.Blur()
+		m.targetInput.Blur()
+		return m, nil
+
+	case "tab":
+		// Passa al criterio successivo
+		criteria := merge.SplitCriteria(m.entityType)
+		for i, criterion := range criteria {
+			if criterion == m.splitCriterion {
+				m.splitCriterion = criteria[(i+1)%len(criteria)]
+				break
+			}
+		}
+		return m, nil
+
+	case "up", "down":
+		// Passa dal valore alla destinazione e viceversa
+		if m.mergeInput.Focused() {
+			m.mergeInput.Blur()
+			return m, m.targetInput.Focus()
+		}
+		m.targetInput.Blur()
+		return m, m.mergeInput.Focus()
+
+	case "enter":
+		if m.mergeInput.Focused() {
+			m.mergeInput.Blur()
+			return m, m.targetInput.Focus()
+		}
+
+		rule, err := m.newSplitRule()
+		if err != nil {
+			m.err = mergeError(m.localizer, m.entityType, err)
+			return m, nil
+		}
+		m.splitRules = append(m.splitRules, rule)
+		m.optCursor = len(m.splitRules) - 1
+		m.mode = "split"
+		m.targetInput.Blur()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	if m.mergeInput.Focused() {
+		m.mergeInput, cmd = m.mergeInput.Update(msg)
+	} else {
+		m.targetInput, cmd = m.targetInput.Update(msg)
+	}
+	return m, cmd
+}
+
+func (m ListModel) updateSplitDoneMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "enter":
+		return m.reload()
+	}
+
+	return m, nil
+}
+
+// startSplit avvia la suddivisione in una goroutine
+func (m ListModel) startSplit(client *paperless.Client) (tea.Model, tea.Cmd) {
+	split := merge.Split{Kind: m.entityType, SourceID: m.splitSource.Item.ID, Rules: m.splitRules}
+
+	m.merging = true
+	m.mergeStatus = m.localizer.T("merge.status_start")
+	m.mergeProgress = 0
+	m.mergeCurrent = 0
+	m.mergeTotal = 0
+	m.progressChan = make(chan tea.Msg, 10)
+	ctx := m.operationContext()
+
+	progressChan := m.progressChan
+	go func() {
+		executor := newExecutor(m.config, client, nil, merge.ReporterFunc(func(p merge.Progress) {
+			progressChan <- mergeProgressMsg{
+				current: p.Current,
+				total:   p.Total,
+				status:  progressStatus(m.localizer, p),
+			}
+		}))
+
+		var msg tea.Msg
+		result, err := executor.Split(ctx, split)
+		switch {
+		case err != nil:
+			msg = mergeCompleteMsg{err: mergeError(m.localizer, m.entityType, err)}
+		case client.DryRun:
+			msg = mergeCompleteMsg{dryRun: true, simulated: client.SkippedRequests()}
+		default:
+			msg = mergeCompleteMsg{split: &result}
+		}
+		progressChan <- msg
+		close(progressChan)
+	}()
+
+	return m, waitForProgress(m.progressChan)
+}
+
+// splitCriterionName restituisce il nome localizzato di un criterio di suddivisione
+func (m ListModel) splitCriterionName(criterion merge.SplitCriterion) string {
+	switch criterion {
+	case merge.SplitByCorrespondent:
+		return m.localizer.T("split.by_correspondent")
+	case merge.SplitByTitle:
+		return m.localizer.T("split.by_title")
+	case merge.SplitByDate:
+		return m.localizer.T("split.by_date")
+	}
+	return ""
+}
+
+// splitRuleLine descrive una regola con la sua destinazione
+func (m ListModel) splitRuleLine(rule merge.SplitRule, documents int) string {
+	target := m.localizer.T("split.target_new")
+	if existing := m.splitSource.Target(rule.Target); existing != nil {
+		target = fmt.Sprintf(m.localizer.T("split.target_existing"), existing.ID)
+	}
+	return fmt.Sprintf(m.localizer.T("split.rule"), m.splitCriterionName(rule.Criterion), rule.Value, rule.Target, target, documents)
+}
+
+// viewSplitDocuments elenca i primi documenti indicati
+func (m ListModel) viewSplitDocuments(docs []paperless.Document) string {
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var s string
+	for i, doc := range docs {
+		if i == splitMaxDocuments {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("split.more_documents"), len(docs)-i)) + "\n"
+			break
+		}
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("split.document"), doc.Title, doc.Created, doc.ID)) + "\n"
+	}
+	return s
+}
+
+func (m ListModel) viewSplit() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	if m.splitSource == nil {
+		return normalStyle.Render(m.localizer.T("list.loading")) + "\n"
+	}
+
+	source := m.splitSource
+	groups, rest := source.Assign(m.splitRules)
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("split.title"), source.Item.Name, len(source.Documents))) + "\n\n"
+
+	if len(m.splitRules) == 0 {
+		s += normalStyle.Render(m.localizer.T("split.no_rules")) + "\n\n"
+	} else {
+		for i, rule := range m.splitRules {
+			line := m.splitRuleLine(rule, len(groups[i]))
+			if i == m.optCursor {
+				s += selectedStyle.Render("> "+line) + "\n"
+			} else {
+				s += normalStyle.Render("  "+line) + "\n"
+			}
+		}
+		s += "\n"
+	}
+
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("split.remaining"), source.Item.Name, len(rest))) + "\n\n"
+
+	// Documenti della regola sotto il cursore, o quelli non ancora assegnati
+	if m.optCursor < len(m.splitRules) {
+		s += normalStyle.Render(m.localizer.T("split.rule_documents")) + "\n"
+		s += m.viewSplitDocuments(groups[m.optCursor]) + "\n"
+	} else if len(rest) > 0 {
+		s += normalStyle.Render(m.localizer.T("split.remaining_documents")) + "\n"
+		s += m.viewSplitDocuments(rest) + "\n"
+	}
+
+	if m.client.DryRun {
+		s += normalStyle.Render(m.localizer.T("split.help_dry_run")) + "\n"
+	} else {
+		s += normalStyle.Render(m.localizer.T("split.help")) + "\n"
+	}
+	return s
+}
+
+func (m ListModel) viewSplitRule() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	source := m.splitSource
+
+	var s string
+	s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("split.title"), source.Item.Name, len(source.Documents))) + "\n\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("split.criterion"), m.splitCriterionName(m.splitCriterion))) + "\n\n"
+
+	switch m.splitCriterion {
+	case merge.SplitByCorrespondent:
+		s += normalStyle.Render(m.localizer.T("split.value_correspondent")) + "\n"
+	case merge.SplitByTitle:
+		s += normalStyle.Render(m.localizer.T("split.value_title")) + "\n"
+	case merge.SplitByDate:
+		s += normalStyle.Render(m.localizer.T("split.value_date")) + "\n"
+	}
+	s += m.mergeInput.View() + "\n\n"
+	s += normalStyle.Render(fmt.Sprintf(m.localizer.T("split.target_label"), entitySingular(m.localizer, m.entityType))) + "\n"
+	s += m.targetInput.View() + "\n\n"
+
+	// Anteprima dei documenti che la regola prenderebbe dopo quelle già inserite
+	if rule, err := m.newSplitRule(); err == nil {
+		groups, _ := source.Assign(append(m.splitRules[:len(m.splitRules):len(m.splitRules)], rule))
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("split.rule_matches"), len(groups[len(groups)-1]))) + "\n\n"
+	}
+
+	s += normalStyle.Render(m.localizer.T("split.rule_help")) + "\n"
+	return s
+}
+
+// viewSplitDone mostra l'esito della suddivisione appena completata
+func (m ListModel) viewSplitDone() string {
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	r := m.splitResult
+
+	var s string
+	s += selectedStyle.Render(m.localizer.T("split.done_title")) + "\n\n"
+	for i, target := range r.Targets {
+		line := fmt.Sprintf(m.localizer.T("split.done_target"), m.splitRules[i].Target, target.ID, target.Moved)
+		if target.Created {
+			line += m.localizer.T("split.done_created")
+		}
+		s += normalStyle.Render(line) + "\n"
+	}
+	s += "\n" + normalStyle.Render(fmt.Sprintf(m.localizer.T("split.remaining"), m.splitSource.Item.Name, r.Remaining)) + "\n"
+
+	s += "\n" + normalStyle.Render(m.localizer.T("list.summary_help")) + "\n"
+	return s
+}
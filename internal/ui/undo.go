@@ -0,0 +1,292 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/meska/paperless-merger/internal/config"
+	"github.com/meska/paperless-merger/internal/locale"
+	"github.com/meska/paperless-merger/internal/merge"
+	"github.com/meska/paperless-merger/internal/paperless"
+)
+
+// UndoModel rappresenta la schermata di annullamento dell'ultimo merge
+type UndoModel struct {
+	config       *config.Config
+	localizer    *locale.Localizer
+	client       *paperless.Client
+	journal      *merge.Journal // Merge da annullare (nil se non ce ne sono)
+	loading      bool
+	undoing      bool
+	done         bool
+	undoStatus   string
+	undoProgress float64
+	progressChan chan tea.Msg
+	progress     progress.Model
+	cancel       context.CancelFunc // Annulla l'undo in corso (tasto Esc)
+	cancelling   bool               // Annullamento richiesto, in attesa che l'undo si fermi
+	err          error
+}
+
+type undoLoadedMsg struct {
+	journal *merge.Journal
+	err     error
+}
+
+type undoCompleteMsg struct {
+	err error
+}
+
+// journalStore restituisce lo store dei journal della configurazione
+func journalStore(cfg *config.Config) (*merge.JournalStore, error) {
+	dir, err := cfg.JournalDir()
+	if err != nil {
+		return nil, err
+	}
+	return merge.NewJournalStore(dir), nil
+}
+
+// NewUndoModel crea la schermata di annullamento dell'ultimo merge
+func NewUndoModel(cfg *config.Config, loc *locale.Localizer) UndoModel {
+	prog := progress.New(progress.WithDefaultGradient())
+	prog.Width = 50
+
+	client, err := newClient(cfg)
+	if err == nil {
+		client.DryRun = cfg.DryRun
+	}
+
+	return UndoModel{
+		config:    cfg,
+		localizer: loc,
+		client:    client,
+		loading:   err == nil,
+		err:       err,
+		progress:  prog,
+	}
+}
+
+// operationContext crea il contesto dell'undo, annullabile con Esc
+func (m *UndoModel) operationContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.cancelling = false
+	return ctx
+}
+
+func (m UndoModel) Init() tea.Cmd {
+	if m.err != nil {
+		return nil
+	}
+	return m.loadJournal
+}
+
+func (m UndoModel) loadJournal() tea.Msg {
+	store, err := journalStore(m.config)
+	if err != nil {
+		return undoLoadedMsg{err: err}
+	}
+
+	journal, err := store.LastUndoable()
+	if errors.Is(err, merge.ErrNothingToUndo) {
+		return undoLoadedMsg{}
+	}
+	return undoLoadedMsg{journal: journal, err: err}
+}
+
+func (m UndoModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case undoLoadedMsg:
+		m.loading = false
+		m.journal = msg.journal
+		m.err = msg.err
+		return m, nil
+
+	case mergeProgressMsg:
+		m.undoStatus = msg.status
+		if msg.total > 0 {
+			m.undoProgress = float64(msg.current) / float64(msg.total)
+		}
+		return m, waitForProgress(m.progressChan)
+
+	case undoCompleteMsg:
+		m.undoing = false
+		if m.cancel != nil {
+			m.cancel()
+			m.cancel = nil
+		}
+		m.cancelling = false
+		if msg.err != nil {
+			m.err = mergeError(m.localizer, m.journal.Plan.Kind, msg.err)
+			return m, nil
+		}
+		m.done = true
+		return m, nil
+
+	case tea.KeyMsg:
+		// Durante l'undo l'unico tasto attivo è Esc, che lo ferma tra un passo e l'altro:
+		// l'undo resta nel journal e può essere ripetuto
+		if m.undoing {
+			if msg.String() == "esc" && m.cancel != nil {
+				m.cancelling = true
+				m.cancel()
+			}
+			return m, nil
+		}
+
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+
+		case "esc", "q":
+			// Torna al menu principale
+			return NewMainModel(m.config), nil
+
+		case "enter":
+			// Un undo fallito può essere ripetuto senza duplicare gli elementi
+			if m.journal != nil && !m.done && !m.loading {
+				return m.startUndo()
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// startUndo avvia l'annullamento del merge in una goroutine
+func (m UndoModel) startUndo() (tea.Model, tea.Cmd) {
+	store, err := journalStore(m.config)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	// In dry-run l'undo è solo simulato: il journal non va aggiornato
+	if m.client.DryRun {
+		store = nil
+	}
+
+	m.err = nil
+	m.undoing = true
+	m.undoStatus = m.localizer.T("undo.status_start")
+	m.undoProgress = 0
+	m.progressChan = make(chan tea.Msg, 10)
+
+	journal := m.journal
+	ctx := m.operationContext()
+	progressChan := m.progressChan
+	executor := newExecutor(m.config, m.client, store, merge.ReporterFunc(func(p merge.Progress) {
+		progressChan <- mergeProgressMsg{
+			current: p.Current,
+			total:   p.Total,
+			status:  progressStatus(m.localizer, p),
+		}
+	}))
+
+	go func() {
+		err := executor.Undo(ctx, journal)
+		progressChan <- undoCompleteMsg{err: err}
+		close(progressChan)
+	}()
+
+	return m, waitForProgress(m.progressChan)
+}
+
+func (m UndoModel) View() string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("170")).
+		MarginBottom(1)
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("170")).
+		Bold(true)
+
+	normalStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	errorStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("196")).
+		Bold(true)
+
+	s := titleStyle.Render(m.localizer.T("undo.title")) + "\n\n"
+
+	if m.loading {
+		s += normalStyle.Render(m.localizer.T("list.loading")) + "\n"
+		return s
+	}
+
+	if m.undoing {
+		s += normalStyle.Render(m.undoStatus) + "\n\n"
+		s += m.progress.ViewAs(m.undoProgress) + "\n"
+		if m.cancelling {
+			s += "\n" + normalStyle.Render(m.localizer.T("list.cancelling")) + "\n"
+		} else if m.cancel != nil {
+			s += "\n" + normalStyle.Render(m.localizer.T("list.cancel_help")) + "\n"
+		}
+		return s
+	}
+
+	if m.journal == nil {
+		if m.err != nil {
+			s += errorStyle.Render(fmt.Sprintf(m.localizer.T("list.error"), m.err)) + "\n\n"
+		} else {
+			s += normalStyle.Render(m.localizer.T("undo.nothing")) + "\n\n"
+		}
+		s += normalStyle.Render(m.localizer.T("undo.help_back")) + "\n"
+		return s
+	}
+
+	j := m.journal
+	if j.Cleanup {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("undo.cleanup_date"), j.CreatedAt.Format("2006-01-02 15:04:05"), entityPlural(m.localizer, j.Plan.Kind))) + "\n"
+	} else {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("undo.merge_date"), j.CreatedAt.Format("2006-01-02 15:04:05"), entityPlural(m.localizer, j.Plan.Kind))) + "\n"
+	}
+	if j.Unfinished() && j.Cleanup {
+		s += errorStyle.Render(m.localizer.T("undo.cleanup_failed")) + "\n"
+	} else if j.Unfinished() {
+		s += errorStyle.Render(m.localizer.T("undo.merge_failed")) + "\n"
+	}
+	s += "\n"
+
+	if j.Cleanup {
+		// Vengono ricreati solo gli elementi effettivamente eliminati
+		var deleted []merge.AbsorbedItem
+		for _, absorbed := range j.Absorbed {
+			if absorbed.Deleted {
+				deleted = append(deleted, absorbed)
+			}
+		}
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("undo.absorbed"), len(deleted))) + "\n"
+		for _, absorbed := range deleted {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("undo.cleanup_item"), absorbed.Item.Name, absorbed.Item.ID)) + "\n"
+		}
+	} else {
+		s += selectedStyle.Render(fmt.Sprintf(m.localizer.T("undo.survivor"), j.Plan.FinalName, j.Survivor.ID, j.Survivor.Name)) + "\n\n"
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("undo.absorbed"), len(j.Absorbed))) + "\n"
+		for _, absorbed := range j.Absorbed {
+			s += normalStyle.Render(fmt.Sprintf(m.localizer.T("undo.absorbed_item"), absorbed.Item.Name, absorbed.Item.ID, len(absorbed.Documents))) + "\n"
+		}
+	}
+	if len(j.References) > 0 {
+		s += normalStyle.Render(fmt.Sprintf(m.localizer.T("undo.references"), len(j.References))) + "\n"
+	}
+	s += "\n"
+
+	if m.err != nil {
+		s += errorStyle.Render(fmt.Sprintf(m.localizer.T("list.error"), m.err)) + "\n\n"
+	}
+
+	if m.done {
+		s += selectedStyle.Render(m.localizer.T("undo.done")) + "\n\n"
+		s += normalStyle.Render(m.localizer.T("undo.help_back")) + "\n"
+		return s
+	}
+
+	s += normalStyle.Render(m.localizer.T("undo.help")) + "\n"
+	return s
+}